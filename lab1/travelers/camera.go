@@ -0,0 +1,107 @@
+package travelers
+
+import (
+	"context"
+	"time"
+)
+
+// Structures - Picture
+
+type PictureCell struct {
+	TravelerId         TravelerId
+	HorizontalEdgeBlur bool
+	VerticalEdgeBlur   bool
+}
+
+func (cell PictureCell) Empty() bool {
+	return cell.TravelerId == NullTraveler
+}
+
+// Picture is an immutable snapshot of the card, so it can be shared by
+// all the observers and kept after the camera moved on.
+type Picture struct {
+	number uint
+	time   time.Time
+	cells  [][]PictureCell
+}
+
+func (picture *Picture) Number() uint {
+	return picture.number
+}
+
+func (picture *Picture) Time() time.Time {
+	return picture.time
+}
+
+func (picture *Picture) Width() int {
+	if len(picture.cells) == 0 {
+		return 0
+	}
+	return len(picture.cells[0])
+}
+
+func (picture *Picture) Height() int {
+	return len(picture.cells)
+}
+
+func (picture *Picture) Cell(x int, y int) PictureCell {
+	return picture.cells[y][x]
+}
+
+// PictureObserver is notified by the camera about every picture it takes.
+// The observers are called from the camera goroutine one after another.
+type PictureObserver interface {
+	ObservePicture(picture *Picture)
+}
+
+type PictureObserverFunc func(picture *Picture)
+
+func (observe PictureObserverFunc) ObservePicture(picture *Picture) {
+	observe(picture)
+}
+
+// Structures - Camera
+
+type Camera struct {
+	pictureCount uint
+	maxPictures  uint
+	card         *TravelersCard
+	observers    []PictureObserver
+}
+
+func newCamera(card *TravelersCard, maxPictures uint, observers []PictureObserver) *Camera {
+	return &Camera{
+		pictureCount: 0,
+		maxPictures:  maxPictures,
+		card:         card,
+		observers:    observers,
+	}
+}
+
+// start returns once the context is cancelled or when only the final
+// picture is left to reach maxPictures (if it is not 0).
+func (camera *Camera) start(ctx context.Context) {
+	defer camera.card.waitGroup.Done()
+
+	for camera.maxPictures == 0 || camera.pictureCount+1 < camera.maxPictures {
+		camera.takePicture()
+
+		if !sleep(ctx, sleepDuration) {
+			return
+		}
+	}
+}
+
+func (camera *Camera) takePicture() {
+	camera.pictureCount++
+
+	picture := &Picture{
+		number: camera.pictureCount,
+		time:   time.Now(),
+		cells:  camera.card.snapshot(),
+	}
+
+	for _, observer := range camera.observers {
+		observer.ObservePicture(picture)
+	}
+}
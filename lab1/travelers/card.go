@@ -0,0 +1,146 @@
+package travelers
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+)
+
+type TravelersCard struct {
+	height            int
+	width             int
+	travelerIdManager *TravelerIdManager
+	exits             [][]bool
+	lifetime          uint
+	grid              [][]*Node
+	stopChannel       chan struct{}
+
+	// travelers and the camera
+	waitGroup sync.WaitGroup
+	// nodes which are still spawning travelers
+	spawnersWaitGroup sync.WaitGroup
+	nodesWaitGroup    sync.WaitGroup
+}
+
+func newTravelersCard(
+	width int, height int, travlerIdManager *TravelerIdManager, exits [][]bool, lifetime uint,
+) *TravelersCard {
+	grid := make([][]*Node, height)
+	for y := range grid {
+		grid[y] = make([]*Node, width)
+		for x := range grid[y] {
+			grid[y][x] = newNode(Coordinates{x, y})
+		}
+	}
+
+	return &TravelersCard{
+		height:            height,
+		width:             width,
+		travelerIdManager: travlerIdManager,
+		exits:             exits,
+		lifetime:          lifetime,
+		grid:              grid,
+		stopChannel:       make(chan struct{}),
+	}
+}
+
+func (card *TravelersCard) startNodes(ctx context.Context, spawnProb float64, moveProb float64) {
+	for y := range card.grid {
+		for x := range card.grid[y] {
+			card.spawnersWaitGroup.Add(1)
+			card.nodesWaitGroup.Add(1)
+			go card.grid[y][x].start(ctx, card, spawnProb, moveProb)
+		}
+	}
+}
+
+// stopNodes may only be called once no traveler can send a request anymore.
+func (card *TravelersCard) stopNodes() {
+	close(card.stopChannel)
+	card.nodesWaitGroup.Wait()
+}
+
+func (card *TravelersCard) isExit(c Coordinates) bool {
+	return card.exits != nil && card.exits[c.y][c.x]
+}
+
+// ParseExits reads a list of exits written as "x,y;x,y" for a card of the
+// given size.
+func ParseExits(value string, width int, height int) ([][]bool, error) {
+	exits := make([][]bool, height)
+	for y := range exits {
+		exits[y] = make([]bool, width)
+	}
+
+	for _, exit := range strings.Split(value, ";") {
+		var x, y int
+		if _, err := fmt.Sscanf(strings.TrimSpace(exit), "%d,%d", &x, &y); err != nil {
+			return nil, fmt.Errorf("invalid exit %q - must be x,y", exit)
+		}
+		if x < 0 || x >= width || y < 0 || y >= height {
+			return nil, fmt.Errorf("invalid exit %q - must be on the card", exit)
+		}
+		exits[y][x] = true
+	}
+	return exits, nil
+}
+
+func (card *TravelersCard) getNewPosition(c Coordinates) Coordinates {
+	moves := []Coordinates{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+	rand.Shuffle(len(moves), func(i, j int) {
+		moves[i], moves[j] = moves[j], moves[i]
+	})
+
+	for _, move := range moves {
+		x, y := c.x+move.x, c.y+move.y
+		if x >= 0 && x < card.width && y >= 0 && y < card.height {
+			return Coordinates{x, y}
+		}
+	}
+
+	return c
+}
+
+func (card *TravelersCard) snapshot() [][]PictureCell {
+	card.sendCameraRequests(cameraBlockNode)
+	card.sendCameraRequests(cameraDrainNode)
+	responses := card.sendCameraRequests(cameraSnapshotNode)
+	card.sendCameraRequests(cameraReleaseNode)
+
+	cells := make([][]PictureCell, card.height)
+	for y := range responses {
+		cells[y] = make([]PictureCell, card.width)
+		for x, response := range responses[y] {
+			cells[y][x] = PictureCell{
+				TravelerId:         response.travelerId,
+				HorizontalEdgeBlur: response.horizontalEdgeBlur,
+				VerticalEdgeBlur:   response.verticalEdgeBlur,
+			}
+		}
+	}
+	return cells
+}
+
+// sendCameraRequests sends the request to all the nodes before waiting
+// for any of the responses, so the nodes can answer them in any order.
+func (card *TravelersCard) sendCameraRequests(value NodeCameraRequestType) [][]NodeCameraResponse {
+	requests := make([][]NodeCameraRequest, card.height)
+	for y := range card.grid {
+		requests[y] = make([]NodeCameraRequest, card.width)
+		for x := range card.grid[y] {
+			requests[y][x] = newNodeCameraRequest(value)
+			card.grid[y][x].cameraChannel <- requests[y][x]
+		}
+	}
+
+	responses := make([][]NodeCameraResponse, card.height)
+	for y := range requests {
+		responses[y] = make([]NodeCameraResponse, card.width)
+		for x := range requests[y] {
+			responses[y][x] = <-requests[y][x].response
+		}
+	}
+	return responses
+}
@@ -0,0 +1,75 @@
+package travelers
+
+import "sync"
+
+// TravelerIdRequest.id is the id given back by a releaseId request, which
+// has no response.
+type TravelerIdRequest struct {
+	value    TravelerIdRequestType
+	id       TravelerId
+	response chan TravelerId
+}
+
+func newTravelerIdRequest() TravelerIdRequest {
+	return TravelerIdRequest{value: getId, response: make(chan TravelerId)}
+}
+
+func newTravelerIdRelease(id TravelerId) TravelerIdRequest {
+	return TravelerIdRequest{value: releaseId, id: id}
+}
+
+type TravelerIdChannel chan TravelerIdRequest
+
+// The ids of the travelers who left the card are given out again, the
+// longest released one first.
+type TravelerIdManager struct {
+	nextId      TravelerId
+	maxId       TravelerId
+	releasedIds []TravelerId
+	spawned     uint
+	released    uint
+	channel     TravelerIdChannel
+}
+
+func newTravelerIdManager(maxTravelers TravelerId) *TravelerIdManager {
+	return &TravelerIdManager{
+		nextId:  0,
+		maxId:   maxTravelers,
+		channel: make(TravelerIdChannel),
+	}
+}
+
+// release gives the id of a traveler who left the card back to the manager.
+func (travelerIdManager *TravelerIdManager) release(id TravelerId) {
+	travelerIdManager.channel <- newTravelerIdRelease(id)
+}
+
+// stop may only be called once the nodes stopped spawning travelers.
+func (travelerIdManager *TravelerIdManager) stop() {
+	close(travelerIdManager.channel)
+}
+
+func (travelerIdManager *TravelerIdManager) start(waitGroup *sync.WaitGroup) {
+	defer waitGroup.Done()
+
+	for request := range travelerIdManager.channel {
+		switch request.value {
+		case getId:
+			if len(travelerIdManager.releasedIds) > 0 {
+				request.response <- travelerIdManager.releasedIds[0]
+				travelerIdManager.releasedIds = travelerIdManager.releasedIds[1:]
+				travelerIdManager.spawned++
+			} else if travelerIdManager.nextId < travelerIdManager.maxId {
+				request.response <- travelerIdManager.nextId
+				travelerIdManager.nextId++
+				travelerIdManager.spawned++
+			} else {
+				request.response <- NullTraveler
+			}
+
+		case releaseId:
+			travelerIdManager.releasedIds = append(travelerIdManager.releasedIds, request.id)
+			travelerIdManager.released++
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package travelers
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+type NodeCameraRequest struct {
+	value    NodeCameraRequestType
+	response chan NodeCameraResponse
+}
+
+func newNodeCameraRequest(value NodeCameraRequestType) NodeCameraRequest {
+	return NodeCameraRequest{
+		value:    value,
+		response: make(chan NodeCameraResponse, 1),
+	}
+}
+
+type NodeCameraResponse struct {
+	response           NodeResponse
+	travelerId         TravelerId
+	horizontalEdgeBlur bool
+	verticalEdgeBlur   bool
+}
+
+type NodeCameraRequestChannel chan NodeCameraRequest
+
+type NodeTravelerRequest struct {
+	value      NodeTravelerRequestType
+	travelerId TravelerId
+	travelerC  Coordinates
+	response   chan NodeResponse
+}
+
+func newNodeTravelerRequest(
+	value NodeTravelerRequestType, id TravelerId, c Coordinates,
+) NodeTravelerRequest {
+	return NodeTravelerRequest{
+		value:      value,
+		travelerId: id,
+		travelerC:  c,
+		response:   make(chan NodeResponse),
+	}
+}
+
+type NodeTravelerRequestChannel chan NodeTravelerRequest
+
+type Coordinates struct {
+	x int
+	y int
+}
+
+type Node struct {
+	c                  Coordinates
+	state              NodeState
+	travelerId         TravelerId
+	horizontalEdgeBlur bool
+	verticalEdgeBlur   bool
+	blocked            bool
+	pendingCamera      *NodeCameraRequest
+	cameraChannel      NodeCameraRequestChannel
+	travelersChannel   NodeTravelerRequestChannel
+}
+
+func newNode(c Coordinates) *Node {
+	return &Node{
+		c:                  c,
+		state:              nodeAvailable,
+		travelerId:         NullTraveler,
+		horizontalEdgeBlur: false,
+		verticalEdgeBlur:   false,
+		blocked:            false,
+		pendingCamera:      nil,
+		travelersChannel:   make(NodeTravelerRequestChannel),
+		cameraChannel:      make(NodeCameraRequestChannel),
+	}
+}
+
+func (node *Node) hasMovement() bool {
+	return node.state == nodeReservedIn || node.state == nodeReservedOut
+}
+
+func (node *Node) hasTraveler() bool {
+	return node.state == nodeOccupied || node.state == nodeReservedOut
+}
+
+func (node *Node) start(
+	ctx context.Context, card *TravelersCard, spawnProb float64, moveProb float64,
+) {
+	defer card.nodesWaitGroup.Done()
+
+	tick := time.After(sleepDuration)
+	done := ctx.Done()
+	for {
+		select {
+		case request := <-node.cameraChannel:
+			node.handleCameraRequest(request)
+
+		case request := <-node.travelersChannel:
+			node.handleTravelerRequest(request)
+			node.answerPendingCameraRequest()
+
+		case <-done:
+			// stop spawning, but keep serving the travelers finishing their moves
+			tick, done = nil, nil
+			card.spawnersWaitGroup.Done()
+
+		case <-card.stopChannel:
+			return
+
+		case <-tick:
+			tick = time.After(sleepDuration)
+
+			if node.state != nodeAvailable || node.blocked || card.isExit(node.c) {
+				continue
+			}
+
+			if !(rand.Float64() < spawnProb) {
+				continue
+			}
+
+			travelerIdRequest := newTravelerIdRequest()
+			card.travelerIdManager.channel <- travelerIdRequest
+
+			travelerId := <-travelerIdRequest.response
+			if travelerId == NullTraveler {
+				continue
+			}
+
+			node.state = nodeOccupied
+			node.travelerId = travelerId
+
+			newTraveler := Traveler{id: travelerId, c: node.c}
+
+			card.waitGroup.Add(1)
+			go newTraveler.start(ctx, card, moveProb)
+		}
+	}
+}
+
+// The camera takes a picture in four phases, sending each request to all
+// the nodes before waiting for the responses:
+//   - block: the node denies new reservations and stops spawning travelers,
+//   - drain: answered once no move is in progress on the node, so after all
+//     the nodes answered, no reservation made before the block is left,
+//   - snapshot: answered with the state of the node once the last move
+//     releasing it has finished, which cannot change anymore,
+//   - release: the node goes back to normal.
+//
+// Hence all the snapshot responses together form a consistent cut.
+func (node *Node) handleCameraRequest(request NodeCameraRequest) {
+	switch request.value {
+	case cameraBlockNode:
+		node.blocked = true
+		request.response <- NodeCameraResponse{response: requestAccepted}
+
+	case cameraDrainNode, cameraSnapshotNode:
+		if !node.blocked || node.pendingCamera != nil {
+			request.response <- NodeCameraResponse{response: requestDenied}
+			return
+		}
+		node.pendingCamera = &request
+		node.answerPendingCameraRequest()
+
+	case cameraReleaseNode:
+		node.blocked = false
+		request.response <- NodeCameraResponse{response: requestAccepted}
+	}
+}
+
+func (node *Node) answerPendingCameraRequest() {
+	if node.pendingCamera == nil || node.hasMovement() {
+		return
+	}
+
+	request := node.pendingCamera
+	node.pendingCamera = nil
+
+	if request.value == cameraDrainNode {
+		request.response <- NodeCameraResponse{response: requestAccepted}
+		return
+	}
+
+	response := NodeCameraResponse{
+		response:           requestAccepted,
+		travelerId:         NullTraveler,
+		horizontalEdgeBlur: node.horizontalEdgeBlur,
+		verticalEdgeBlur:   node.verticalEdgeBlur,
+	}
+	if node.hasTraveler() {
+		response.travelerId = node.travelerId
+	}
+
+	node.horizontalEdgeBlur = false
+	node.verticalEdgeBlur = false
+
+	request.response <- response
+}
+
+func (node *Node) handleTravelerRequest(request NodeTravelerRequest) {
+	switch request.value {
+	case travelerReserveNode:
+		if node.state == nodeAvailable && !node.blocked {
+			request.response <- requestAccepted
+
+			node.travelerId = request.travelerId
+			node.state = nodeReservedIn
+		} else {
+			request.response <- requestDenied
+		}
+
+	case travelerAssignNode:
+		if node.state == nodeReservedIn &&
+			node.travelerId == request.travelerId {
+
+			request.response <- requestAccepted
+			node.state = nodeOccupied
+
+			if node.c.y == request.travelerC.y &&
+				node.c.x == request.travelerC.x-1 {
+				node.horizontalEdgeBlur = true
+			} else if node.c.x == request.travelerC.x &&
+				node.c.y == request.travelerC.y-1 {
+				node.verticalEdgeBlur = true
+			}
+		} else {
+			request.response <- requestDenied
+		}
+
+	case travelerReleaseNode:
+		switch node.state {
+		case nodeOccupied:
+			if node.travelerId == request.travelerId {
+				request.response <- requestAccepted
+				node.state = nodeReservedOut
+			} else {
+				request.response <- requestDenied
+			}
+
+		case nodeReservedOut:
+			if node.travelerId == request.travelerId {
+				request.response <- requestAccepted
+				node.state = nodeAvailable
+				node.travelerId = NullTraveler
+
+				if node.c.y == request.travelerC.y &&
+					node.c.x == request.travelerC.x-1 {
+					node.horizontalEdgeBlur = true
+				} else if node.c.x == request.travelerC.x &&
+					node.c.y == request.travelerC.y-1 {
+					node.verticalEdgeBlur = true
+				}
+			} else {
+				request.response <- requestDenied
+			}
+
+		case nodeReservedIn:
+			if node.travelerId == request.travelerId {
+				request.response <- requestAccepted
+				node.state = nodeAvailable
+				node.travelerId = NullTraveler
+			} else {
+				request.response <- requestDenied
+			}
+
+		case nodeAvailable:
+			request.response <- requestDenied
+		}
+
+	case travelerLeaveNode:
+		// a traveler leaves only when no picture is being taken, so that
+		// every picture either has it on the node or not
+		if node.state == nodeOccupied && node.travelerId == request.travelerId && !node.blocked {
+			request.response <- requestAccepted
+			node.state = nodeAvailable
+			node.travelerId = NullTraveler
+		} else {
+			request.response <- requestDenied
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package travelers
+
+import "testing"
+
+const (
+	owner    TravelerId = 1
+	stranger TravelerId = 2
+)
+
+var nodeStateNames = map[NodeState]string{
+	nodeAvailable:   "available",
+	nodeReservedIn:  "reserved in",
+	nodeReservedOut: "reserved out",
+	nodeOccupied:    "occupied",
+}
+
+var travelerRequestNames = map[NodeTravelerRequestType]string{
+	travelerReserveNode: "reserve",
+	travelerAssignNode:  "assign",
+	travelerReleaseNode: "release",
+	travelerLeaveNode:   "leave",
+}
+
+// newTestNode returns a node in the state, held by the owner unless it is
+// available.
+func newTestNode(state NodeState, blocked bool) *Node {
+	node := newNode(Coordinates{1, 1})
+	node.state = state
+	node.blocked = blocked
+	if state != nodeAvailable {
+		node.travelerId = owner
+	}
+	return node
+}
+
+// sendTravelerRequest calls the handler directly, the response channel is
+// buffered since nobody waits on it yet.
+func sendTravelerRequest(
+	node *Node, value NodeTravelerRequestType, id TravelerId, c Coordinates,
+) NodeResponse {
+	request := newNodeTravelerRequest(value, id, c)
+	request.response = make(chan NodeResponse, 1)
+	node.handleTravelerRequest(request)
+	return <-request.response
+}
+
+func TestNodeTravelerRequests(t *testing.T) {
+	// blocked tells the response while the camera takes a picture, the node
+	// keeps its state whenever a request is denied
+	tests := []struct {
+		state    NodeState
+		request  NodeTravelerRequestType
+		id       TravelerId
+		response NodeResponse
+		blocked  NodeResponse
+		after    NodeState
+	}{
+		{nodeAvailable, travelerReserveNode, stranger, requestAccepted, requestDenied, nodeReservedIn},
+		{nodeAvailable, travelerAssignNode, stranger, requestDenied, requestDenied, nodeAvailable},
+		{nodeAvailable, travelerReleaseNode, stranger, requestDenied, requestDenied, nodeAvailable},
+		{nodeAvailable, travelerLeaveNode, stranger, requestDenied, requestDenied, nodeAvailable},
+
+		{nodeReservedIn, travelerReserveNode, owner, requestDenied, requestDenied, nodeReservedIn},
+		{nodeReservedIn, travelerReserveNode, stranger, requestDenied, requestDenied, nodeReservedIn},
+		{nodeReservedIn, travelerAssignNode, owner, requestAccepted, requestAccepted, nodeOccupied},
+		{nodeReservedIn, travelerAssignNode, stranger, requestDenied, requestDenied, nodeReservedIn},
+		{nodeReservedIn, travelerReleaseNode, owner, requestAccepted, requestAccepted, nodeAvailable},
+		{nodeReservedIn, travelerReleaseNode, stranger, requestDenied, requestDenied, nodeReservedIn},
+		{nodeReservedIn, travelerLeaveNode, owner, requestDenied, requestDenied, nodeReservedIn},
+		{nodeReservedIn, travelerLeaveNode, stranger, requestDenied, requestDenied, nodeReservedIn},
+
+		{nodeReservedOut, travelerReserveNode, owner, requestDenied, requestDenied, nodeReservedOut},
+		{nodeReservedOut, travelerReserveNode, stranger, requestDenied, requestDenied, nodeReservedOut},
+		{nodeReservedOut, travelerAssignNode, owner, requestDenied, requestDenied, nodeReservedOut},
+		{nodeReservedOut, travelerAssignNode, stranger, requestDenied, requestDenied, nodeReservedOut},
+		{nodeReservedOut, travelerReleaseNode, owner, requestAccepted, requestAccepted, nodeAvailable},
+		{nodeReservedOut, travelerReleaseNode, stranger, requestDenied, requestDenied, nodeReservedOut},
+		{nodeReservedOut, travelerLeaveNode, owner, requestDenied, requestDenied, nodeReservedOut},
+		{nodeReservedOut, travelerLeaveNode, stranger, requestDenied, requestDenied, nodeReservedOut},
+
+		{nodeOccupied, travelerReserveNode, owner, requestDenied, requestDenied, nodeOccupied},
+		{nodeOccupied, travelerReserveNode, stranger, requestDenied, requestDenied, nodeOccupied},
+		{nodeOccupied, travelerAssignNode, owner, requestDenied, requestDenied, nodeOccupied},
+		{nodeOccupied, travelerAssignNode, stranger, requestDenied, requestDenied, nodeOccupied},
+		{nodeOccupied, travelerReleaseNode, owner, requestAccepted, requestAccepted, nodeReservedOut},
+		{nodeOccupied, travelerReleaseNode, stranger, requestDenied, requestDenied, nodeOccupied},
+		{nodeOccupied, travelerLeaveNode, owner, requestAccepted, requestDenied, nodeAvailable},
+		{nodeOccupied, travelerLeaveNode, stranger, requestDenied, requestDenied, nodeOccupied},
+	}
+
+	for _, test := range tests {
+		for _, blocked := range []bool{false, true} {
+			name := nodeStateNames[test.state] + "/" + travelerRequestNames[test.request]
+			if test.id == stranger {
+				name += " by a stranger"
+			}
+			if blocked {
+				name += " while blocked"
+			}
+
+			t.Run(name, func(t *testing.T) {
+				node := newTestNode(test.state, blocked)
+
+				response, after := test.response, test.after
+				if blocked {
+					response = test.blocked
+				}
+				if response == requestDenied {
+					after = test.state
+				}
+
+				got := sendTravelerRequest(node, test.request, test.id, Coordinates{1, 2})
+				if got != response {
+					t.Errorf("response %d, expected %d", got, response)
+				}
+				if node.state != after {
+					t.Errorf("state %s, expected %s", nodeStateNames[node.state], nodeStateNames[after])
+				}
+
+				expectedId := NullTraveler
+				if after == nodeReservedIn && test.state == nodeAvailable {
+					expectedId = test.id
+				} else if after != nodeAvailable {
+					expectedId = owner
+				}
+				if node.travelerId != expectedId {
+					t.Errorf("traveler %d, expected %d", node.travelerId, expectedId)
+				}
+				if node.blocked != blocked {
+					t.Errorf("blocked changed to %v", node.blocked)
+				}
+			})
+		}
+	}
+}
+
+func TestNodeEdgeBlur(t *testing.T) {
+	tests := []struct {
+		name       string
+		state      NodeState
+		request    NodeTravelerRequestType
+		c          Coordinates
+		horizontal bool
+		vertical   bool
+	}{
+		{"assign from the right", nodeReservedIn, travelerAssignNode, Coordinates{2, 1}, true, false},
+		{"assign from below", nodeReservedIn, travelerAssignNode, Coordinates{1, 2}, false, true},
+		{"assign from the left", nodeReservedIn, travelerAssignNode, Coordinates{0, 1}, false, false},
+		{"assign from above", nodeReservedIn, travelerAssignNode, Coordinates{1, 0}, false, false},
+		{"release to the right", nodeReservedOut, travelerReleaseNode, Coordinates{2, 1}, true, false},
+		{"release downwards", nodeReservedOut, travelerReleaseNode, Coordinates{1, 2}, false, true},
+		{"release to the left", nodeReservedOut, travelerReleaseNode, Coordinates{0, 1}, false, false},
+		{"cancel", nodeReservedIn, travelerReleaseNode, Coordinates{2, 1}, false, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			node := newTestNode(test.state, false)
+			response := sendTravelerRequest(node, test.request, owner, test.c)
+			if response != requestAccepted {
+				t.Fatalf("response %d", response)
+			}
+			if node.horizontalEdgeBlur != test.horizontal || node.verticalEdgeBlur != test.vertical {
+				t.Errorf("edge blur %v/%v, expected %v/%v",
+					node.horizontalEdgeBlur, node.verticalEdgeBlur, test.horizontal, test.vertical)
+			}
+		})
+	}
+}
+
+// sendCameraRequest returns the response of the node and whether the node
+// answered it right away.
+func sendCameraRequest(node *Node, value NodeCameraRequestType) (NodeCameraResponse, bool) {
+	request := newNodeCameraRequest(value)
+	node.handleCameraRequest(request)
+
+	select {
+	case response := <-request.response:
+		return response, true
+	default:
+		return NodeCameraResponse{}, false
+	}
+}
+
+func TestNodeCameraRequests(t *testing.T) {
+	states := []NodeState{nodeAvailable, nodeReservedIn, nodeReservedOut, nodeOccupied}
+	cameraRequestNames := map[NodeCameraRequestType]string{
+		cameraDrainNode:    "drain",
+		cameraSnapshotNode: "snapshot",
+	}
+
+	for _, state := range states {
+		stateName := nodeStateNames[state]
+		moving := state == nodeReservedIn || state == nodeReservedOut
+
+		t.Run(stateName+"/block and release", func(t *testing.T) {
+			node := newTestNode(state, false)
+			if response, answered := sendCameraRequest(node, cameraBlockNode); !answered ||
+				response.response != requestAccepted || !node.blocked {
+				t.Errorf("block: %+v, answered %v, blocked %v", response, answered, node.blocked)
+			}
+			if response, answered := sendCameraRequest(node, cameraReleaseNode); !answered ||
+				response.response != requestAccepted || node.blocked {
+				t.Errorf("release: %+v, answered %v, blocked %v", response, answered, node.blocked)
+			}
+			if node.state != state {
+				t.Errorf("state changed to %s", nodeStateNames[node.state])
+			}
+		})
+
+		for _, value := range []NodeCameraRequestType{cameraDrainNode, cameraSnapshotNode} {
+			name := stateName + "/" + cameraRequestNames[value]
+
+			t.Run(name+" while running", func(t *testing.T) {
+				node := newTestNode(state, false)
+				if response, answered := sendCameraRequest(node, value); !answered ||
+					response.response != requestDenied {
+					t.Errorf("%+v, answered %v", response, answered)
+				}
+			})
+
+			t.Run(name+" while blocked", func(t *testing.T) {
+				node := newTestNode(state, true)
+				node.horizontalEdgeBlur = true
+
+				request := newNodeCameraRequest(value)
+				node.handleCameraRequest(request)
+
+				if moving {
+					select {
+					case <-request.response:
+						t.Fatal("answered during a move")
+					default:
+					}
+
+					if response, answered := sendCameraRequest(node, value); !answered ||
+						response.response != requestDenied {
+						t.Errorf("second request: %+v, answered %v", response, answered)
+					}
+
+					// the move finishes with the traveler on the node or away from it
+					finish := travelerReleaseNode
+					if state == nodeReservedIn {
+						finish = travelerAssignNode
+					}
+					sendTravelerRequest(node, finish, owner, Coordinates{0, 1})
+					node.answerPendingCameraRequest()
+				}
+
+				var response NodeCameraResponse
+				select {
+				case response = <-request.response:
+				default:
+					t.Fatal("not answered")
+				}
+
+				if response.response != requestAccepted || node.pendingCamera != nil {
+					t.Errorf("%+v, pending %v", response, node.pendingCamera != nil)
+				}
+				if value != cameraSnapshotNode {
+					return
+				}
+
+				expectedId := NullTraveler
+				if node.hasTraveler() {
+					expectedId = owner
+				}
+				if response.travelerId != expectedId {
+					t.Errorf("traveler %d, expected %d", response.travelerId, expectedId)
+				}
+				if !response.horizontalEdgeBlur || node.horizontalEdgeBlur {
+					t.Errorf("edge blur %v not passed on and cleared", node.horizontalEdgeBlur)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package travelers
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Renderer draws a single picture. Renderers are stateless, so one renderer
+// can be shared by many observers.
+type Renderer interface {
+	Render(w io.Writer, picture *Picture) error
+}
+
+var renderers = map[string]Renderer{
+	"ascii":   ASCIIRenderer{},
+	"unicode": UnicodeRenderer{},
+	"plain":   PlainRenderer{},
+}
+
+func RendererNames() []string {
+	names := make([]string, 0, len(renderers))
+	for name := range renderers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func NewRenderer(name string) (Renderer, error) {
+	renderer, exists := renderers[name]
+	if !exists {
+		return nil, fmt.Errorf("unknown renderer %q - must be one of: %s",
+			name, strings.Join(RendererNames(), ", "))
+	}
+	return renderer, nil
+}
+
+// RendererObserver renders every picture of the camera to its writer.
+type RendererObserver struct {
+	renderer Renderer
+	writer   io.Writer
+}
+
+func NewRendererObserver(renderer Renderer, writer io.Writer) *RendererObserver {
+	return &RendererObserver{renderer: renderer, writer: writer}
+}
+
+func (observer *RendererObserver) ObservePicture(picture *Picture) {
+	buffer := bufio.NewWriter(observer.writer)
+	if err := observer.renderer.Render(buffer, picture); err == nil {
+		buffer.Flush()
+	}
+}
+
+// Structures - ASCIIRenderer
+
+const (
+	horizontalBlur string = "--"
+	verticalBlur   string = "||"
+	noBlur         string = "  "
+)
+
+// ASCIIRenderer draws the pictures the same way the original camera did.
+type ASCIIRenderer struct{}
+
+func (renderer ASCIIRenderer) Render(w io.Writer, picture *Picture) error {
+	fmt.Fprint(w, ansiClear)
+	fmt.Fprintf(w, "Picture: %d\n", picture.Number())
+
+	for y := 0; y < picture.Height(); y++ {
+		for x := 0; x < picture.Width(); x++ {
+			cell := picture.Cell(x, y)
+			if cell.TravelerId != NullTraveler {
+				fmt.Fprintf(w, "[%02d]", cell.TravelerId)
+			} else {
+				fmt.Fprintf(w, "[%s]", noBlur)
+			}
+
+			if cell.HorizontalEdgeBlur {
+				fmt.Fprintf(w, "%s", horizontalBlur)
+			} else {
+				fmt.Fprintf(w, "%s", noBlur)
+			}
+		}
+
+		fmt.Fprintln(w)
+		for x := 0; x < picture.Width(); x++ {
+			if picture.Cell(x, y).VerticalEdgeBlur {
+				fmt.Fprintf(w, " %s ", verticalBlur)
+			} else {
+				fmt.Fprintf(w, " %s ", noBlur)
+			}
+			fmt.Fprintf(w, "%s", noBlur)
+		}
+
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Structures - UnicodeRenderer
+
+const (
+	ansiClear  string = "\033[H\033[2J"
+	ansiReset  string = "\033[0m"
+	ansiGreen  string = "\033[32m"
+	ansiYellow string = "\033[33m"
+)
+
+// UnicodeRenderer redraws a colored box-drawing grid in place of the
+// previous picture. The blur trails are drawn as arrows over the edge
+// the traveler crossed.
+type UnicodeRenderer struct{}
+
+func (renderer UnicodeRenderer) Render(w io.Writer, picture *Picture) error {
+	width := picture.Width()
+
+	fmt.Fprint(w, ansiClear)
+	fmt.Fprintf(w, "Picture: %d\n", picture.Number())
+	fmt.Fprintln(w, renderer.border("┌", "┬", "┐", width, nil))
+
+	for y := 0; y < picture.Height(); y++ {
+		fmt.Fprint(w, "│")
+		for x := 0; x < width; x++ {
+			cell := picture.Cell(x, y)
+			fmt.Fprint(w, renderer.cell(cell))
+
+			if x == width-1 {
+				fmt.Fprint(w, "│")
+			} else if cell.HorizontalEdgeBlur {
+				fmt.Fprint(w, ansiYellow+"↔"+ansiReset)
+			} else {
+				fmt.Fprint(w, "│")
+			}
+		}
+		fmt.Fprintln(w)
+
+		if y == picture.Height()-1 {
+			fmt.Fprintln(w, renderer.border("└", "┴", "┘", width, nil))
+			continue
+		}
+
+		blurs := make([]bool, width)
+		for x := range blurs {
+			blurs[x] = picture.Cell(x, y).VerticalEdgeBlur
+		}
+		fmt.Fprintln(w, renderer.border("├", "┼", "┤", width, blurs))
+	}
+
+	_, err := fmt.Fprint(w, ansiReset)
+	return err
+}
+
+func (renderer UnicodeRenderer) cell(cell PictureCell) string {
+	if cell.TravelerId == NullTraveler {
+		return "    "
+	}
+	return fmt.Sprintf("%s %02d %s", ansiGreen, cell.TravelerId, ansiReset)
+}
+
+func (renderer UnicodeRenderer) border(
+	left string, middle string, right string, width int, blurs []bool,
+) string {
+	var builder strings.Builder
+
+	builder.WriteString(left)
+	for x := 0; x < width; x++ {
+		if blurs != nil && blurs[x] {
+			builder.WriteString("─" + ansiYellow + "↕" + ansiReset + "──")
+		} else {
+			builder.WriteString("────")
+		}
+
+		if x < width-1 {
+			builder.WriteString(middle)
+		}
+	}
+	builder.WriteString(right)
+
+	return builder.String()
+}
+
+// Structures - PlainRenderer
+
+// PlainRenderer writes a picture as a header line followed by one line per
+// row with a space separated token per cell:
+//
+//	picture <number> <width> <height> <unix nanoseconds>
+//	<cell> <cell> ...
+//
+// A cell is "." when empty and "t<id>" for a traveler. It is followed by ">"
+// if a traveler moved over the edge to the right and by "v" if it moved over
+// the edge below.
+type PlainRenderer struct{}
+
+func (renderer PlainRenderer) Render(w io.Writer, picture *Picture) error {
+	fmt.Fprintf(w, "picture %d %d %d %d\n",
+		picture.Number(), picture.Width(), picture.Height(), picture.Time().UnixNano())
+
+	for y := 0; y < picture.Height(); y++ {
+		tokens := make([]string, picture.Width())
+		for x := range tokens {
+			tokens[x] = renderer.cell(picture.Cell(x, y))
+		}
+
+		if _, err := fmt.Fprintln(w, strings.Join(tokens, " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (renderer PlainRenderer) cell(cell PictureCell) string {
+	token := "."
+	if cell.TravelerId != NullTraveler {
+		token = fmt.Sprintf("t%d", cell.TravelerId)
+	}
+
+	if cell.HorizontalEdgeBlur {
+		token += ">"
+	}
+	if cell.VerticalEdgeBlur {
+		token += "v"
+	}
+	return token
+}
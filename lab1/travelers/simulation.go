@@ -0,0 +1,193 @@
+// Package travelers implements the multithreaded travelers simulation:
+// every node of the card, every traveler and the camera is a goroutine
+// and they only communicate through channels.
+package travelers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const minSize, maxSize int = 1, 10
+
+// NodeProbs are checked by an empty node (Spawn) and a traveler (Move)
+// on every tick.
+type NodeProbs struct {
+	Spawn float64
+	Move  float64
+}
+
+// Config describes a single simulation run.
+type Config struct {
+	Width        int
+	Height       int
+	MaxTravelers int
+	Probs        NodeProbs
+
+	// A traveler leaves the card once it stands on one of the Exits (if not
+	// nil, Height rows of Width nodes) or after Lifetime ticks (if not 0),
+	// its id is then given to a new traveler. No traveler spawns on an exit.
+	Exits    [][]bool
+	Lifetime uint
+
+	// The simulation stops by itself after Duration (if it is not 0)
+	// or once MaxPictures (if it is not 0) pictures were taken.
+	Duration    time.Duration
+	MaxPictures uint
+
+	Observers []PictureObserver
+}
+
+// Validate checks the config without starting anything, NewSimulation
+// rejects the same configs.
+func (config *Config) Validate() error {
+	if config.Width < minSize || config.Width > maxSize {
+		return errors.New("invalid value of width - must be in range [1, 10]")
+	}
+
+	if config.Height < minSize || config.Height > maxSize {
+		return errors.New("invalid value of height - must be in range [1, 10]")
+	}
+
+	if config.MaxTravelers < minSize || config.MaxTravelers > config.Width*config.Height {
+		return errors.New(
+			"invalid value of max_travelers - must be in range [1, width * height]")
+	}
+
+	if config.Probs.Spawn < 0 || config.Probs.Spawn > 1 {
+		return errors.New("invalid value of spawn_prob - must be in range [0, 1]")
+	}
+
+	if config.Probs.Move < 0 || config.Probs.Move > 1 {
+		return errors.New("invalid value of move_prob - must be in range [0, 1]")
+	}
+
+	if config.Exits != nil {
+		if len(config.Exits) != config.Height {
+			return errors.New("invalid exits - must have a row for every row of the card")
+		}
+		for _, row := range config.Exits {
+			if len(row) != config.Width {
+				return errors.New("invalid exits - must have a node for every node of the card")
+			}
+		}
+	}
+
+	if config.Duration < 0 {
+		return errors.New("invalid value of duration - must be non-negative")
+	}
+
+	return nil
+}
+
+type Statistics struct {
+	Seconds          float64    `json:"seconds"`
+	Pictures         uint       `json:"pictures"`
+	MaxTravelers     TravelerId `json:"max_travelers"`
+	TravelersSpawned uint       `json:"travelers_spawned"`
+	TravelersLeft    uint       `json:"travelers_left"`
+}
+
+func (statistics *Statistics) Print(w io.Writer) {
+	fmt.Fprintf(w, "Simulation finished after %.1fs\n", statistics.Seconds)
+	fmt.Fprintf(w, "Pictures taken: %d\n", statistics.Pictures)
+	fmt.Fprintf(w, "Travelers spawned: %d, at most %d at once (left the card: %d)\n",
+		statistics.TravelersSpawned, statistics.MaxTravelers, statistics.TravelersLeft)
+}
+
+type Simulation struct {
+	config            Config
+	travelerIdManager *TravelerIdManager
+	card              *TravelersCard
+	camera            *Camera
+
+	ctx               context.Context
+	cancel            context.CancelFunc
+	servicesWaitGroup sync.WaitGroup
+	startTime         time.Time
+
+	stopOnce   sync.Once
+	statistics *Statistics
+}
+
+func NewSimulation(config Config) (*Simulation, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	travelerIdManager := newTravelerIdManager(TravelerId(config.MaxTravelers))
+	card := newTravelersCard(
+		config.Width, config.Height, travelerIdManager, config.Exits, config.Lifetime)
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if config.Duration > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), config.Duration)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+
+	return &Simulation{
+		config:            config,
+		travelerIdManager: travelerIdManager,
+		card:              card,
+		camera:            newCamera(card, config.MaxPictures, config.Observers),
+		ctx:               ctx,
+		cancel:            cancel,
+	}, nil
+}
+
+// Start runs the simulation in the background, it must be called only once.
+func (simulation *Simulation) Start() {
+	simulation.startTime = time.Now()
+
+	simulation.servicesWaitGroup.Add(1)
+	go simulation.travelerIdManager.start(&simulation.servicesWaitGroup)
+
+	simulation.card.startNodes(
+		simulation.ctx, simulation.config.Probs.Spawn, simulation.config.Probs.Move)
+
+	simulation.card.waitGroup.Add(1)
+	go func() {
+		simulation.camera.start(simulation.ctx)
+		simulation.cancel()
+	}()
+}
+
+// Done is closed once the simulation wants to stop by itself, i.e. after
+// its duration or the number of its pictures was reached.
+func (simulation *Simulation) Done() <-chan struct{} {
+	return simulation.ctx.Done()
+}
+
+// Stop lets the in-flight moves finish, takes the final picture, stops all
+// the actors and returns the statistics of the run. It can be called many
+// times, only the first call stops the simulation.
+func (simulation *Simulation) Stop() *Statistics {
+	simulation.stopOnce.Do(func() {
+		simulation.cancel()
+
+		card := simulation.card
+		card.spawnersWaitGroup.Wait()
+		card.waitGroup.Wait()
+		simulation.camera.takePicture()
+
+		card.stopNodes()
+		simulation.travelerIdManager.stop()
+		simulation.servicesWaitGroup.Wait()
+
+		simulation.statistics = &Statistics{
+			Seconds:          time.Since(simulation.startTime).Seconds(),
+			Pictures:         simulation.camera.pictureCount,
+			MaxTravelers:     simulation.travelerIdManager.maxId,
+			TravelersSpawned: simulation.travelerIdManager.spawned,
+			TravelersLeft:    simulation.travelerIdManager.released,
+		}
+	})
+
+	return simulation.statistics
+}
@@ -0,0 +1,91 @@
+package travelers
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// checkPicture reports a picture having a traveler on more than one node
+// or more travelers than there can be on the card, and returns the number of
+// travelers on it.
+func checkPicture(t *testing.T, picture *Picture, maxTravelers int) int {
+	seen := make(map[TravelerId]bool)
+	for y := 0; y < picture.Height(); y++ {
+		for x := 0; x < picture.Width(); x++ {
+			id := picture.Cell(x, y).TravelerId
+			if id == NullTraveler {
+				continue
+			}
+
+			if id < 0 || int(id) >= maxTravelers {
+				t.Errorf("picture %d: unknown traveler %d on (%d,%d)", picture.Number(), id, x, y)
+			}
+			if seen[id] {
+				t.Errorf("picture %d: traveler %d on more than one node", picture.Number(), id)
+			}
+			seen[id] = true
+		}
+	}
+	return len(seen)
+}
+
+// TestSimulationStress runs several full cards at once, so that hundreds of
+// travelers move side by side, and checks every picture, run it with -race.
+func TestSimulationStress(t *testing.T) {
+	if testing.Short() {
+		t.Skip("the stress test takes several seconds")
+	}
+
+	const (
+		simulations  = 4
+		size         = maxSize
+		maxTravelers = size * size
+	)
+
+	var waitGroup sync.WaitGroup
+	for i := 0; i < simulations; i++ {
+		waitGroup.Add(1)
+		go func(i int) {
+			defer waitGroup.Done()
+
+			exits := make([][]bool, size)
+			for y := range exits {
+				exits[y] = make([]bool, size)
+			}
+			exits[0][0], exits[size-1][size-1] = true, true
+
+			pictures, mostTravelers := 0, 0
+			simulation, err := NewSimulation(Config{
+				Width:        size,
+				Height:       size,
+				MaxTravelers: maxTravelers,
+				Probs:        NodeProbs{Spawn: 1, Move: 1},
+				Exits:        exits,
+				Lifetime:     2,
+				Duration:     9 * time.Second,
+				Observers: []PictureObserver{PictureObserverFunc(func(picture *Picture) {
+					pictures++
+					mostTravelers = max(mostTravelers, checkPicture(t, picture, maxTravelers))
+				})},
+			})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			simulation.Start()
+			<-simulation.Done()
+			statistics := simulation.Stop()
+
+			// all nodes but the exits hold a traveler after the first tick
+			if pictures < 4 || mostTravelers < maxTravelers*9/10 {
+				t.Errorf("card %d: %d pictures with at most %d travelers", i, pictures, mostTravelers)
+			}
+			if statistics.TravelersSpawned < uint(maxTravelers) {
+				t.Errorf("card %d: only %d travelers spawned", i, statistics.TravelersSpawned)
+			}
+		}(i)
+	}
+	waitGroup.Wait()
+}
@@ -0,0 +1,84 @@
+package travelers
+
+import (
+	"context"
+	"math/rand"
+)
+
+type Traveler struct {
+	id    TravelerId
+	c     Coordinates
+	ticks uint
+}
+
+func (traveler *Traveler) start(ctx context.Context, card *TravelersCard, moveProb float64) {
+	defer card.waitGroup.Done()
+
+	for sleep(ctx, sleepDuration) {
+		traveler.ticks++
+		if traveler.mustLeave(card) && traveler.leave(card) {
+			card.travelerIdManager.release(traveler.id)
+			return
+		}
+
+		if rand.Float64() > moveProb {
+			continue
+		}
+
+		newC := card.getNewPosition(traveler.c)
+		currNode := card.grid[traveler.c.y][traveler.c.x]
+		newNode := card.grid[newC.y][newC.x]
+
+		reserveRequest := newNodeTravelerRequest(
+			travelerReserveNode, traveler.id, traveler.c)
+
+		newNode.travelersChannel <- reserveRequest
+		response := <-reserveRequest.response
+
+		if response == requestAccepted {
+			releaseRequest := newNodeTravelerRequest(
+				travelerReleaseNode, traveler.id, newC)
+
+			for {
+				currNode.travelersChannel <- releaseRequest
+				releaseResponse := <-releaseRequest.response
+				if releaseResponse == requestAccepted {
+					break
+				}
+			}
+
+			for {
+				assignRequest := newNodeTravelerRequest(
+					travelerAssignNode, traveler.id, traveler.c)
+
+				newNode.travelersChannel <- assignRequest
+				assignResponse := <-assignRequest.response
+				if assignResponse == requestAccepted {
+					traveler.c = newC
+					break
+				}
+			}
+
+			for {
+				currNode.travelersChannel <- releaseRequest
+				releaseResponse := <-releaseRequest.response
+				if releaseResponse == requestAccepted {
+					break
+				}
+			}
+		}
+	}
+}
+
+func (traveler *Traveler) mustLeave(card *TravelersCard) bool {
+	return card.isExit(traveler.c) || (card.lifetime > 0 && traveler.ticks >= card.lifetime)
+}
+
+// leave returns false if the node did not let the traveler go yet, i.e.
+// while the camera takes a picture.
+func (traveler *Traveler) leave(card *TravelersCard) bool {
+	leaveRequest := newNodeTravelerRequest(travelerLeaveNode, traveler.id, traveler.c)
+
+	card.grid[traveler.c.y][traveler.c.x].travelersChannel <- leaveRequest
+	return <-leaveRequest.response == requestAccepted
+}
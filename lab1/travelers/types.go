@@ -0,0 +1,66 @@
+package travelers
+
+import (
+	"context"
+	"time"
+)
+
+// Type aliases
+
+type (
+	TravelerId              int16
+	NodeState               uint8
+	NodeCameraRequestType   uint8
+	NodeTravelerRequestType uint8
+	NodeResponse            uint8
+	TravelerIdRequestType   uint8
+)
+
+// Constants
+
+const (
+	NullTraveler TravelerId = -1
+)
+
+const (
+	nodeAvailable   NodeState = iota
+	nodeReservedIn  NodeState = iota
+	nodeReservedOut NodeState = iota
+	nodeOccupied    NodeState = iota
+)
+
+const (
+	cameraBlockNode    NodeCameraRequestType = iota
+	cameraDrainNode    NodeCameraRequestType = iota
+	cameraSnapshotNode NodeCameraRequestType = iota
+	cameraReleaseNode  NodeCameraRequestType = iota
+)
+
+const (
+	travelerReserveNode NodeTravelerRequestType = iota
+	travelerAssignNode  NodeTravelerRequestType = iota
+	travelerReleaseNode NodeTravelerRequestType = iota
+	travelerLeaveNode   NodeTravelerRequestType = iota
+)
+
+const (
+	requestAccepted NodeResponse = iota
+	requestDenied   NodeResponse = iota
+)
+
+const (
+	getId     TravelerIdRequestType = iota
+	releaseId TravelerIdRequestType = iota
+)
+
+const sleepDuration time.Duration = 2 * time.Second
+
+// sleep returns false if the context was cancelled before the duration elapsed.
+func sleep(ctx context.Context, duration time.Duration) bool {
+	select {
+	case <-time.After(duration):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
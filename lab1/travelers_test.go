@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		error string
+	}{
+		{"defaults", nil, ""},
+		{"all options", []string{
+			"-H", "3", "-W", "5", "-T", "15", "-s", "1", "-m", "0",
+			"--exits", "0,0;4,2", "--lifetime", "20", "--duration", "1m",
+			"--pictures", "7", "--render", "plain",
+		}, ""},
+		{"unknown flag", []string{"--speed", "2"}, "unknown arguments"},
+		{"not a number", []string{"-H", "four"}, "bad integer"},
+		{"height too small", []string{"-H", "0"}, "invalid value of height"},
+		{"width too large", []string{"-W", "11"}, "invalid value of width"},
+		{"too many travelers", []string{"-H", "2", "-W", "2", "-T", "5"}, "invalid value of max_travelers"},
+		{"negative spawn_prob", []string{"-s", "-0.1"}, "invalid value of spawn_prob"},
+		{"spawn_prob above 1", []string{"-s", "1.5"}, "invalid value of spawn_prob"},
+		{"move_prob above 1", []string{"-m", "2"}, "invalid value of move_prob"},
+		{"negative lifetime", []string{"--lifetime", "-1"}, "invalid value of lifetime"},
+		{"negative pictures", []string{"--pictures", "-3"}, "invalid value of pictures"},
+		{"bad duration", []string{"--duration", "soon"}, "invalid value of duration"},
+		{"negative duration", []string{"--duration=-5s"}, "invalid value of duration"},
+		{"exit off the card", []string{"--exits", "6,0"}, "must be on the card"},
+		{"bad exit", []string{"--exits", "1;2"}, "must be x,y"},
+		{"unknown renderer", []string{"--render", "svg"}, "render"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := parseArgs(append([]string{"travelers"}, test.args...))
+			if test.error == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), test.error) {
+				t.Fatalf("expected an error containing %q, got %v", test.error, err)
+			}
+		})
+	}
+}
+
+func TestParseArgsConfig(t *testing.T) {
+	_, arguments, err := parseArgs([]string{
+		"travelers", "-H", "3", "-W", "5", "-T", "15", "-s", "0.2", "-m", "0.7",
+		"--exits", "4,2", "--lifetime", "20", "--duration", "1m", "--pictures", "7",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := arguments.config
+	if config.Height != 3 || config.Width != 5 || config.MaxTravelers != 15 {
+		t.Errorf("wrong size: %dx%d with %d travelers", config.Width, config.Height, config.MaxTravelers)
+	}
+	if config.Probs.Spawn != 0.2 || config.Probs.Move != 0.7 {
+		t.Errorf("wrong probabilities: %+v", config.Probs)
+	}
+	if len(config.Exits) != 3 || !config.Exits[2][4] || config.Exits[0][0] {
+		t.Errorf("wrong exits: %v", config.Exits)
+	}
+	if config.Lifetime != 20 || config.Duration != time.Minute || config.MaxPictures != 7 {
+		t.Errorf("wrong limits: lifetime %d, duration %v, pictures %d",
+			config.Lifetime, config.Duration, config.MaxPictures)
+	}
+	if arguments.renderer == nil {
+		t.Error("no renderer")
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"lab2/travelers"
+)
+
+// RunConfig holds every option of a run, the keys of a config file are the
+// long names of the flags with '_' in place of '-'. A run is deterministic
+// if it has a seed. Species (if not empty) replace the travelers made of
+// max_travelers, the spawn, move and wild probabilities, routing and lifetime.
+type RunConfig struct {
+	Height       int     `json:"height"`
+	Width        int     `json:"width"`
+	MaxTravelers int     `json:"max_travelers"`
+	SpawnProb    float64 `json:"spawn_prob"`
+	MoveProb     float64 `json:"move_prob"`
+	WildProb     float64 `json:"wild_prob"`
+	DangerProb   float64 `json:"danger_prob"`
+
+	DangerZoneDuration int                             `json:"danger_zone_duration"`
+	DangerZoneShape    travelers.DangerZoneShapeE      `json:"danger_zone_shape"`
+	DangerZoneSpread   float64                         `json:"danger_zone_spread"`
+	DangerZonesKill    bool                            `json:"danger_zones_kill"`
+	Scenario           []travelers.ScheduledDangerZone `json:"scenario,omitempty"`
+	QueueSize          int                             `json:"queue_size"`
+
+	Seed        *int64  `json:"seed,omitempty"`
+	FastForward bool    `json:"fast_forward"`
+	Speed       float64 `json:"speed"`
+
+	SpawnTiming    travelers.Timing `json:"spawn_timing"`
+	ThinkTiming    travelers.Timing `json:"think_timing"`
+	PatienceTiming travelers.Timing `json:"patience_timing"`
+	CameraTiming   travelers.Timing `json:"camera_timing"`
+
+	Duration      travelers.Duration `json:"duration"`
+	Pictures      uint               `json:"pictures"`
+	Watchdog      travelers.Duration `json:"watchdog"`
+	WatchdogAbort bool               `json:"watchdog_abort"`
+
+	Topology travelers.TopologyE `json:"topology"`
+	Map      string              `json:"map,omitempty"`
+	Exits    string              `json:"exits,omitempty"`
+	Lifetime uint                `json:"lifetime"`
+	Routing  travelers.RoutingE  `json:"routing"`
+	Species  []travelers.Species `json:"species,omitempty"`
+
+	Render   string `json:"render"`
+	EventLog string `json:"event_log,omitempty"`
+	Stats    string `json:"stats,omitempty"`
+	Export   string `json:"export,omitempty"`
+	Serve    string `json:"serve,omitempty"`
+	Control  string `json:"control,omitempty"`
+}
+
+func defaultRunConfig() RunConfig {
+	timings := travelers.DefaultTimings()
+	return RunConfig{
+		Height:             4,
+		Width:              6,
+		MaxTravelers:       10,
+		SpawnProb:          0.1,
+		MoveProb:           0.5,
+		WildProb:           0.025,
+		DangerProb:         0.025,
+		DangerZoneDuration: travelers.DefaultDangerZoneDuration,
+		DangerZoneShape:    travelers.DangerZoneShapeNode,
+		QueueSize:          travelers.DefaultQueueSize,
+		Speed:              1,
+		SpawnTiming:        timings.Spawn,
+		ThinkTiming:        timings.Think,
+		PatienceTiming:     timings.Patience,
+		CameraTiming:       timings.Camera,
+		Topology:           travelers.TopologyGrid,
+		Routing:            travelers.RoutingRandom,
+		Render:             "ascii",
+	}
+}
+
+// loadRunConfig reads a config file over the values already in the config,
+// so the file only has to list the options it changes.
+func loadRunConfig(reader io.Reader, runConfig *RunConfig) error {
+	decoder := json.NewDecoder(reader)
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(runConfig)
+}
+
+func loadRunConfigFile(path string, runConfig *RunConfig) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return loadRunConfig(file, runConfig)
+}
+
+func (runConfig *RunConfig) dump(w io.Writer) error {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(runConfig); err != nil {
+		return err
+	}
+	_, err := buffer.WriteTo(w)
+	return err
+}
+
+// simulationConfig builds the config of the simulation without its event
+// log and observers, it loads the map the config points to.
+func (runConfig *RunConfig) simulationConfig() (travelers.Config, error) {
+	// travelers.Config takes a zero for the default, but the run config
+	// starts with the defaults, so a zero here was given by the user
+	switch {
+	case runConfig.DangerZoneDuration == 0:
+		return travelers.Config{}, errors.New("invalid value of danger_zone_duration - must be positive")
+	case runConfig.QueueSize == 0:
+		return travelers.Config{}, errors.New("invalid value of queue_size - must be positive")
+	case runConfig.Speed == 0:
+		return travelers.Config{}, errors.New("invalid value of speed - must be positive")
+	}
+
+	config := travelers.Config{
+		Width:        runConfig.Width,
+		Height:       runConfig.Height,
+		MaxTravelers: runConfig.MaxTravelers,
+		Probs: travelers.NodeProbs{
+			Spawn:  runConfig.SpawnProb,
+			Move:   runConfig.MoveProb,
+			Wild:   runConfig.WildProb,
+			Danger: runConfig.DangerProb,
+		},
+		Topology:           runConfig.Topology,
+		Routing:            runConfig.Routing,
+		Lifetime:           runConfig.Lifetime,
+		Species:            runConfig.Species,
+		DangerZoneDuration: runConfig.DangerZoneDuration,
+		DangerZoneShape:    runConfig.DangerZoneShape,
+		DangerZoneSpread:   runConfig.DangerZoneSpread,
+		DangerZonesKill:    runConfig.DangerZonesKill,
+		Scenario:           runConfig.Scenario,
+		QueueSize:          runConfig.QueueSize,
+		Seed:               time.Now().UnixNano(),
+		Deterministic:      runConfig.Seed != nil,
+		FastForward:        runConfig.FastForward,
+		Timings: travelers.Timings{
+			Spawn:    runConfig.SpawnTiming,
+			Think:    runConfig.ThinkTiming,
+			Patience: runConfig.PatienceTiming,
+			Camera:   runConfig.CameraTiming,
+		},
+		Speed:          runConfig.Speed,
+		Duration:       time.Duration(runConfig.Duration),
+		MaxPictures:    runConfig.Pictures,
+		Watchdog:       time.Duration(runConfig.Watchdog),
+		WatchdogAbort:  runConfig.WatchdogAbort,
+		WatchdogOutput: os.Stderr,
+	}
+
+	if runConfig.Seed != nil {
+		config.Seed = *runConfig.Seed
+	}
+
+	if runConfig.Map != "" {
+		walls, exits, err := loadMap(runConfig.Map)
+		if err != nil {
+			return config, fmt.Errorf("cannot load the map - %v", err)
+		}
+
+		config.Walls, config.Exits = walls, exits
+		config.Height, config.Width = len(walls), len(walls[0])
+	}
+
+	if runConfig.Exits != "" {
+		exits, err := travelers.ParseExits(runConfig.Exits, config.Width, config.Height)
+		if err != nil {
+			return config, err
+		}
+
+		for y := range exits {
+			for x := range exits[y] {
+				exits[y][x] = exits[y][x] || (config.Exits != nil && config.Exits[y][x])
+			}
+		}
+		config.Exits = exits
+	}
+
+	return config, config.Validate()
+}
+
+func loadMap(path string) ([][]bool, [][]bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	return travelers.LoadMap(file)
+}
+
+func loadSpecies(path string) ([]travelers.Species, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return travelers.LoadSpecies(file)
+}
+
+func loadScenario(path string) ([]travelers.ScheduledDangerZone, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return travelers.LoadScenario(file)
+}
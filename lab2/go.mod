@@ -2,4 +2,4 @@ module lab2
 
 go 1.21.2
 
-require github.com/akamensky/argparse v1.4.0 // indirect
+require github.com/akamensky/argparse v1.4.0
@@ -0,0 +1,479 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/akamensky/argparse"
+
+	"lab2/travelers"
+)
+
+// Type aliases
+
+type (
+	TravelerId         = travelers.TravelerId
+	Event              = travelers.Event
+	NodeTravelerStateE uint8
+)
+
+// Constants
+
+const ( // NodeTravelerStateE
+	nodeAvailable   NodeTravelerStateE = iota
+	nodeReservedIn  NodeTravelerStateE = iota
+	nodeReservedOut NodeTravelerStateE = iota
+	nodeOccupied    NodeTravelerStateE = iota
+)
+
+func (state NodeTravelerStateE) String() string {
+	switch state {
+	case nodeAvailable:
+		return "nodeAvailable"
+	case nodeReservedIn:
+		return "nodeReservedIn"
+	case nodeReservedOut:
+		return "nodeReservedOut"
+	case nodeOccupied:
+		return "nodeOccupied"
+	}
+	return "unknown"
+}
+
+// Structures - Event
+
+type Coordinates struct {
+	x int
+	y int
+}
+
+func eventC(event *Event) Coordinates {
+	return Coordinates{event.X, event.Y}
+}
+
+type Violation struct {
+	event   Event
+	message string
+}
+
+func (violation Violation) String() string {
+	event := violation.event
+	return fmt.Sprintf("seq %d [%s traveler %d at (%d,%d)]: %s",
+		event.Seq, event.Type, event.Traveler, event.X, event.Y, violation.message)
+}
+
+// Structures - Model
+
+type NodeModel struct {
+	travelerState NodeTravelerStateE
+	travelerId    TravelerId
+	dangerZone    bool
+	isWaiting     bool
+	// a danger zone started under the traveler, who must terminate next
+	isDoomed bool
+	blocked  bool
+	frozen   bool
+}
+
+func newNodeModel() *NodeModel {
+	return &NodeModel{
+		travelerState: nodeAvailable,
+		travelerId:    travelers.NullTraveler,
+	}
+}
+
+func (node *NodeModel) reset() {
+	node.travelerState = nodeAvailable
+	node.travelerId = travelers.NullTraveler
+	node.dangerZone = false
+	node.isWaiting = false
+	node.isDoomed = false
+}
+
+func (node *NodeModel) visibleTraveler() TravelerId {
+	if node.dangerZone ||
+		(node.travelerState != nodeOccupied && node.travelerState != nodeReservedOut) {
+		return travelers.NullTraveler
+	}
+	return node.travelerId
+}
+
+func (node *NodeModel) hasMovement() bool {
+	return node.travelerState == nodeReservedIn || node.travelerState == nodeReservedOut
+}
+
+type TravelerModel struct {
+	kind       string
+	occupied   map[Coordinates]bool
+	healthGone bool
+	terminated bool
+}
+
+// Verifier replays the events against the Node state machine of the
+// travelers simulation and collects every transition it would not allow.
+type Verifier struct {
+	nodes        map[Coordinates]*NodeModel
+	travelers    map[TravelerId]*TravelerModel
+	blockedNodes int
+	// travelers in the picture the camera is taking
+	pictured   map[TravelerId]Coordinates
+	violations []Violation
+}
+
+func newVerifier() *Verifier {
+	return &Verifier{
+		nodes:     make(map[Coordinates]*NodeModel),
+		travelers: make(map[TravelerId]*TravelerModel),
+		pictured:  make(map[TravelerId]Coordinates),
+	}
+}
+
+func (verifier *Verifier) node(c Coordinates) *NodeModel {
+	node, exists := verifier.nodes[c]
+	if !exists {
+		node = newNodeModel()
+		verifier.nodes[c] = node
+	}
+	return node
+}
+
+// traveler returns a new model for a spawn of an id given back by
+// a traveler who is gone.
+func (verifier *Verifier) traveler(event *Event) *TravelerModel {
+	traveler, exists := verifier.travelers[event.Traveler]
+	if !exists || (event.Type == travelers.EventSpawn && traveler.terminated) {
+		traveler = &TravelerModel{
+			kind:     event.Kind,
+			occupied: make(map[Coordinates]bool),
+		}
+		verifier.travelers[event.Traveler] = traveler
+	}
+	return traveler
+}
+
+func (verifier *Verifier) report(event *Event, format string, args ...any) {
+	verifier.violations = append(verifier.violations, Violation{
+		event:   *event,
+		message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (verifier *Verifier) expectState(
+	event *Event, node *NodeModel, state NodeTravelerStateE, message string,
+) bool {
+	if node.travelerState == state && node.travelerId == event.Traveler {
+		return true
+	}
+
+	verifier.report(event, "%s (node is %s held by traveler %d)",
+		message, node.travelerState, node.travelerId)
+	return false
+}
+
+func (verifier *Verifier) apply(event *Event) {
+	switch event.Type {
+	case travelers.EventDangerZoneStart, travelers.EventDangerZoneExpire:
+		verifier.applyDangerZoneEvent(event)
+		return
+
+	case travelers.EventCameraBlock, travelers.EventCameraSnapshot, travelers.EventCameraRelease:
+		verifier.applyCameraEvent(event)
+		return
+	}
+
+	node := verifier.node(eventC(event))
+	traveler := verifier.traveler(event)
+
+	// a traveler terminated while entering a node still frees the node it left
+	// and a doomed one still gives back the node it reserved
+	if traveler.terminated &&
+		!(event.Type == travelers.EventReleaseFinal && traveler.occupied[eventC(event)]) &&
+		!(event.Type == travelers.EventCancel && node.travelerState == nodeReservedIn &&
+			node.travelerId == event.Traveler) {
+		verifier.report(event, "traveler is active after it was terminated")
+	}
+	if event.Kind != traveler.kind {
+		verifier.report(event, "traveler of species %q changed it to %q", traveler.kind, event.Kind)
+	}
+	// a node may still ask a dying traveler to make room, it just never does
+	if traveler.healthGone && event.Type != travelers.EventReleaseOut &&
+		event.Type != travelers.EventReleaseFinal && event.Type != travelers.EventTerminate &&
+		event.Type != travelers.EventDisplace {
+		verifier.report(event, "traveler is active after its hp reached zero")
+	}
+	// the traveler who expired has released its node before it terminates
+	if node.frozen && event.Type != travelers.EventHealth && event.Type != travelers.EventUnlock &&
+		!(event.Type == travelers.EventTerminate && traveler.healthGone) {
+		verifier.report(event, "node changed after the camera took its snapshot")
+	} else if node.blocked && (event.Type == travelers.EventSpawn ||
+		event.Type == travelers.EventReserve || event.Type == travelers.EventDisplace ||
+		event.Type == travelers.EventLeave) {
+		verifier.report(event, "move started on a node blocked by the camera")
+	}
+
+	switch event.Type {
+	case travelers.EventSpawn:
+		if node.travelerState != nodeAvailable || node.dangerZone {
+			verifier.report(event, "traveler spawned on a node that is not empty "+
+				"(node is %s held by traveler %d)", node.travelerState, node.travelerId)
+		}
+		node.travelerState = nodeOccupied
+		node.travelerId = event.Traveler
+		verifier.occupy(event, traveler)
+
+	case travelers.EventReserve:
+		if node.travelerState != nodeAvailable {
+			verifier.report(event, "two travelers on one node "+
+				"(node is %s held by traveler %d)", node.travelerState, node.travelerId)
+		}
+		node.travelerState = nodeReservedIn
+		node.travelerId = event.Traveler
+
+	case travelers.EventCancel:
+		verifier.expectState(event, node, nodeReservedIn, "cancelled a node it never reserved")
+		node.travelerState = nodeAvailable
+		node.travelerId = travelers.NullTraveler
+
+	case travelers.EventAssign:
+		verifier.expectState(event, node, nodeReservedIn, "assigned to a node it never reserved")
+		if node.dangerZone {
+			verifier.report(event, "entered an active danger zone without being terminated")
+		}
+		node.travelerState = nodeOccupied
+		node.travelerId = event.Traveler
+		verifier.occupy(event, traveler)
+
+	case travelers.EventReleaseOut:
+		verifier.expectState(event, node, nodeOccupied, "release without a prior occupy")
+		if node.isDoomed {
+			verifier.report(event, "doomed traveler moved out of a danger zone")
+		}
+		node.travelerState = nodeReservedOut
+		node.travelerId = event.Traveler
+
+	case travelers.EventReleaseFinal:
+		verifier.expectState(event, node, nodeReservedOut, "release without a prior occupy")
+		node.reset()
+		delete(traveler.occupied, eventC(event))
+
+	case travelers.EventDisplace:
+		verifier.expectState(event, node, nodeOccupied, "displaced a traveler from another node")
+		if node.isDoomed {
+			verifier.report(event, "displaced a doomed traveler")
+		}
+		node.isWaiting = true
+
+	case travelers.EventUnlock:
+		if !node.isWaiting {
+			verifier.report(event, "unlocked a node that was not waiting")
+		}
+		node.isWaiting = false
+
+	case travelers.EventHealth:
+		if event.Health == nil {
+			verifier.report(event, "health event without hp")
+		} else if *event.Health == 0 {
+			traveler.healthGone = true
+		}
+
+	case travelers.EventTerminate:
+		verifier.applyTerminateEvent(event, node, traveler)
+
+	case travelers.EventLeave:
+		verifier.expectState(event, node, nodeOccupied, "left the card from a node it does not occupy")
+		if node.isDoomed {
+			verifier.report(event, "doomed traveler left the card")
+		}
+		traveler.terminated = true
+		node.reset()
+		delete(traveler.occupied, eventC(event))
+
+	default:
+		verifier.report(event, "unknown event type")
+	}
+}
+
+func (verifier *Verifier) applyTerminateEvent(
+	event *Event, node *NodeModel, traveler *TravelerModel,
+) {
+	traveler.terminated = true
+
+	// the danger zone stays on the node of a doomed traveler
+	if node.isDoomed && node.travelerId == event.Traveler {
+		verifier.expectState(event, node, nodeOccupied, "doomed traveler terminated outside of its node")
+		if !node.dangerZone {
+			verifier.report(event, "doomed traveler terminated without an active danger zone")
+		}
+		node.travelerState = nodeAvailable
+		node.travelerId = travelers.NullTraveler
+		node.isWaiting = false
+		node.isDoomed = false
+		delete(traveler.occupied, eventC(event))
+		return
+	}
+
+	if traveler.healthGone {
+		// the traveler has already released its node
+		if node.travelerId == event.Traveler {
+			verifier.report(event, "traveler terminated without releasing its node")
+		}
+		return
+	}
+
+	verifier.expectState(event, node, nodeReservedIn, "terminated outside of a move")
+	if !node.dangerZone {
+		verifier.report(event, "terminated on a node without an active danger zone")
+	}
+	node.travelerState = nodeAvailable
+	node.travelerId = travelers.NullTraveler
+	node.isWaiting = false
+}
+
+func (verifier *Verifier) applyDangerZoneEvent(event *Event) {
+	node := verifier.node(eventC(event))
+
+	switch event.Type {
+	case travelers.EventDangerZoneStart:
+		if node.blocked {
+			verifier.report(event, "danger zone started on a node blocked by the camera")
+		}
+		// a danger zone which kills the traveler standing on the node dooms it
+		if node.travelerState == nodeOccupied {
+			node.isDoomed = true
+		} else if node.travelerState != nodeAvailable {
+			verifier.report(event, "danger zone started on a node that is not empty")
+		}
+		if node.dangerZone {
+			verifier.report(event, "danger zone started on an active danger zone")
+		}
+		node.dangerZone = true
+
+	case travelers.EventDangerZoneExpire:
+		if node.frozen {
+			verifier.report(event, "node changed after the camera took its snapshot")
+		}
+		if !node.dangerZone {
+			verifier.report(event, "expired a danger zone that is not active")
+		}
+		if node.isDoomed {
+			verifier.report(event, "danger zone expired before its doomed traveler terminated")
+		}
+		node.dangerZone = false
+	}
+}
+
+func (verifier *Verifier) applyCameraEvent(event *Event) {
+	node := verifier.node(eventC(event))
+
+	switch event.Type {
+	case travelers.EventCameraBlock:
+		if node.blocked {
+			verifier.report(event, "camera blocked a node twice")
+		}
+		node.blocked = true
+		verifier.blockedNodes++
+
+	case travelers.EventCameraSnapshot:
+		if !node.blocked || node.frozen {
+			verifier.report(event, "camera took a snapshot of a node it did not block")
+		}
+		if node.hasMovement() {
+			verifier.report(event, "camera took a snapshot in the middle of a move")
+		}
+		if visible := node.visibleTraveler(); visible != event.Traveler {
+			verifier.report(event, "camera saw traveler %d instead of %d", event.Traveler, visible)
+		}
+		if c, exists := verifier.pictured[event.Traveler]; exists {
+			verifier.report(event, "traveler is in the picture twice, also at (%d,%d)", c.x, c.y)
+		}
+		if event.Traveler != travelers.NullTraveler {
+			verifier.pictured[event.Traveler] = eventC(event)
+		}
+		node.frozen = true
+
+	case travelers.EventCameraRelease:
+		if !node.blocked {
+			verifier.report(event, "camera released a node it did not block")
+		} else {
+			verifier.blockedNodes--
+		}
+		node.blocked = false
+		node.frozen = false
+
+		if verifier.blockedNodes == 0 {
+			verifier.pictured = make(map[TravelerId]Coordinates)
+		}
+	}
+}
+
+func (verifier *Verifier) occupy(event *Event, traveler *TravelerModel) {
+	for c := range traveler.occupied {
+		if c != eventC(event) && verifier.node(c).travelerState == nodeOccupied &&
+			verifier.node(c).travelerId == event.Traveler {
+			verifier.report(event, "traveler occupies two nodes, also (%d,%d)", c.x, c.y)
+		}
+	}
+	traveler.occupied[eventC(event)] = true
+}
+
+func (verifier *Verifier) verify(reader io.Reader) error {
+	scanner := bufio.NewScanner(reader)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		verifier.apply(&event)
+	}
+	return scanner.Err()
+}
+
+// main
+
+func main() {
+	parser := argparse.NewParser(
+		"travelers-verify", "Offline invariant checker for recorded travelers runs")
+
+	eventLogPath := parser.StringPositional(&argparse.Options{
+		Help: "Event log written with --event-log (stdin if omitted)",
+	})
+
+	if err := parser.Parse(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, parser.Usage(err))
+		os.Exit(2)
+	}
+
+	input := os.Stdin
+	if *eventLogPath != "" {
+		file, err := os.Open(*eventLogPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error: Cannot open the event log -", err.Error())
+			os.Exit(2)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	verifier := newVerifier()
+	if err := verifier.verify(input); err != nil {
+		fmt.Fprintln(os.Stderr, "Error: Invalid event log -", err.Error())
+		os.Exit(2)
+	}
+
+	for _, violation := range verifier.violations {
+		fmt.Println(violation)
+	}
+
+	fmt.Printf("%d violation(s), %d traveler(s), %d node(s)\n",
+		len(verifier.violations), len(verifier.travelers), len(verifier.nodes))
+	if len(verifier.violations) > 0 {
+		os.Exit(1)
+	}
+}
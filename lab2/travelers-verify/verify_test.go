@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"lab2/travelers"
+)
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name       string
+		events     []string
+		violations []string
+	}{
+		{"clean run", []string{
+			`{"event": "spawn", "traveler": 1, "kind": "normal", "x": 0, "y": 0}`,
+			`{"event": "reserve", "traveler": 1, "kind": "normal", "x": 1, "y": 0}`,
+			`{"event": "release.1", "traveler": 1, "kind": "normal", "x": 0, "y": 0}`,
+			`{"event": "assign", "traveler": 1, "kind": "normal", "x": 1, "y": 0}`,
+			`{"event": "release.2", "traveler": 1, "kind": "normal", "x": 0, "y": 0}`,
+			`{"event": "danger-zone.start", "traveler": -1, "x": 0, "y": 0}`,
+			`{"event": "camera.block", "traveler": -1, "x": 1, "y": 0}`,
+			`{"event": "camera.snapshot", "traveler": 1, "x": 1, "y": 0}`,
+			`{"event": "camera.release", "traveler": -1, "x": 1, "y": 0}`,
+			`{"event": "danger-zone.expire", "traveler": -1, "x": 0, "y": 0}`,
+			`{"event": "leave", "traveler": 1, "kind": "normal", "x": 1, "y": 0}`,
+			``,
+			`{"event": "spawn", "traveler": 1, "kind": "normal", "x": 0, "y": 0}`,
+		}, nil},
+		{"two travelers on one node", []string{
+			`{"event": "spawn", "traveler": 1, "kind": "normal", "x": 0, "y": 0}`,
+			`{"event": "spawn", "traveler": 2, "kind": "normal", "x": 1, "y": 0}`,
+			`{"event": "reserve", "traveler": 2, "kind": "normal", "x": 0, "y": 0}`,
+		}, []string{"two travelers on one node (node is nodeOccupied held by traveler 1)"}},
+		{"assign without reserve", []string{
+			`{"event": "spawn", "traveler": 1, "kind": "normal", "x": 0, "y": 0}`,
+			`{"event": "release.1", "traveler": 1, "kind": "normal", "x": 0, "y": 0}`,
+			`{"event": "assign", "traveler": 1, "kind": "normal", "x": 1, "y": 0}`,
+		}, []string{"assigned to a node it never reserved (node is nodeAvailable held by traveler -1)"}},
+		{"release without occupy", []string{
+			`{"event": "spawn", "traveler": 1, "kind": "normal", "x": 0, "y": 0}`,
+			`{"event": "release.1", "traveler": 1, "kind": "normal", "x": 1, "y": 0}`,
+		}, []string{"release without a prior occupy (node is nodeAvailable held by traveler -1)"}},
+		{"wild traveler alive at hp 0", []string{
+			`{"event": "spawn", "traveler": 5, "kind": "wild", "x": 0, "y": 0}`,
+			`{"event": "health", "traveler": 5, "kind": "wild", "x": 0, "y": 0, "hp": 0}`,
+			`{"event": "displace", "traveler": 5, "kind": "wild", "x": 0, "y": 0}`,
+			`{"event": "unlock", "traveler": 5, "kind": "wild", "x": 0, "y": 0}`,
+		}, []string{"traveler is active after its hp reached zero"}},
+		{"entering an active danger zone", []string{
+			`{"event": "spawn", "traveler": 1, "kind": "normal", "x": 0, "y": 0}`,
+			`{"event": "danger-zone.start", "traveler": -1, "x": 1, "y": 0}`,
+			`{"event": "reserve", "traveler": 1, "kind": "normal", "x": 1, "y": 0}`,
+			`{"event": "release.1", "traveler": 1, "kind": "normal", "x": 0, "y": 0}`,
+			`{"event": "assign", "traveler": 1, "kind": "normal", "x": 1, "y": 0}`,
+		}, []string{"entered an active danger zone without being terminated"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			verifier := newVerifier()
+			if err := verifier.verify(strings.NewReader(strings.Join(test.events, "\n"))); err != nil {
+				t.Fatal(err)
+			}
+
+			var violations []string
+			for _, violation := range verifier.violations {
+				violations = append(violations, violation.message)
+			}
+			if !slices.Equal(violations, test.violations) {
+				t.Errorf("violations %q, expected %q", violations, test.violations)
+			}
+		})
+	}
+}
+
+func TestVerifyInvalidEventLog(t *testing.T) {
+	events := `{"event": "spawn", "traveler": 1, "kind": "normal", "x": 0, "y": 0}` + "\n{\"event\":"
+	if err := newVerifier().verify(strings.NewReader(events)); err == nil ||
+		!strings.HasPrefix(err.Error(), "line 2:") {
+		t.Errorf("error %v, expected one on line 2", err)
+	}
+}
+
+// TestVerifySimulation checks the event log of a real run with travelers
+// moving, pushing each other and dying in danger zones.
+func TestVerifySimulation(t *testing.T) {
+	var eventLog bytes.Buffer
+	simulation, err := travelers.NewSimulation(travelers.Config{
+		Width:           6,
+		Height:          6,
+		MaxTravelers:    12,
+		Probs:           travelers.NodeProbs{Spawn: 0.3, Move: 0.9, Wild: 0.2, Danger: 0.1},
+		DangerZonesKill: true,
+		Seed:            1,
+		Deterministic:   true,
+		FastForward:     true,
+		Duration:        20 * time.Second,
+		EventLog:        &eventLog,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	simulation.Start()
+	<-simulation.Done()
+	simulation.Stop()
+
+	verifier := newVerifier()
+	if err := verifier.verify(&eventLog); err != nil {
+		t.Fatal(err)
+	}
+	if len(verifier.travelers) == 0 {
+		t.Fatal("no travelers in the event log")
+	}
+	for _, violation := range verifier.violations {
+		t.Error(violation)
+	}
+}
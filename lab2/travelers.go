@@ -2,960 +2,397 @@ package main
 
 import (
 	"fmt"
-	"math/rand"
 	"os"
-	"sync"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/akamensky/argparse"
-)
-
-// Type aliases
-
-type (
-	// General
-	TravelerId         int16
-	WildTravelerHealth uint8
-	DangerZone         int16
 
-	// State enums
-	NodeCameraStateE   uint8
-	NodeTravelerStateE uint8
-
-	// Request enums
-	NodeRequestE       uint8
-	NodeResponseE      uint8
-	TravelerIdRequestE uint8
+	"lab2/travelers"
 )
 
-func (dangerZone DangerZone) active() bool {
-	return dangerZone > -1
-}
-
-// constants
-
-const ( // general
-	bufferSize = 10
-
-	nullTraveler           TravelerId         = -1
-	initWildTravelerHealth WildTravelerHealth = 5
-
-	dangerZoneNotActive    DangerZone = -1
-	initDangerZoneDuration DangerZone = 3
-
-	dangerZoneMarker string = "##"
-	horizontalBlur   string = "--"
-	verticalBlur     string = "||"
-	noBlur           string = "  "
-)
-
-const ( // NodeCameraStateE
-	nodeRunning NodeCameraStateE = iota
-	nodeBlocekd NodeCameraStateE = iota
-)
-
-const ( // NodeTravelerStateE
-	nodeAvailable   NodeTravelerStateE = iota
-	nodeReservedIn  NodeTravelerStateE = iota
-	nodeReservedOut NodeTravelerStateE = iota
-	nodeOccupied    NodeTravelerStateE = iota
-)
-
-const ( // NodeRequestE
-	cameraBlockNode     NodeRequestE = iota
-	cameraReleaseNode   NodeRequestE = iota
-	travelerReserveNode NodeRequestE = iota
-	travelerAssignNode  NodeRequestE = iota
-	travelerReleaseNode NodeRequestE = iota
-	travelerUnlockNode  NodeRequestE = iota
-)
-
-func isCameraRequest(request NodeRequestE) bool {
-	return request == cameraBlockNode || request == cameraReleaseNode
-}
+const timingHelp = " - fixed:<duration>, uniform:<min>..<max> or exponential:<mean>"
 
-func isTravelerRequest(request NodeRequestE) bool {
-	return !isCameraRequest(request)
+// Arguments are the options of a run, checked before anything starts.
+type Arguments struct {
+	runConfig  RunConfig
+	config     travelers.Config
+	dumpConfig bool
+	renderer   travelers.Renderer
+	server     *travelers.Server
 }
 
-const ( // NodeResponseE
-	requestAccepted   NodeResponseE = iota
-	requestDenied     NodeResponseE = iota
-	requestSuspended  NodeResponseE = iota
-	terminateTraveler NodeResponseE = iota
-)
-
-const ( // TravelerIdRequestE
-	getId     TravelerIdRequestE = iota
-	getWildId TravelerIdRequestE = iota
-)
-
-// Global variables
-
-var waitGroup sync.WaitGroup
-
-const sleepDuration time.Duration = 2 * time.Second
-
-// Structures - general
-
-type Coordinates struct {
-	x int
-	y int
-}
+// parseArgs returns the parser along with the error so that the usage can
+// be printed with it.
+func parseArgs(args []string) (*argparse.Parser, Arguments, error) {
+	parser := argparse.NewParser("travelers", "Multithreaded traveler simulation")
+	defaults := defaultRunConfig()
 
-// Structures - TravelersCard
+	configPath := parser.String("", "config", &argparse.Options{
+		Help: "Read the options from a JSON file, the flags given override them",
+	})
+	dumpConfig := parser.Flag("", "dump-config", &argparse.Options{
+		Help: "Print the effective options as JSON and exit",
+	})
 
-type WildTravelerChannel chan Coordinates
+	height := parser.Int("H", "height", &argparse.Options{Default: defaults.Height})
+	width := parser.Int("W", "width", &argparse.Options{Default: defaults.Width})
+	maxTravelers := parser.Int("T", "max_travelers", &argparse.Options{Default: defaults.MaxTravelers})
+
+	travelerSpawnP := parser.Float("s", "spawn_prob", &argparse.Options{Default: defaults.SpawnProb})
+	travelerMoveP := parser.Float("m", "move_prob", &argparse.Options{Default: defaults.MoveProb})
+	travelerWildP := parser.Float("w", "wild_prob", &argparse.Options{Default: defaults.WildProb})
+	dangerP := parser.Float("d", "danger_prob", &argparse.Options{Default: defaults.DangerProb})
+	dangerZoneDuration := parser.Int("", "danger-zone-duration", &argparse.Options{
+		Default: defaults.DangerZoneDuration,
+		Help:    "Number of ticks of its node a danger zone lasts",
+	})
+	dangerZoneShape := parser.Selector("", "danger-zone-shape", travelers.DangerZoneShapeNames(), &argparse.Options{
+		Default: string(defaults.DangerZoneShape),
+		Help:    "Nodes a danger zone started by a node covers, its neighbours up to 1 (cross) or 2 (disc) moves away",
+	})
+	dangerZoneSpread := parser.Float("", "danger-zone-spread", &argparse.Options{
+		Default: defaults.DangerZoneSpread,
+		Help:    "Probability of a danger zone spreading to a random neighbour on a tick of its node",
+	})
+	dangerZonesKill := parser.Flag("", "danger-zones-kill", &argparse.Options{
+		Help: "Let the danger zones kill the travelers standing on the nodes they reach",
+	})
+	scenarioPath := parser.String("", "scenario", &argparse.Options{
+		Help: "Load the danger zones to start at given times from a JSON file",
+	})
+	queueSize := parser.Int("", "queue-size", &argparse.Options{
+		Default: defaults.QueueSize,
+		Help:    "Capacity of the request queues of the actors",
+	})
 
-type WildTravelerChannelMap map[TravelerId]WildTravelerChannel
+	seed := parser.Int("", "seed", &argparse.Options{
+		Help: "Replay a deterministic run for the given seed using a virtual clock",
+	})
+	fastForward := parser.Flag("", "fast-forward", &argparse.Options{
+		Help: "Run a deterministic run as fast as possible instead of in real time",
+	})
+	eventLogPath := parser.String("", "event-log", &argparse.Options{
+		Help: "Write the simulation events as JSON Lines to the given file",
+	})
 
-type TravelersCard struct {
-	height                 int
-	width                  int
-	travelerIdManager      *TravelerIdManager
-	grid                   [][]*Node
-	wildTravelerChannelMap WildTravelerChannelMap
-}
+	speed := parser.Float("", "speed", &argparse.Options{
+		Default: defaults.Speed,
+		Help:    "Run all the actors the given number of times faster",
+	})
+	spawnTimingStr := parser.String("", "spawn-timing", &argparse.Options{
+		Default: defaults.SpawnTiming.String(),
+		Help:    "Time between the ticks of the nodes" + timingHelp,
+	})
+	thinkTimingStr := parser.String("", "think-timing", &argparse.Options{
+		Default: defaults.ThinkTiming.String(),
+		Help:    "Time between the moves of a traveler" + timingHelp,
+	})
+	patienceTimingStr := parser.String("", "patience-timing", &argparse.Options{
+		Default: defaults.PatienceTiming.String(),
+		Help:    "Time between the ticks of a traveler who stands still" + timingHelp,
+	})
+	cameraTimingStr := parser.String("", "camera-timing", &argparse.Options{
+		Default: defaults.CameraTiming.String(),
+		Help:    "Time between the pictures" + timingHelp,
+	})
 
-func newTravelersCard(
-	width int, height int, travlerIdManager *TravelerIdManager,
-) *TravelersCard {
-	grid := make([][]*Node, height)
-	for y := range grid {
-		grid[y] = make([]*Node, width)
-		for x := range grid[y] {
-			grid[y][x] = newNode(Coordinates{x, y})
-		}
-	}
+	durationStr := parser.String("", "duration", &argparse.Options{
+		Help: "Stop the simulation after the given duration (e.g. 30s)",
+	})
+	maxPictures := parser.Int("", "pictures", &argparse.Options{
+		Help: "Stop the simulation after the given number of pictures",
+	})
+	statisticsPath := parser.String("", "stats", &argparse.Options{
+		Help: "Write the end-of-run statistics as JSON to the given file",
+	})
+	watchdogStr := parser.String("", "watchdog", &argparse.Options{
+		Help: "Report the travelers waiting for a node for longer than the given duration " +
+			"and the wait-for cycles to stderr",
+	})
+	watchdogAbort := parser.Flag("", "watchdog-abort", &argparse.Options{
+		Help: "Abort the simulation after the first report of the watchdog",
+	})
+	topology := parser.Selector("", "topology", travelers.TopologyNames(), &argparse.Options{
+		Default: string(defaults.Topology),
+		Help:    "Topology of the card",
+	})
+	mapPath := parser.String("", "map", &argparse.Options{
+		Help: "Map of the card with a line per row, '.' for a free node, '#' for a wall " +
+			"and 'E' for an exit (overrides height and width)",
+	})
+	exitsStr := parser.String("", "exits", &argparse.Options{
+		Help: "Nodes where the travelers leave the card as x,y;x,y",
+	})
+	lifetime := parser.Int("", "lifetime", &argparse.Options{
+		Help: "Number of ticks after which a traveler leaves the card",
+	})
+	exportPath := parser.String("", "export", &argparse.Options{
+		Help: "Export the pictures as an animated .gif or an .svg filmstrip, of a card of at most 100x100",
+	})
+	routing := parser.Selector("", "routing", travelers.RoutingNames(), &argparse.Options{
+		Default: string(defaults.Routing),
+		Help:    "Routing of the travelers, goal sends them to random destinations along the shortest paths",
+	})
+	speciesPath := parser.String("", "species", &argparse.Options{
+		Help: "Load the species of the travelers from a JSON file " +
+			"(overrides max_travelers, the spawn, move and wild probabilities, routing and lifetime)",
+	})
+	serveAddress := parser.String("", "serve", &argparse.Options{
+		Help: "Serve a live view of the pictures in a browser on the given address (e.g. :8080, which only listens on localhost)",
+	})
+	controlAddress := parser.String("", "control", &argparse.Options{
+		Help: "Accept commands to spawn travelers, start danger zones, pause the run and change " +
+			"the probabilities on the given address (e.g. :8081, which only listens on localhost, " +
+			"or unix:/tmp/travelers.sock)",
+	})
+	rendererName := parser.Selector("", "render", travelers.RendererNames(), &argparse.Options{
+		Default: defaults.Render,
+		Help:    "Format of the pictures",
+	})
 
-	return &TravelersCard{
-		height:                 height,
-		width:                  width,
-		travelerIdManager:      travlerIdManager,
-		grid:                   grid,
-		wildTravelerChannelMap: make(WildTravelerChannelMap),
+	var arguments Arguments
+	if err := parser.Parse(args); err != nil {
+		return parser, arguments, err
 	}
-}
 
-func (card *TravelersCard) startNodes(probs *NodeProbs) {
-	defer waitGroup.Done()
-
-	for y := range card.grid {
-		for x := range card.grid[y] {
-			waitGroup.Add(1)
-			go card.grid[y][x].start(card, probs)
+	runConfig := defaults
+	if *configPath != "" {
+		if err := loadRunConfigFile(*configPath, &runConfig); err != nil {
+			return parser, arguments, fmt.Errorf("cannot load the config - %v", err)
 		}
 	}
-}
 
-func (card *TravelersCard) isWildTraveler(id TravelerId) bool {
-	return id != nullTraveler && id >= card.travelerIdManager.maxId
-}
-
-func (card *TravelersCard) getNewPosition(c Coordinates) Coordinates {
-	moves := []Coordinates{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
-	rand.Shuffle(len(moves), func(i, j int) {
-		moves[i], moves[j] = moves[j], moves[i]
-	})
-
-	for _, move := range moves {
-		x, y := c.x+move.x, c.y+move.y
-		if x >= 0 && x < card.width && y >= 0 && y < card.height {
-			return Coordinates{x, y}
-		}
+	parseTiming := func(value string, timing *travelers.Timing) (err error) {
+		*timing, err = travelers.ParseTiming(value)
+		return err
 	}
-
-	return c
-}
-
-func (card *TravelersCard) display() {
-	for y := range card.grid {
-		verticalEdgeBlurStorage := make([]bool, card.width)
-		for i := range verticalEdgeBlurStorage {
-			verticalEdgeBlurStorage[i] = false
+	parseDuration := func(name string, value string, duration *travelers.Duration) error {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid value of %s - must be a duration (e.g. 30s)", name)
 		}
-
-		for x := range card.grid[y] {
-			request := newNodeCameraRequest(cameraBlockNode)
-			card.grid[y][x].requestChannel <- request
-			response := <-request.cameraResponse
-
-			for response.response != requestAccepted {
-				card.grid[y][x].requestChannel <- request
+		*duration = travelers.Duration(parsed)
+		return nil
+	}
+
+	// the flags given on the command line override the config file
+	overrides := map[string]func() error{
+		"height":               func() error { runConfig.Height = *height; return nil },
+		"width":                func() error { runConfig.Width = *width; return nil },
+		"max_travelers":        func() error { runConfig.MaxTravelers = *maxTravelers; return nil },
+		"spawn_prob":           func() error { runConfig.SpawnProb = *travelerSpawnP; return nil },
+		"move_prob":            func() error { runConfig.MoveProb = *travelerMoveP; return nil },
+		"wild_prob":            func() error { runConfig.WildProb = *travelerWildP; return nil },
+		"danger_prob":          func() error { runConfig.DangerProb = *dangerP; return nil },
+		"danger-zone-duration": func() error { runConfig.DangerZoneDuration = *dangerZoneDuration; return nil },
+		"danger-zone-spread":   func() error { runConfig.DangerZoneSpread = *dangerZoneSpread; return nil },
+		"danger-zones-kill":    func() error { runConfig.DangerZonesKill = *dangerZonesKill; return nil },
+		"queue-size":           func() error { runConfig.QueueSize = *queueSize; return nil },
+		"fast-forward":         func() error { runConfig.FastForward = *fastForward; return nil },
+		"speed":                func() error { runConfig.Speed = *speed; return nil },
+		"watchdog-abort":       func() error { runConfig.WatchdogAbort = *watchdogAbort; return nil },
+		"topology":             func() error { runConfig.Topology = travelers.TopologyE(*topology); return nil },
+		"map":                  func() error { runConfig.Map = *mapPath; return nil },
+		"exits":                func() error { runConfig.Exits = *exitsStr; return nil },
+		"routing":              func() error { runConfig.Routing = travelers.RoutingE(*routing); return nil },
+		"render":               func() error { runConfig.Render = *rendererName; return nil },
+		"event-log":            func() error { runConfig.EventLog = *eventLogPath; return nil },
+		"stats":                func() error { runConfig.Stats = *statisticsPath; return nil },
+		"export":               func() error { runConfig.Export = *exportPath; return nil },
+		"serve":                func() error { runConfig.Serve = *serveAddress; return nil },
+		"control":              func() error { runConfig.Control = *controlAddress; return nil },
+		"danger-zone-shape": func() error {
+			runConfig.DangerZoneShape = travelers.DangerZoneShapeE(*dangerZoneShape)
+			return nil
+		},
+		"seed": func() error {
+			runConfig.Seed = new(int64)
+			*runConfig.Seed = int64(*seed)
+			return nil
+		},
+		"lifetime": func() error {
+			if *lifetime < 0 {
+				return fmt.Errorf("invalid value of lifetime - must be non-negative")
 			}
-
-			if response.dangerZone {
-				fmt.Printf("[%s]", dangerZoneMarker)
-			} else if response.travelerId != nullTraveler {
-				if card.isWildTraveler(response.travelerId) {
-					fmt.Printf("[**]")
-				} else {
-					fmt.Printf("[%02d]", response.travelerId)
-				}
-			} else {
-				fmt.Printf("[  ]")
+			runConfig.Lifetime = uint(*lifetime)
+			return nil
+		},
+		"pictures": func() error {
+			if *maxPictures < 0 {
+				return fmt.Errorf("invalid value of pictures - must be non-negative")
 			}
-
-			if response.horizontalEdgeBlur {
-				fmt.Printf("%s", horizontalBlur)
-			} else {
-				fmt.Printf("%s", noBlur)
+			runConfig.Pictures = uint(*maxPictures)
+			return nil
+		},
+		"spawn-timing":    func() error { return parseTiming(*spawnTimingStr, &runConfig.SpawnTiming) },
+		"think-timing":    func() error { return parseTiming(*thinkTimingStr, &runConfig.ThinkTiming) },
+		"patience-timing": func() error { return parseTiming(*patienceTimingStr, &runConfig.PatienceTiming) },
+		"camera-timing":   func() error { return parseTiming(*cameraTimingStr, &runConfig.CameraTiming) },
+		"duration":        func() error { return parseDuration("duration", *durationStr, &runConfig.Duration) },
+		"watchdog":        func() error { return parseDuration("watchdog", *watchdogStr, &runConfig.Watchdog) },
+		"scenario": func() (err error) {
+			if runConfig.Scenario, err = loadScenario(*scenarioPath); err != nil {
+				return fmt.Errorf("cannot load the scenario - %v", err)
 			}
-
-			verticalEdgeBlurStorage[x] = response.verticalEdgeBlur
-		}
-
-		fmt.Println()
-		for i := range verticalEdgeBlurStorage {
-			if verticalEdgeBlurStorage[i] {
-				fmt.Printf(" %s ", verticalBlur)
-			} else {
-				fmt.Printf(" %s ", noBlur)
+			return nil
+		},
+		"species": func() (err error) {
+			if runConfig.Species, err = loadSpecies(*speciesPath); err != nil {
+				return fmt.Errorf("cannot load the species - %v", err)
 			}
-			fmt.Printf("%s", noBlur)
-		}
-		fmt.Println()
-
-		for x := range card.grid[y] {
-			request := newNodeCameraRequest(cameraReleaseNode)
-			card.grid[y][x].requestChannel <- request
-			response := <-request.cameraResponse
+			return nil
+		},
+	}
 
-			for response.response != requestAccepted {
-				card.grid[y][x].requestChannel <- request
+	for _, arg := range parser.GetArgs() {
+		if override, exists := overrides[arg.GetLname()]; exists && arg.GetParsed() {
+			if err := override(); err != nil {
+				return parser, arguments, err
 			}
 		}
 	}
-}
-
-// Structures - Camera
-
-type Camera struct {
-	pictureCount uint
-	card         *TravelersCard
-}
 
-func newCamera(card *TravelersCard) *Camera {
-	return &Camera{
-		pictureCount: 0,
-		card:         card,
+	config, err := runConfig.simulationConfig()
+	if err != nil {
+		return parser, arguments, err
 	}
-}
-
-func (camera *Camera) start() {
-	defer waitGroup.Done()
-
-	for {
-		camera.pictureCount++
 
-		// fmt.Print("\033[H\033[2J") // clear console
-		fmt.Printf("Picture: %d\n", camera.pictureCount)
-		camera.card.display()
-
-		time.Sleep(sleepDuration)
+	renderer, err := travelers.NewRenderer(runConfig.Render)
+	if err != nil {
+		return parser, arguments, err
 	}
-}
-
-// Structures - Traveler
-
-type Traveler struct {
-	id TravelerId
-	c  Coordinates
-}
-
-func (traveler *Traveler) start(card *TravelersCard, moveProb float64) {
-	defer waitGroup.Done()
-
-	for {
-		time.Sleep(sleepDuration)
 
-		if rand.Float64() > moveProb {
-			continue
-		}
-
-		terminate := traveler.move(card)
-		if terminate {
-			return
+	// the file is only created once the run starts
+	if runConfig.Export != "" {
+		if err := travelers.ValidateExport(runConfig.Export, config.Width, config.Height); err != nil {
+			return parser, arguments, err
 		}
 	}
-}
-
-func (traveler *Traveler) move(card *TravelersCard) bool {
-	terminate := false
-
-	newC := card.getNewPosition(traveler.c)
-	currNode := card.grid[traveler.c.y][traveler.c.x]
-	newNode := card.grid[newC.y][newC.x]
 
-	reserveRequest := newNodeTravelerRequest(travelerReserveNode, traveler.id, traveler.c)
-	for {
-		newNode.requestChannel <- reserveRequest
-		response := <-reserveRequest.travelerResponse
-		if response == requestSuspended {
-			continue
-		}
-		if response == requestAccepted {
-			break
-		}
-		return terminate
+	var server *travelers.Server
+	if runConfig.Serve != "" {
+		server = travelers.NewServer(runConfig.Serve)
 	}
 
-	releaseRequest := newNodeTravelerRequest(travelerReleaseNode, traveler.id, newC)
-	for {
-		currNode.requestChannel <- releaseRequest
-		response := <-releaseRequest.travelerResponse
-		if response == requestAccepted {
-			break
-		}
-	}
-
-	assignRequest := newNodeTravelerRequest(travelerAssignNode, traveler.id, traveler.c)
-	for {
-		newNode.requestChannel <- assignRequest
-		response := <-assignRequest.travelerResponse
-
-		if response == terminateTraveler {
-			terminate = true
-			break
-		} else if response == requestAccepted {
-			traveler.c = newC
-			break
-		}
-	}
-
-	for {
-		currNode.requestChannel <- releaseRequest
-		response := <-releaseRequest.travelerResponse
-		if response == requestAccepted {
-			break
-		}
-	}
-
-	return terminate
+	return parser, Arguments{
+		runConfig:  runConfig,
+		config:     config,
+		dumpConfig: *dumpConfig,
+		renderer:   renderer,
+		server:     server,
+	}, nil
 }
 
-type WildTraveler struct {
-	id TravelerId
-	c  Coordinates
-	hp WildTravelerHealth
-}
-
-func newWildTraveler(id TravelerId, c Coordinates) WildTraveler {
-	return WildTraveler{
-		id: id,
-		c:  c,
-		hp: initWildTravelerHealth,
+func main() {
+	parser, arguments, err := parseArgs(os.Args)
+	if err != nil {
+		fmt.Fprint(os.Stderr, parser.Usage("Error: "+err.Error()))
+		os.Exit(2)
 	}
-}
-
-func (wildTraveler *WildTraveler) alive() bool {
-	return wildTraveler.hp > 0
-}
-
-func (wildTraveler *WildTraveler) start(card *TravelersCard) {
-	defer waitGroup.Done()
-
-	fmt.Fprintf(os.Stderr, "WT %d start! (%d,%d)\n",
-		wildTraveler.id, wildTraveler.c.x, wildTraveler.c.y)
 
-	for {
-		time.Sleep(sleepDuration)
-
-		select {
-		case c := <-card.wildTravelerChannelMap[wildTraveler.id]:
-			if c != wildTraveler.c {
-				wildTraveler.unlockNode(card, c)
-				continue
-			}
-
-			fmt.Fprintf(os.Stderr, "WT %d move?\n", wildTraveler.id)
-			moved := false
-			terminate := false
-
-			possibleMoves := []Coordinates{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
-			for _, move := range possibleMoves {
-				x, y := wildTraveler.c.x+move.x, wildTraveler.c.y+move.y
-				if x < 0 || x >= card.width || y < 0 || y >= card.height {
-					fmt.Fprintf(os.Stderr, "WT %d skip (%d,%d)\n", wildTraveler.id, x, y)
-					continue
-				}
-
-				fmt.Fprintf(os.Stderr, "WT %d move (%d,%d)?\n", wildTraveler.id, x, y)
-				moved, terminate = wildTraveler.move(Coordinates{x, y}, card)
-				if moved {
-					fmt.Fprintf(os.Stderr, "WT %d move [ok] (%d,%d)?\n", wildTraveler.id, x, y)
-					break
-				}
-				if terminate {
-					fmt.Fprintf(os.Stderr, "WT %d move [term] (%d,%d)?\n", wildTraveler.id, x, y)
-					break
-				}
-			}
-
-			if !moved {
-				wildTraveler.unlockNode(card, wildTraveler.c)
-			}
-			if terminate {
-				delete(card.wildTravelerChannelMap, wildTraveler.id)
-				return
-			}
-
-		default:
-			wildTraveler.hp--
-			if !wildTraveler.alive() {
-				wildTraveler.terminate(card)
-				delete(card.wildTravelerChannelMap, wildTraveler.id)
-				return
-			}
+	runConfig, config := arguments.runConfig, arguments.config
+	if arguments.dumpConfig {
+		if err := runConfig.dump(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "Error: Cannot print the config -", err.Error())
+			os.Exit(1)
 		}
+		return
 	}
-}
-
-func (wildTraveler *WildTraveler) move(newC Coordinates, card *TravelersCard) (bool, bool) {
-	terminate := false
-
-	currNode := card.grid[wildTraveler.c.y][wildTraveler.c.x]
-	newNode := card.grid[newC.y][newC.x]
 
-	reserveRequest := newNodeTravelerRequest(travelerReserveNode, wildTraveler.id, wildTraveler.c)
-	newNode.requestChannel <- reserveRequest
-	response := <-reserveRequest.travelerResponse
-	if response != requestAccepted {
-		return false, terminate
+	config.Observers = []travelers.PictureObserver{
+		travelers.NewRendererObserver(arguments.renderer, os.Stdout),
 	}
-
-	fmt.Fprintf(os.Stderr, "WT %d move [reserve] (%d,%d)\n", wildTraveler.id, newC.x, newC.y)
-
-	releaseRequest := newNodeTravelerRequest(travelerReleaseNode, wildTraveler.id, newC)
-	for {
-		currNode.requestChannel <- releaseRequest
-		response = <-releaseRequest.travelerResponse
-		if response == requestAccepted {
-			break
+	var exporter *travelers.Exporter
+	if runConfig.Export != "" {
+		if exporter, err = travelers.NewExporter(runConfig.Export); err != nil {
+			fmt.Fprintln(os.Stderr, "Error: Cannot export the pictures -", err.Error())
+			os.Exit(1)
 		}
+		config.Observers = append(config.Observers, exporter)
 	}
-
-	fmt.Fprintf(os.Stderr, "WT %d move [release.1] (%d,%d)\n",
-		wildTraveler.id, wildTraveler.c.x, wildTraveler.c.y)
-
-	assignRequest := newNodeTravelerRequest(travelerAssignNode, wildTraveler.id, wildTraveler.c)
-	for {
-		newNode.requestChannel <- assignRequest
-		response = <-assignRequest.travelerResponse
-
-		if response == terminateTraveler {
-			terminate = true
-			break
+	if server := arguments.server; server != nil {
+		address, err := server.Start()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error: Cannot serve the live view -", err.Error())
+			os.Exit(1)
 		}
-		if response == requestAccepted {
-			wildTraveler.c = newC
-			break
-		}
-	}
+		defer server.Close()
 
-	fmt.Fprintf(os.Stderr, "WT %d move [assign] (%d,%d)\n", wildTraveler.id, newC.x, newC.y)
-
-	for {
-		currNode.requestChannel <- releaseRequest
-		response = <-releaseRequest.travelerResponse
-		if response == requestAccepted {
-			break
-		}
+		fmt.Fprintf(os.Stderr, "Serving the live view on http://%s\n", address)
+		config.Observers = append(config.Observers, server)
 	}
 
-	fmt.Fprintf(os.Stderr, "WT %d move [release.2] (%d,%d)\n",
-		wildTraveler.id, wildTraveler.c.x, wildTraveler.c.y)
-
-	return true, terminate
-}
-
-func (wildTraveler *WildTraveler) unlockNode(card *TravelersCard, c Coordinates) {
-	unlockRequest := newNodeTravelerRequest(
-		travelerUnlockNode, wildTraveler.id, wildTraveler.c)
-	currNode := card.grid[c.y][c.x]
-	for {
-		currNode.requestChannel <- unlockRequest
-		response := <-unlockRequest.travelerResponse
-		if response == requestAccepted {
-			break
-		}
-	}
-	fmt.Fprintf(os.Stderr, "WT %d unlock (%d,%d)\n", wildTraveler.id, c.x, c.y)
-}
-
-func (wildTraveler *WildTraveler) terminate(card *TravelersCard) {
-	currNode := card.grid[wildTraveler.c.y][wildTraveler.c.x]
-	releaseRequest := newNodeTravelerRequest(
-		travelerReleaseNode, wildTraveler.id, wildTraveler.c)
-
-	finalRelease := false
-
-	for {
-		currNode.requestChannel <- releaseRequest
-		response := <-releaseRequest.travelerResponse
-		if response == requestAccepted {
-			if !finalRelease {
-				finalRelease = true
-				continue
-			}
-			return
+	if runConfig.EventLog != "" {
+		eventLogFile, err := os.Create(runConfig.EventLog)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error: Cannot create the event log -", err.Error())
+			os.Exit(1)
 		}
-	}
-}
-
-// Structures - TravelerIdManager
-
-type TravelerIdChannel chan TravelerId
-
-type TravelerIdRequest struct {
-	request  TravelerIdRequestE
-	response TravelerIdChannel
-}
-
-func newTravelerIdRequest(request TravelerIdRequestE) TravelerIdRequest {
-	return TravelerIdRequest{
-		request:  request,
-		response: make(TravelerIdChannel, bufferSize),
-	}
-}
+		defer eventLogFile.Close()
 
-type TravelerIdRequestChannel chan TravelerIdRequest
-
-type TravelerIdManager struct {
-	nextId     TravelerId
-	maxId      TravelerId
-	nextWildId TravelerId
-	maxWildId  TravelerId
-	channel    TravelerIdRequestChannel
-}
-
-func newTravelerIdManager(maxTravelers TravelerId) *TravelerIdManager {
-	return &TravelerIdManager{
-		nextId:     0,
-		maxId:      maxTravelers,
-		nextWildId: 0,
-		maxWildId:  100,
-		channel:    make(TravelerIdRequestChannel, bufferSize),
+		config.EventLog = eventLogFile
 	}
-}
-
-func (travelerIdManager *TravelerIdManager) start() {
-	defer waitGroup.Done()
-
-	for request := range travelerIdManager.channel {
-		switch request.request {
-		case getId:
-			if travelerIdManager.nextId < travelerIdManager.maxId {
-				request.response <- travelerIdManager.nextId
-				travelerIdManager.nextId++
-			} else {
-				request.response <- nullTraveler
-			}
 
-		case getWildId:
-			request.response <- travelerIdManager.nextWildId + travelerIdManager.maxId
-			travelerIdManager.nextWildId++
-			travelerIdManager.nextWildId %= travelerIdManager.maxWildId
+	simulation, err := travelers.NewSimulation(config)
+	if err != nil {
+		if exporter != nil {
+			exporter.Close()
 		}
+		fmt.Fprintln(os.Stderr, "Error:", err.Error())
+		os.Exit(1)
 	}
-}
-
-// Structures - Node::Requests
-
-type NodeRequest struct {
-	request          NodeRequestE
-	travelerData     NodeTravelerRequestData
-	cameraResponse   NodeCameraResponseChannel
-	travelerResponse NodeTravelerResponseChannel
-}
-
-func newNodeCameraRequest(request NodeRequestE) NodeRequest {
-	return NodeRequest{
-		request:        request,
-		cameraResponse: make(NodeCameraResponseChannel, bufferSize),
-	}
-}
-
-func newNodeTravelerRequest(request NodeRequestE, id TravelerId, c Coordinates) NodeRequest {
-	return NodeRequest{
-		request: request,
-		travelerData: NodeTravelerRequestData{
-			id: id,
-			c:  c,
-		},
-		travelerResponse: make(NodeTravelerResponseChannel, bufferSize),
-	}
-}
-
-type NodeRequestChannel chan NodeRequest
-
-type NodeCameraResponse struct {
-	response           NodeResponseE
-	travelerId         TravelerId
-	dangerZone         bool
-	horizontalEdgeBlur bool
-	verticalEdgeBlur   bool
-}
-
-type NodeCameraResponseChannel chan NodeCameraResponse
-
-type NodeTravelerResponseChannel chan NodeResponseE
-
-type NodeTravelerRequestData struct {
-	id TravelerId
-	c  Coordinates
-}
-
-// Structures - Node
-
-type Node struct {
-	c                  Coordinates
-	dangerZone         DangerZone
-	cameraState        NodeCameraStateE
-	travelerState      NodeTravelerStateE
-	isWaiting          bool
-	travelerId         TravelerId
-	horizontalEdgeBlur bool
-	verticalEdgeBlur   bool
-	requestChannel     NodeRequestChannel
-}
-
-func newNode(c Coordinates) *Node {
-	return &Node{
-		c:                  c,
-		dangerZone:         dangerZoneNotActive,
-		cameraState:        nodeRunning,
-		travelerState:      nodeAvailable,
-		isWaiting:          false,
-		travelerId:         nullTraveler,
-		horizontalEdgeBlur: false,
-		verticalEdgeBlur:   false,
-		requestChannel:     make(NodeRequestChannel, bufferSize),
-	}
-}
-
-type NodeProbs struct {
-	spawn  float64
-	move   float64
-	wild   float64
-	danger float64
-}
-
-func (node *Node) start(
-	card *TravelersCard, probs *NodeProbs,
-) {
-	defer waitGroup.Done()
-
-	for {
-		select {
-		case request := <-node.requestChannel:
-			if isCameraRequest(request.request) {
-				node.handleCameraRequest(&request)
-			} else if isTravelerRequest(request.request) {
-				node.handleTravelerRequest(&request, card)
-			}
-
-		case <-time.After(sleepDuration):
-			if node.dangerZone.active() {
-				node.dangerZone--
-			}
-
-			if node.travelerState != nodeAvailable || node.dangerZone.active() {
-				continue
-			}
 
-			if rand.Float64() < probs.spawn {
-				travelerIdRequest := newTravelerIdRequest(getId)
-				card.travelerIdManager.channel <- travelerIdRequest
-
-				travelerId := <-travelerIdRequest.response
-				if travelerId == nullTraveler {
-					continue
-				}
-
-				node.travelerState = nodeOccupied
-				node.travelerId = travelerId
-
-				newTraveler := Traveler{travelerId, node.c}
-
-				waitGroup.Add(1)
-				go newTraveler.start(card, probs.move)
-				continue
-			}
-
-			if rand.Float64() < probs.wild {
-				travelerIdRequest := newTravelerIdRequest(getWildId)
-				card.travelerIdManager.channel <- travelerIdRequest
-
-				wildTravelerId := <-travelerIdRequest.response
-				card.wildTravelerChannelMap[wildTravelerId] = make(WildTravelerChannel, bufferSize)
-
-				newWildTraveler := newWildTraveler(wildTravelerId, node.c)
-
-				node.travelerState = nodeOccupied
-				node.travelerId = wildTravelerId
-
-				waitGroup.Add(1)
-				go newWildTraveler.start(card)
-				continue
-			}
-
-			if rand.Float64() < probs.danger {
-				node.dangerZone = initDangerZoneDuration
-			}
+	if runConfig.Control != "" {
+		controlServer := travelers.NewControlServer(runConfig.Control, simulation.Controller())
+		address, err := controlServer.Start()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error: Cannot accept the commands -", err.Error())
+			os.Exit(1)
 		}
-	}
-}
-
-func (node *Node) hasMovement() bool {
-	return node.travelerState == nodeReservedIn || node.travelerState == nodeReservedOut
-}
+		defer controlServer.Close()
 
-func (node *Node) hasTraveler() bool {
-	return node.travelerState == nodeOccupied || node.travelerState == nodeReservedOut
-}
-
-func (node *Node) reset() {
-	node.dangerZone = dangerZoneNotActive
-	node.travelerState = nodeAvailable
-	node.isWaiting = false
-	node.travelerId = nullTraveler
-}
-
-func (node *Node) handleCameraRequest(request *NodeRequest) {
-	switch request.request {
-	case cameraBlockNode:
-		node.handleCameraBlockRequest(request)
-
-	case cameraReleaseNode:
-		node.handleCameraReleaseRequest(request)
-
-	default:
-		request.cameraResponse <- NodeCameraResponse{response: requestDenied}
+		fmt.Fprintf(os.Stderr, "Accepting the commands on %s %s\n", address.Network(), address)
 	}
-}
 
-func (node *Node) handleCameraBlockRequest(request *NodeRequest) {
-	if node.hasMovement() {
-		request.cameraResponse <- NodeCameraResponse{response: requestDenied}
-		return
-	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
 
-	node.cameraState = nodeBlocekd
-	response := NodeCameraResponse{
-		response:           requestAccepted,
-		dangerZone:         node.dangerZone.active(),
-		horizontalEdgeBlur: node.horizontalEdgeBlur,
-		verticalEdgeBlur:   node.verticalEdgeBlur,
-	}
+	simulation.Start()
 
-	if node.dangerZone.active() || !node.hasTraveler() {
-		response.travelerId = nullTraveler
-	} else {
-		response.travelerId = node.travelerId
+	stop, aborted := simulation.Stop, false
+	select {
+	case <-signals:
+	case <-simulation.Done():
+	case <-simulation.Aborted():
+		// the event log and the statistics up to the abort help to find out why
+		fmt.Fprintln(os.Stderr, "Error: The watchdog aborted the simulation")
+		stop, aborted = simulation.Abort, true
 	}
 
-	node.horizontalEdgeBlur = false
-	node.verticalEdgeBlur = false
+	statistics, eventLogErr := stop()
+	statistics.Print(os.Stdout)
 
-	request.cameraResponse <- response
-}
-
-func (node *Node) handleCameraReleaseRequest(request *NodeRequest) {
-	if node.cameraState == nodeRunning {
-		request.cameraResponse <- NodeCameraResponse{response: requestDenied}
-		return
-	}
-
-	request.cameraResponse <- NodeCameraResponse{response: requestAccepted}
-	node.cameraState = nodeRunning
-}
-
-func (node *Node) handleTravelerRequest(request *NodeRequest, card *TravelersCard) {
-	if node.cameraState == nodeBlocekd {
-		if node.isWaiting {
-			request.travelerResponse <- requestSuspended
-		} else {
-			request.travelerResponse <- requestDenied
+	if exporter != nil {
+		if err := exporter.Close(); err != nil {
+			fmt.Fprintln(os.Stderr, "Error: Cannot export the pictures -", err.Error())
+			os.Exit(1)
 		}
-		return
-	}
-
-	switch request.request {
-	case travelerReserveNode:
-		node.handleTravelerReserveRequest(request, card)
-
-	case travelerAssignNode:
-		node.handleTravelerAssignRequest(request)
-
-	case travelerReleaseNode:
-		node.handleTravelerReleaseRequest(request)
-
-	case travelerUnlockNode:
-		if node.isWaiting {
-			request.travelerResponse <- requestAccepted
-			node.isWaiting = false
-		} else {
-			request.travelerResponse <- requestDenied
-		}
-
-	default:
-		request.travelerResponse <- requestDenied
-	}
-}
-
-func (node *Node) handleTravelerReserveRequest(request *NodeRequest, card *TravelersCard) {
-	if node.travelerState == nodeAvailable {
-		request.travelerResponse <- requestAccepted
-		node.travelerState = nodeReservedIn
-		node.travelerId = request.travelerData.id
-		return
-	}
-
-	if node.travelerState == nodeOccupied && !node.isWaiting &&
-		card.isWildTraveler(node.travelerId) && !card.isWildTraveler(request.travelerData.id) {
-
-		fmt.Fprintf(os.Stderr, "T %d move -> (%d,%d)?\n", request.travelerData.id, node.c.x, node.c.y)
-
-		if _, exists := card.wildTravelerChannelMap[node.travelerId]; !exists {
-			request.travelerResponse <- requestDenied
-			return
-		}
-
-		node.isWaiting = true
-		fmt.Fprintf(os.Stderr, "WT %d move! (%d,%d)\n", node.travelerId, node.c.x, node.c.y)
-		card.wildTravelerChannelMap[node.travelerId] <- node.c
-	}
-
-	if node.isWaiting {
-		request.travelerResponse <- requestSuspended
-	} else {
-		request.travelerResponse <- requestDenied
-	}
-}
-
-func (node *Node) handleTravelerAssignRequest(request *NodeRequest) {
-	if node.travelerState != nodeReservedIn ||
-		node.travelerId != request.travelerData.id {
-		request.travelerResponse <- requestDenied
-		return
-	}
-
-	if node.dangerZone.active() {
-		request.travelerResponse <- terminateTraveler
-		node.reset()
-		return
 	}
 
-	request.travelerResponse <- requestAccepted
-	node.travelerState = nodeOccupied
-
-	if node.c.y == request.travelerData.c.y &&
-		node.c.x == request.travelerData.c.x-1 {
-		node.horizontalEdgeBlur = true
-	} else if node.c.x == request.travelerData.c.x &&
-		node.c.y == request.travelerData.c.y-1 {
-		node.verticalEdgeBlur = true
-	}
-}
-
-func (node *Node) handleTravelerReleaseRequest(request *NodeRequest) {
-	switch node.travelerState {
-	case nodeOccupied:
-		if node.travelerId == request.travelerData.id {
-			request.travelerResponse <- requestAccepted
-			node.travelerState = nodeReservedOut
-		} else {
-			request.travelerResponse <- requestDenied
+	if runConfig.Stats != "" {
+		if err := statistics.WriteJSON(runConfig.Stats); err != nil {
+			fmt.Fprintln(os.Stderr, "Error: Cannot write the statistics -", err.Error())
+			os.Exit(1)
 		}
-
-	case nodeReservedOut:
-		if node.travelerId == request.travelerData.id {
-			request.travelerResponse <- requestAccepted
-			if node.c.y == request.travelerData.c.y &&
-				node.c.x == request.travelerData.c.x-1 {
-				node.horizontalEdgeBlur = true
-			} else if node.c.x == request.travelerData.c.x &&
-				node.c.y == request.travelerData.c.y-1 {
-				node.verticalEdgeBlur = true
-			}
-			node.reset()
-		} else {
-			request.travelerResponse <- requestDenied
-		}
-
-	case nodeReservedIn:
-		if node.travelerId == request.travelerData.id {
-			request.travelerResponse <- requestAccepted
-			node.travelerState = nodeAvailable
-			node.travelerId = nullTraveler
-		} else {
-			request.travelerResponse <- requestDenied
-		}
-
-	case nodeAvailable:
-		request.travelerResponse <- requestDenied
 	}
-}
 
-// main
-
-func main() {
-	parser := argparse.NewParser("travelers", "Multithreaded traveler simulation")
-
-	height := parser.Int("H", "height", &argparse.Options{Default: 4})
-	width := parser.Int("W", "width", &argparse.Options{Default: 6})
-	maxTravelers := parser.Int("T", "max_travelers", &argparse.Options{Default: 10})
-
-	travelerSpawnP := parser.Float("s", "spawn_prob", &argparse.Options{Default: 0.1})
-	travelerMoveP := parser.Float("m", "move_prob", &argparse.Options{Default: 0.5})
-	travelerWildP := parser.Float("w", "wild_prob", &argparse.Options{Default: 0.025})
-	dangerP := parser.Float("d", "danger_prob", &argparse.Options{Default: 0.025})
-
-	if err := parser.Parse(os.Args); err != nil {
-		fmt.Fprintln(os.Stderr, "Error: Invalid arguments!")
-		fmt.Fprintln(os.Stderr, err.Error())
-	}
-
-	const minSize, maxSize int = 1, 10
-
-	if *width < minSize || *width > maxSize {
-		fmt.Fprintln(os.Stderr, "Error: Invalid value of width - must be in range [1, 10]")
-		os.Exit(1)
-	}
-
-	if *height < minSize || *height > maxSize {
-		fmt.Fprintln(os.Stderr, "Error: Invalid value of height - must be in range [1, 10]")
+	if eventLogErr != nil {
+		fmt.Fprintln(os.Stderr, "Error:", eventLogErr.Error())
 		os.Exit(1)
 	}
-
-	if *maxTravelers < minSize || *maxTravelers > (*width**height) {
-		fmt.Fprintln(
-			os.Stderr,
-			"Error: Invalid value of max_travelers - must be in range [1, width * height]",
-		)
-		os.Exit(1)
+	if aborted {
+		os.Exit(2)
 	}
-
-	travelerIdManager := newTravelerIdManager(TravelerId(*maxTravelers))
-	card := newTravelersCard(*width, *height, travelerIdManager)
-	camera := newCamera(card)
-
-	waitGroup.Add(1)
-	go travelerIdManager.start()
-
-	waitGroup.Add(1)
-	go card.startNodes(
-		&NodeProbs{
-			spawn:  *travelerSpawnP,
-			move:   *travelerMoveP,
-			wild:   *travelerWildP,
-			danger: *dangerP,
-		},
-	)
-
-	waitGroup.Add(1)
-	go camera.start()
-
-	waitGroup.Wait()
 }
@@ -0,0 +1,168 @@
+package travelers
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"time"
+)
+
+// Structures - Picture
+
+// PictureCell describes a node and the edges it owns, the edge blurs tell
+// that a traveler crossed the edge to the right, below, to the lower right
+// and to the lower left of the node since the last picture. Kind and Glyph
+// are the name and the glyph of the species of the traveler.
+type PictureCell struct {
+	TravelerId           TravelerId `json:"traveler"`
+	Kind                 string     `json:"kind,omitempty"`
+	Glyph                string     `json:"glyph,omitempty"`
+	DangerZone           bool       `json:"danger_zone,omitempty"`
+	Wall                 bool       `json:"wall,omitempty"`
+	HorizontalEdgeBlur   bool       `json:"horizontal_blur,omitempty"`
+	VerticalEdgeBlur     bool       `json:"vertical_blur,omitempty"`
+	DiagonalEdgeBlur     bool       `json:"diagonal_blur,omitempty"`
+	AntiDiagonalEdgeBlur bool       `json:"anti_diagonal_blur,omitempty"`
+}
+
+func (cell PictureCell) Empty() bool {
+	return !cell.Wall && !cell.DangerZone && cell.TravelerId == NullTraveler
+}
+
+// Picture is an immutable snapshot of the card, so it can be shared by
+// all the observers and kept after the camera moved on.
+type Picture struct {
+	number   uint
+	time     time.Time
+	topology TopologyE
+	cells    [][]PictureCell
+}
+
+func (picture *Picture) Number() uint {
+	return picture.number
+}
+
+func (picture *Picture) Time() time.Time {
+	return picture.time
+}
+
+func (picture *Picture) Topology() TopologyE {
+	return picture.topology
+}
+
+func (picture *Picture) Width() int {
+	if len(picture.cells) == 0 {
+		return 0
+	}
+	return len(picture.cells[0])
+}
+
+func (picture *Picture) Height() int {
+	return len(picture.cells)
+}
+
+func (picture *Picture) Cell(x int, y int) PictureCell {
+	return picture.cells[y][x]
+}
+
+func (picture *Picture) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Number   uint            `json:"number"`
+		Time     time.Time       `json:"time"`
+		Topology TopologyE       `json:"topology"`
+		Width    int             `json:"width"`
+		Height   int             `json:"height"`
+		Cells    [][]PictureCell `json:"cells"`
+	}{picture.number, picture.time, picture.topology, picture.Width(), picture.Height(), picture.cells})
+}
+
+// PictureObserver is notified by the camera about every picture it takes.
+// The observers are called from the camera goroutine one after another.
+type PictureObserver interface {
+	ObservePicture(picture *Picture)
+}
+
+type PictureObserverFunc func(picture *Picture)
+
+func (observe PictureObserverFunc) ObservePicture(picture *Picture) {
+	observe(picture)
+}
+
+// Structures - Camera
+
+// Camera.pauseChannel passes the latest pause or resume of the Controller,
+// a paused camera takes no pictures until it is resumed.
+type Camera struct {
+	pictureCount uint
+	maxPictures  uint
+	isPaused     bool
+	pauseChannel chan bool
+	card         *TravelersCard
+	observers    []PictureObserver
+	rng          *rand.Rand
+	clockActor   ClockActor
+}
+
+func newCamera(
+	card *TravelersCard, maxPictures uint, observers []PictureObserver, seed int64,
+) *Camera {
+	return &Camera{
+		pictureCount: 0,
+		maxPictures:  maxPictures,
+		isPaused:     false,
+		pauseChannel: make(chan bool, 1),
+		card:         card,
+		observers:    observers,
+		rng:          newRand(seed),
+		clockActor:   card.clock.join(),
+	}
+}
+
+// start returns once the context is cancelled or when only the final
+// picture is left to reach maxPictures (if it is not 0). A pause or
+// a resume takes effect when the next picture is due.
+func (camera *Camera) start(ctx context.Context) {
+	defer camera.card.waitGroup.Done()
+	defer camera.clockActor.leave()
+
+	for camera.maxPictures == 0 || camera.pictureCount+1 < camera.maxPictures {
+		select {
+		case camera.isPaused = <-camera.pauseChannel:
+		default:
+		}
+
+		if !camera.isPaused {
+			camera.takePicture()
+		}
+
+		if !camera.clockActor.sleep(ctx, camera.card.timings.Camera.sample(camera.rng)) {
+			return
+		}
+	}
+}
+
+// pause replaces a pause or a resume the camera has not seen yet, it may
+// only be called by one goroutine at a time.
+func (camera *Camera) pause(isPaused bool) {
+	select {
+	case <-camera.pauseChannel:
+	default:
+	}
+	camera.pauseChannel <- isPaused
+}
+
+func (camera *Camera) takePicture() {
+	camera.pictureCount++
+
+	picture := &Picture{
+		number:   camera.pictureCount,
+		time:     camera.card.clock.now(),
+		topology: camera.card.topology.kind,
+		cells:    camera.card.snapshot(),
+	}
+
+	camera.card.latestPicture.Store(picture)
+	for _, observer := range camera.observers {
+		observer.ObservePicture(picture)
+	}
+}
@@ -0,0 +1,257 @@
+package travelers
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Structures - TravelersCard
+
+// Displacement asks a displaceable traveler to make room on the node c for
+// the traveler at the end of the chain (or one who is not displaceable if
+// the chain is empty).
+type Displacement struct {
+	c     Coordinates
+	chain []TravelerId
+}
+
+// DisplacementChannel passes to a displaceable traveler the nodes which
+// want it to make room.
+type DisplacementChannel chan Displacement
+
+// DangerChannel tells a traveler that a danger zone started on its node.
+type DangerChannel chan Coordinates
+
+type TravelersCard struct {
+	height               int
+	width                int
+	travelerIdManager    *TravelerIdManager
+	topology             *Topology
+	clock                Clock
+	eventLog             *EventLog
+	statistics           *Statistics
+	timings              Timings
+	retryDuration        time.Duration
+	waits                *WaitTable
+	species              []*Species
+	exits                [][]bool
+	dangerZoneDuration   DangerZone
+	dangerZoneShape      DangerZoneShapeE
+	dangerZoneSpread     float64
+	dangerZonesKill      bool
+	latestPicture        atomic.Pointer[Picture]
+	spawnCount           atomic.Uint64
+	grid                 [][]*Node
+	regions              []*Region
+	displacementRegistry *DisplacementRegistry
+	stopChannel          chan struct{}
+	pauseGate            *PauseGate
+
+	// travelers and the camera
+	waitGroup sync.WaitGroup
+	// regions which are still spawning travelers
+	spawnersWaitGroup sync.WaitGroup
+	nodesWaitGroup    sync.WaitGroup
+}
+
+// A card with up to maxRegions nodes has a region for every node, a larger
+// one is split into at most maxRegions square regions.
+const maxRegions = 4096
+
+func regionSize(width int, height int) int {
+	size := 1
+	for ((width+size-1)/size)*((height+size-1)/size) > maxRegions {
+		size *= 2
+	}
+	return size
+}
+
+func newTravelersCard(
+	topology *Topology, travlerIdManager *TravelerIdManager,
+	displacementRegistry *DisplacementRegistry, clock Clock, seed int64, eventLog *EventLog,
+	timings Timings, speed float64, species []*Species, queueSize int,
+) *TravelersCard {
+	width, height := topology.width, topology.height
+
+	seedRand := newRand(seed)
+
+	grid := make([][]*Node, height)
+	for y := range grid {
+		grid[y] = make([]*Node, width)
+	}
+
+	size := regionSize(width, height)
+	var regions []*Region
+	for regionY := 0; regionY < height; regionY += size {
+		for regionX := 0; regionX < width; regionX += size {
+			region := newRegion(clock.join(), seedRand.Int63(), queueSize)
+			regions = append(regions, region)
+
+			for y := regionY; y < height && y < regionY+size; y++ {
+				for x := regionX; x < width && x < regionX+size; x++ {
+					grid[y][x] = newNode(Coordinates{x, y}, region)
+					region.nodes = append(region.nodes, grid[y][x])
+				}
+			}
+		}
+	}
+
+	return &TravelersCard{
+		height:               height,
+		width:                width,
+		topology:             topology,
+		travelerIdManager:    travlerIdManager,
+		clock:                clock,
+		eventLog:             eventLog,
+		statistics:           newStatistics(species),
+		timings:              timings,
+		species:              species,
+		retryDuration:        max(time.Duration(float64(retryDuration)/speed), minWait),
+		grid:                 grid,
+		regions:              regions,
+		displacementRegistry: displacementRegistry,
+		stopChannel:          make(chan struct{}),
+		pauseGate:            newPauseGate(),
+	}
+}
+
+func (card *TravelersCard) startNodes(ctx context.Context, probs NodeProbs) {
+	regionProbs := newRegionProbs(card.species, probs.Danger)
+	for _, region := range card.regions {
+		region.probs = regionProbs
+		card.spawnersWaitGroup.Add(1)
+		card.nodesWaitGroup.Add(1)
+		go region.start(ctx, card)
+	}
+}
+
+// stopNodes may only be called once no traveler can send a request anymore.
+func (card *TravelersCard) stopNodes() {
+	close(card.stopChannel)
+	card.nodesWaitGroup.Wait()
+}
+
+// request sends the request of a traveler to the node and waits for the
+// response, the watchdog sees the traveler waiting until its move is done.
+func (card *TravelersCard) request(key TravelerKey, node *Node, request NodeRequest) NodeResponseE {
+	card.waits.wait(key, request.travelerData.species, node.c, request.request)
+	node.send(request)
+	return <-request.travelerResponse
+}
+
+// sendDangerZone makes the nodes of the zone start its danger zones on their
+// next tick, it returns false if the nodes have stopped before.
+func (card *TravelersCard) sendDangerZone(zone *ScheduledDangerZone) bool {
+	for _, cell := range zone.Cells {
+		for _, c := range card.topology.dangerZoneCells(Coordinates{cell.X, cell.Y}, zone.Shape) {
+			node := card.grid[c.y][c.x]
+			request := newRegionDangerZoneRequest(DangerZone(zone.Duration))
+			if !card.sendRegionRequest(RegionRequest{node: node, dangerZoneRequest: request}) {
+				return false
+			}
+
+			select {
+			case <-request.response:
+			case <-card.stopChannel:
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// sendRegionRequest passes a request which does not come from a traveler to
+// the region of its node, it returns false if the nodes have stopped.
+func (card *TravelersCard) sendRegionRequest(request RegionRequest) bool {
+	select {
+	case request.node.region.requestChannel <- request:
+		return true
+	case <-card.stopChannel:
+		return false
+	}
+}
+
+// logEvent takes a nil species for the events of no traveler.
+func (card *TravelersCard) logEvent(
+	eventType EventTypeE, id TravelerId, species *Species, c Coordinates,
+) {
+	event := Event{
+		Type:     eventType,
+		Traveler: id,
+		X:        c.x,
+		Y:        c.y,
+	}
+	if species != nil {
+		event.Kind = species.Name
+	}
+	card.eventLog.emit(event)
+}
+
+func (card *TravelersCard) getNewPosition(c Coordinates, rng *rand.Rand) Coordinates {
+	neighbours := card.topology.neighbours(c)
+	if len(neighbours) == 0 {
+		return c
+	}
+	return neighbours[rng.Intn(len(neighbours))].c
+}
+
+func (card *TravelersCard) isExit(c Coordinates) bool {
+	return card.exits != nil && card.exits[c.y][c.x]
+}
+
+// isKnownDangerZone tells whether c was a danger zone in the latest picture.
+func (card *TravelersCard) isKnownDangerZone(c Coordinates) bool {
+	picture := card.latestPicture.Load()
+	return picture != nil && picture.Cell(c.x, c.y).DangerZone
+}
+
+func (card *TravelersCard) snapshot() [][]PictureCell {
+	card.sendCameraRequests(cameraBlockNode)
+	card.sendCameraRequests(cameraDrainNode)
+	responses := card.sendCameraRequests(cameraSnapshotNode)
+	card.sendCameraRequests(cameraReleaseNode)
+
+	cells := make([][]PictureCell, card.height)
+	for y := range cells {
+		cells[y] = make([]PictureCell, card.width)
+	}
+
+	for i, region := range card.regions {
+		for j, node := range region.nodes {
+			response := responses[i].nodes[j]
+			cells[node.c.y][node.c.x] = PictureCell{
+				TravelerId:           response.travelerId,
+				DangerZone:           response.dangerZone,
+				Wall:                 response.wall,
+				HorizontalEdgeBlur:   response.edgeBlur&edgeEast != 0,
+				VerticalEdgeBlur:     response.edgeBlur&edgeSouth != 0,
+				DiagonalEdgeBlur:     response.edgeBlur&edgeSouthEast != 0,
+				AntiDiagonalEdgeBlur: response.edgeBlur&edgeSouthWest != 0,
+			}
+			if response.species != nil {
+				cells[node.c.y][node.c.x].Kind = response.species.Name
+				cells[node.c.y][node.c.x].Glyph = response.species.Glyph
+			}
+		}
+	}
+	return cells
+}
+
+// sendCameraRequests sends the request to all the regions before waiting
+// for any of the responses, so the regions can answer them in any order.
+func (card *TravelersCard) sendCameraRequests(request NodeRequestE) []RegionCameraResponse {
+	requests := make([]*RegionCameraRequest, len(card.regions))
+	for i, region := range card.regions {
+		requests[i] = newRegionCameraRequest(request)
+		region.requestChannel <- RegionRequest{cameraRequest: requests[i]}
+	}
+
+	responses := make([]RegionCameraResponse, len(requests))
+	for i := range requests {
+		responses[i] = <-requests[i].response
+	}
+	return responses
+}
@@ -0,0 +1,292 @@
+package travelers
+
+import (
+	"container/heap"
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Structures - Clock
+
+// Clock.stop is called once no actor uses the clock anymore.
+type Clock interface {
+	join() ClockActor
+	now() time.Time
+	stop()
+}
+
+// ClockActor.sleep returns false if the context was cancelled before the
+// duration elapsed. A parked actor lets the others run until some other
+// actor unparks it, it runs again once the channel returned by park
+// delivers. An actor must leave the clock once it stops using it.
+type ClockActor interface {
+	after(duration time.Duration) <-chan time.Time
+	sleep(ctx context.Context, duration time.Duration) bool
+	park() <-chan time.Time
+	unpark()
+	leave()
+}
+
+type realClock struct{}
+
+func newRealClock() Clock {
+	return realClock{}
+}
+
+func (clock realClock) join() ClockActor {
+	return realClockActor{}
+}
+
+func (clock realClock) now() time.Time {
+	return time.Now()
+}
+
+func (clock realClock) stop() {}
+
+type realClockActor struct{}
+
+func (actor realClockActor) after(duration time.Duration) <-chan time.Time {
+	return time.After(duration)
+}
+
+func (actor realClockActor) sleep(ctx context.Context, duration time.Duration) bool {
+	select {
+	case <-time.After(duration):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// A real actor does not have to wait for its turn, it only waits for
+// whatever it was parked for.
+func (actor realClockActor) park() <-chan time.Time {
+	wake := make(chan time.Time, 1)
+	wake <- time.Now()
+	return wake
+}
+
+func (actor realClockActor) unpark() {}
+
+func (actor realClockActor) leave() {}
+
+// virtualClock lets exactly one actor run at a time. Time only advances once
+// every actor has gone back to sleep and sleeping actors are woken in the
+// order (wake time, join order), so a run is fully determined by the seed.
+// Regions and the TravelerIdManager answer requests from the running actor
+// in between, so they never hold the clock themselves. An unpaced clock
+// skips the waits instead of keeping the pace of a real run.
+type virtualClock struct {
+	paced       bool
+	mutex       sync.Mutex
+	idle        *sync.Cond
+	elapsed     time.Duration
+	active      int
+	nextActorId uint64
+	timers      virtualTimerHeap
+	stopped     bool
+}
+
+func newVirtualClock(paced bool) Clock {
+	clock := &virtualClock{paced: paced}
+	clock.idle = sync.NewCond(&clock.mutex)
+	go clock.run()
+	return clock
+}
+
+func (clock *virtualClock) join() ClockActor {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	actor := &virtualClockActor{clock: clock, id: clock.nextActorId}
+	clock.nextActorId++
+	clock.active++
+	return actor
+}
+
+func (clock *virtualClock) run() {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	for {
+		for !clock.stopped && (clock.active > 0 || clock.timers.Len() == 0) {
+			clock.idle.Wait()
+		}
+		if clock.stopped {
+			return
+		}
+
+		timer := heap.Pop(&clock.timers).(*virtualTimer)
+		timer.actor.pending = nil
+		if delay := timer.at - clock.elapsed; clock.paced && delay > 0 {
+			// keep the pace of a real run, nobody can join while all actors sleep
+			clock.mutex.Unlock()
+			time.Sleep(delay)
+			clock.mutex.Lock()
+		}
+
+		clock.elapsed = timer.at
+		clock.active++
+		timer.wake <- clock.virtualTime()
+	}
+}
+
+// stop ends the goroutine waking the actors.
+func (clock *virtualClock) stop() {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	clock.stopped = true
+	clock.idle.Signal()
+}
+
+func (clock *virtualClock) now() time.Time {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	return clock.virtualTime()
+}
+
+func (clock *virtualClock) virtualTime() time.Time {
+	return time.Unix(0, 0).UTC().Add(clock.elapsed)
+}
+
+func (clock *virtualClock) yield() {
+	clock.active--
+	if clock.active == 0 {
+		clock.idle.Signal()
+	}
+}
+
+type virtualClockActor struct {
+	clock   *virtualClock
+	id      uint64
+	pending *virtualTimer
+	parked  chan time.Time
+}
+
+func (actor *virtualClockActor) after(duration time.Duration) <-chan time.Time {
+	clock := actor.clock
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	wake := make(chan time.Time, 1)
+	actor.pending = &virtualTimer{
+		at:    clock.elapsed + duration,
+		actor: actor,
+		wake:  wake,
+	}
+	heap.Push(&clock.timers, actor.pending)
+	clock.yield()
+	return wake
+}
+
+func (actor *virtualClockActor) sleep(ctx context.Context, duration time.Duration) bool {
+	select {
+	case <-actor.after(duration):
+		return true
+	case <-ctx.Done():
+		actor.resume()
+		return false
+	}
+}
+
+func (actor *virtualClockActor) park() <-chan time.Time {
+	clock := actor.clock
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	actor.parked = make(chan time.Time, 1)
+	clock.yield()
+	return actor.parked
+}
+
+// unpark is called by the running actor, so the parked one is woken at the
+// current time once the running one sleeps again.
+func (actor *virtualClockActor) unpark() {
+	clock := actor.clock
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	if actor.parked == nil {
+		return
+	}
+	actor.pending = &virtualTimer{
+		at:    clock.elapsed,
+		actor: actor,
+		wake:  actor.parked,
+	}
+	actor.parked = nil
+	heap.Push(&clock.timers, actor.pending)
+}
+
+// resume makes an actor woken up by something else than its timer
+// count as running again.
+func (actor *virtualClockActor) resume() {
+	clock := actor.clock
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	if actor.pending != nil {
+		heap.Remove(&clock.timers, actor.pending.index)
+		actor.pending = nil
+		clock.active++
+	}
+}
+
+func (actor *virtualClockActor) leave() {
+	clock := actor.clock
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	if actor.pending != nil {
+		heap.Remove(&clock.timers, actor.pending.index)
+		actor.pending = nil
+		return
+	}
+	clock.yield()
+}
+
+type virtualTimer struct {
+	at    time.Duration
+	actor *virtualClockActor
+	index int
+	wake  chan time.Time
+}
+
+type virtualTimerHeap []*virtualTimer
+
+func (timers virtualTimerHeap) Len() int {
+	return len(timers)
+}
+
+func (timers virtualTimerHeap) Less(i, j int) bool {
+	if timers[i].at != timers[j].at {
+		return timers[i].at < timers[j].at
+	}
+	return timers[i].actor.id < timers[j].actor.id
+}
+
+func (timers virtualTimerHeap) Swap(i, j int) {
+	timers[i], timers[j] = timers[j], timers[i]
+	timers[i].index = i
+	timers[j].index = j
+}
+
+func (timers *virtualTimerHeap) Push(timer any) {
+	timer.(*virtualTimer).index = len(*timers)
+	*timers = append(*timers, timer.(*virtualTimer))
+}
+
+func (timers *virtualTimerHeap) Pop() any {
+	old := *timers
+	timer := old[len(old)-1]
+	*timers = old[:len(old)-1]
+	return timer
+}
+
+func newRand(seed int64) *rand.Rand {
+	return rand.New(rand.NewSource(seed))
+}
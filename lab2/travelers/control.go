@@ -0,0 +1,332 @@
+package travelers
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Constants
+
+// a spawn is tried again while the camera takes a picture of the node
+const controlSpawnRetries int = 100
+
+// Structures - PauseGate
+
+// PauseGate keeps the travelers from starting a tick while the run is
+// paused. A traveler who entered it must leave it once its tick is over.
+type PauseGate struct {
+	mutex    sync.Mutex
+	idle     *sync.Cond
+	isPaused bool
+	ticking  int
+}
+
+func newPauseGate() *PauseGate {
+	gate := &PauseGate{}
+	gate.idle = sync.NewCond(&gate.mutex)
+	return gate
+}
+
+// enter returns false while the run is paused.
+func (gate *PauseGate) enter() bool {
+	gate.mutex.Lock()
+	defer gate.mutex.Unlock()
+
+	if gate.isPaused {
+		return false
+	}
+	gate.ticking++
+	return true
+}
+
+func (gate *PauseGate) leave() {
+	gate.mutex.Lock()
+	defer gate.mutex.Unlock()
+
+	gate.ticking--
+	if gate.ticking == 0 {
+		gate.idle.Broadcast()
+	}
+}
+
+// pause waits for the ticks already started to be over, so that nothing
+// but the travelers making room for them moves afterwards.
+func (gate *PauseGate) pause(isPaused bool) {
+	gate.mutex.Lock()
+	defer gate.mutex.Unlock()
+
+	gate.isPaused = isPaused
+	for gate.isPaused && gate.ticking > 0 {
+		gate.idle.Wait()
+	}
+}
+
+// Structures - Controller
+
+// ControlState is what the Controller has changed so far.
+type ControlState struct {
+	Paused bool      `json:"paused"`
+	Probs  NodeProbs `json:"probs"`
+}
+
+// Controller pokes a running simulation. Its commands are requests to the
+// regions like the ones of the travelers, so every node is still only
+// changed by the goroutine of its region. A simulation which is controlled
+// is no longer reproducible, even if it is deterministic. The commands are
+// carried out one at a time, it must only be used while the simulation
+// runs.
+type Controller struct {
+	mutex  sync.Mutex
+	config *Config
+	card   *TravelersCard
+	camera *Camera
+	state  ControlState
+}
+
+func newController(config *Config, card *TravelersCard, camera *Camera) *Controller {
+	controller := &Controller{
+		config: config,
+		card:   card,
+		camera: camera,
+		state:  ControlState{Probs: config.Probs},
+	}
+
+	// the probabilities of custom species named like the default ones
+	for _, species := range card.species {
+		switch species.Name {
+		case SpeciesNormal:
+			controller.state.Probs.Spawn = species.SpawnProb
+			controller.state.Probs.Move = species.MoveProb
+		case SpeciesWild:
+			controller.state.Probs.Wild = species.SpawnProb
+		}
+	}
+	return controller
+}
+
+func (controller *Controller) State() ControlState {
+	controller.mutex.Lock()
+	defer controller.mutex.Unlock()
+
+	return controller.state
+}
+
+func (controller *Controller) species(name string) (*Species, error) {
+	if name == "" {
+		return controller.card.species[0], nil
+	}
+	index := slices.IndexFunc(controller.card.species, func(species *Species) bool {
+		return species.Name == name
+	})
+	if index < 0 {
+		return nil, fmt.Errorf("unknown species %q", name)
+	}
+	return controller.card.species[index], nil
+}
+
+func (controller *Controller) node(x int, y int) (*Node, error) {
+	if x < 0 || x >= controller.card.width || y < 0 || y >= controller.card.height {
+		return nil, fmt.Errorf("node (%d,%d) must be on the card", x, y)
+	}
+	return controller.card.grid[y][x], nil
+}
+
+// request sends the command to the region of the node and waits for its
+// response.
+func (controller *Controller) request(node *Node, request *RegionControlRequest) RegionControlResponse {
+	card := controller.card
+	if !card.sendRegionRequest(RegionRequest{node: node, controlRequest: request}) {
+		return RegionControlResponse{travelerId: NullTraveler, err: errors.New("the simulation has stopped")}
+	}
+
+	select {
+	case response := <-request.response:
+		return response
+	case <-card.stopChannel:
+		return RegionControlResponse{travelerId: NullTraveler, err: errors.New("the simulation has stopped")}
+	}
+}
+
+// requestAll sends the command to every region and returns the first error.
+func (controller *Controller) requestAll(newRequest func() *RegionControlRequest) error {
+	var err error
+	for _, region := range controller.card.regions {
+		if response := controller.request(region.nodes[0], newRequest()); response.err != nil && err == nil {
+			err = response.err
+		}
+	}
+	return err
+}
+
+// SpawnTraveler puts a new traveler of the species (the first one if the
+// name is empty) on the empty node (x, y).
+func (controller *Controller) SpawnTraveler(x int, y int, speciesName string) (TravelerId, error) {
+	controller.mutex.Lock()
+	defer controller.mutex.Unlock()
+
+	node, err := controller.node(x, y)
+	if err != nil {
+		return NullTraveler, err
+	}
+	species, err := controller.species(speciesName)
+	if err != nil {
+		return NullTraveler, err
+	}
+
+	for retry := 0; ; retry++ {
+		request := newRegionControlRequest(controlSpawnNode)
+		request.species = species
+
+		response := controller.request(node, request)
+		if !errors.Is(response.err, errNodeBlocked) || retry == controlSpawnRetries {
+			return response.travelerId, response.err
+		}
+		time.Sleep(controller.card.retryDuration)
+	}
+}
+
+// StartDangerZone starts a danger zone of the shape around the node (x, y)
+// on the next tick of its nodes, it lasts duration ticks (the danger zone
+// duration of the simulation if 0).
+func (controller *Controller) StartDangerZone(
+	x int, y int, shape DangerZoneShapeE, duration int,
+) error {
+	controller.mutex.Lock()
+	defer controller.mutex.Unlock()
+
+	zone := ScheduledDangerZone{
+		Cells:    []ScenarioCell{{X: x, Y: y}},
+		Shape:    shape,
+		Duration: duration,
+	}
+	if err := zone.validate(controller.config); err != nil {
+		return err
+	}
+
+	if !controller.card.sendDangerZone(&zone) {
+		return errors.New("the simulation has stopped")
+	}
+	return nil
+}
+
+// Pause stops the camera, all the nodes and all the travelers: no traveler
+// spawns, moves, leaves or loses health and the danger zones stay as they
+// are until Resume. It returns once the ticks of the travelers already
+// started are over, their moves still finish.
+func (controller *Controller) Pause() error {
+	return controller.pause(true)
+}
+
+func (controller *Controller) Resume() error {
+	return controller.pause(false)
+}
+
+func (controller *Controller) pause(isPaused bool) error {
+	controller.mutex.Lock()
+	defer controller.mutex.Unlock()
+
+	request := controlResumeNode
+	if isPaused {
+		request = controlPauseNode
+	}
+	err := controller.requestAll(func() *RegionControlRequest {
+		return newRegionControlRequest(request)
+	})
+	if err != nil {
+		return err
+	}
+
+	controller.card.pauseGate.pause(isPaused)
+	controller.camera.pause(isPaused)
+	controller.state.Paused = isPaused
+	return nil
+}
+
+// SetProbs changes the probabilities of the nodes: Spawn and Move are the
+// ones of the species named normal and Wild the spawn probability of the
+// species named wild. The travelers already on the card keep moving with
+// the probability they spawned with.
+func (controller *Controller) SetProbs(probs NodeProbs) error {
+	return controller.ChangeProbs(func(current *NodeProbs) {
+		*current = probs
+	})
+}
+
+// ChangeProbs changes the probabilities like SetProbs, change gets the
+// current ones.
+func (controller *Controller) ChangeProbs(change func(probs *NodeProbs)) error {
+	controller.mutex.Lock()
+	defer controller.mutex.Unlock()
+
+	probs := controller.state.Probs
+	change(&probs)
+
+	species := controller.card.species
+	regionProbs := newRegionProbs(species, probs.Danger)
+	for i, current := range species {
+		switch current.Name {
+		case SpeciesNormal:
+			regionProbs.spawn[i] = probs.Spawn
+			regionProbs.move[i] = probs.Move
+		case SpeciesWild:
+			regionProbs.spawn[i] = probs.Wild
+		}
+	}
+	if err := controller.validateProbs(probs, regionProbs); err != nil {
+		return err
+	}
+
+	err := controller.requestAll(func() *RegionControlRequest {
+		request := newRegionControlRequest(controlProbsNode)
+		request.probs = regionProbs
+		return request
+	})
+	if err != nil {
+		return err
+	}
+
+	controller.state.Probs = probs
+	return nil
+}
+
+func (controller *Controller) validateProbs(probs NodeProbs, regionProbs *RegionProbs) error {
+	for _, prob := range []struct {
+		name  string
+		value float64
+	}{
+		{"spawn_prob", probs.Spawn},
+		{"move_prob", probs.Move},
+		{"wild_prob", probs.Wild},
+		{"danger_prob", probs.Danger},
+	} {
+		if prob.value < 0 || prob.value > 1 {
+			return fmt.Errorf("invalid value of %s - must be in range [0, 1]", prob.name)
+		}
+	}
+
+	current := controller.state.Probs
+	for _, species := range []struct {
+		name    string
+		changed bool
+	}{
+		{SpeciesNormal, probs.Spawn != current.Spawn || probs.Move != current.Move},
+		{SpeciesWild, probs.Wild != current.Wild},
+	} {
+		if _, err := controller.species(species.name); species.changed && err != nil {
+			return fmt.Errorf("invalid probabilities - there are no %s travelers", species.name)
+		}
+	}
+
+	total := regionProbs.danger
+	for _, spawn := range regionProbs.spawn {
+		total += spawn
+	}
+	if total > 1+probTolerance {
+		return fmt.Errorf("invalid probabilities - the spawn probabilities of the species "+
+			"and danger_prob add up to %.4g, must be at most 1", total)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package travelers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net"
+	"net/http"
+)
+
+// Structures - ControlServer
+
+type controlSpawnRequest struct {
+	X       int    `json:"x"`
+	Y       int    `json:"y"`
+	Species string `json:"species,omitempty"`
+}
+
+type controlDangerZoneRequest struct {
+	X        int              `json:"x"`
+	Y        int              `json:"y"`
+	Shape    DangerZoneShapeE `json:"shape,omitempty"`
+	Duration int              `json:"duration,omitempty"`
+}
+
+// controlProbsRequest only changes the probabilities it has.
+type controlProbsRequest struct {
+	Spawn  *float64 `json:"spawn_prob"`
+	Move   *float64 `json:"move_prob"`
+	Wild   *float64 `json:"wild_prob"`
+	Danger *float64 `json:"danger_prob"`
+}
+
+// ControlServer serves the commands of the Controller as JSON over HTTP,
+// on a TCP port or a Unix socket:
+//   - GET /state: whether the simulation is paused and its probabilities,
+//   - POST /spawn {"x", "y", "species"}: the id of the traveler spawned,
+//   - POST /danger-zone {"x", "y", "shape", "duration"},
+//   - POST /pause and POST /resume,
+//   - POST /probs {"spawn_prob", "move_prob", "wild_prob", "danger_prob"}.
+//
+// The commands changing the state answer with the new state and the ones
+// which failed with {"error"}. Anyone who can reach the server can change
+// the run, so a port without a host only listens on localhost and the
+// commands must be sent as application/json, which a web page cannot do
+// for another site without asking it first.
+type ControlServer struct {
+	address    string
+	controller *Controller
+	httpServer *http.Server
+}
+
+func NewControlServer(address string, controller *Controller) *ControlServer {
+	server := &ControlServer{address: address, controller: controller}
+	server.httpServer = &http.Server{Handler: server.Handler()}
+	return server
+}
+
+func (server *ControlServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/state", server.handleState)
+	mux.HandleFunc("/spawn", server.handleSpawn)
+	mux.HandleFunc("/danger-zone", server.handleDangerZone)
+	mux.HandleFunc("/pause", server.handlePause)
+	mux.HandleFunc("/resume", server.handlePause)
+	mux.HandleFunc("/probs", server.handleProbs)
+	return mux
+}
+
+// Start listens on the address before it returns and serves in the
+// background.
+func (server *ControlServer) Start() (net.Addr, error) {
+	listener, err := listen(server.address)
+	if err != nil {
+		return nil, err
+	}
+
+	go server.httpServer.Serve(listener)
+	return listener.Addr(), nil
+}
+
+func (server *ControlServer) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+	defer cancel()
+	return server.httpServer.Shutdown(ctx)
+}
+
+func writeControlResponse(w http.ResponseWriter, status int, response any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+func writeControlError(w http.ResponseWriter, status int, err error) {
+	writeControlResponse(w, status, struct {
+		Error string `json:"error"`
+	}{err.Error()})
+}
+
+// decodeControlRequest answers the request itself unless it is a JSON POST
+// with a valid body.
+func decodeControlRequest(w http.ResponseWriter, r *http.Request, request any) bool {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeControlError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return false
+	}
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil ||
+		mediaType != "application/json" {
+		writeControlError(w, http.StatusUnsupportedMediaType,
+			errors.New("the content type must be application/json"))
+		return false
+	}
+	if request == nil {
+		return true
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(request); err != nil {
+		writeControlError(w, http.StatusBadRequest, err)
+		return false
+	}
+	return true
+}
+
+func (server *ControlServer) handleState(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeControlError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	writeControlResponse(w, http.StatusOK, server.controller.State())
+}
+
+func (server *ControlServer) handleSpawn(w http.ResponseWriter, r *http.Request) {
+	var request controlSpawnRequest
+	if !decodeControlRequest(w, r, &request) {
+		return
+	}
+
+	travelerId, err := server.controller.SpawnTraveler(request.X, request.Y, request.Species)
+	if err != nil {
+		writeControlError(w, http.StatusConflict, err)
+		return
+	}
+	writeControlResponse(w, http.StatusOK, struct {
+		Traveler TravelerId `json:"traveler"`
+	}{travelerId})
+}
+
+func (server *ControlServer) handleDangerZone(w http.ResponseWriter, r *http.Request) {
+	var request controlDangerZoneRequest
+	if !decodeControlRequest(w, r, &request) {
+		return
+	}
+
+	err := server.controller.StartDangerZone(request.X, request.Y, request.Shape, request.Duration)
+	if err != nil {
+		writeControlError(w, http.StatusConflict, err)
+		return
+	}
+	writeControlResponse(w, http.StatusOK, server.controller.State())
+}
+
+func (server *ControlServer) handlePause(w http.ResponseWriter, r *http.Request) {
+	if !decodeControlRequest(w, r, nil) {
+		return
+	}
+
+	pause := server.controller.Pause
+	if r.URL.Path == "/resume" {
+		pause = server.controller.Resume
+	}
+	if err := pause(); err != nil {
+		writeControlError(w, http.StatusConflict, err)
+		return
+	}
+	writeControlResponse(w, http.StatusOK, server.controller.State())
+}
+
+func (server *ControlServer) handleProbs(w http.ResponseWriter, r *http.Request) {
+	var request controlProbsRequest
+	if !decodeControlRequest(w, r, &request) {
+		return
+	}
+
+	err := server.controller.ChangeProbs(func(probs *NodeProbs) {
+		for _, prob := range []struct {
+			value *float64
+			prob  *float64
+		}{
+			{request.Spawn, &probs.Spawn},
+			{request.Move, &probs.Move},
+			{request.Wild, &probs.Wild},
+			{request.Danger, &probs.Danger},
+		} {
+			if prob.value != nil {
+				*prob.prob = *prob.value
+			}
+		}
+	})
+	if err != nil {
+		writeControlError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeControlResponse(w, http.StatusOK, server.controller.State())
+}
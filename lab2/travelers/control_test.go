@@ -0,0 +1,373 @@
+package travelers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Structures - test pictures
+
+type testPictures struct {
+	mutex   sync.Mutex
+	count   int
+	picture *Picture
+}
+
+func (pictures *testPictures) ObservePicture(picture *Picture) {
+	pictures.mutex.Lock()
+	defer pictures.mutex.Unlock()
+
+	pictures.count++
+	pictures.picture = picture
+}
+
+func (pictures *testPictures) latest() (int, *Picture) {
+	pictures.mutex.Lock()
+	defer pictures.mutex.Unlock()
+
+	return pictures.count, pictures.picture
+}
+
+// waitFor waits for a picture to match, or fails the test after a while.
+func (pictures *testPictures) waitFor(t *testing.T, name string, match func(picture *Picture) bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		if _, picture := pictures.latest(); picture != nil && match(picture) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("no picture with %s", name)
+}
+
+func countTravelers(picture *Picture) int {
+	travelers := 0
+	for y := 0; y < picture.Height(); y++ {
+		for x := 0; x < picture.Width(); x++ {
+			if picture.Cell(x, y).TravelerId != NullTraveler {
+				travelers++
+			}
+		}
+	}
+	return travelers
+}
+
+// startControlledSimulation runs a simulation on the real clock in which
+// nothing happens unless the test asks for it.
+func startControlledSimulation(t *testing.T) (*Simulation, *testPictures) {
+	t.Helper()
+
+	pictures := &testPictures{}
+	simulation, err := NewSimulation(Config{
+		Width:              4,
+		Height:             4,
+		MaxTravelers:       8,
+		DangerZoneDuration: 1000,
+		Seed:               1,
+		Speed:              50,
+		Observers:          []PictureObserver{pictures},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	simulation.Start()
+	t.Cleanup(func() { simulation.Stop() })
+	return simulation, pictures
+}
+
+func TestControllerSpawnTraveler(t *testing.T) {
+	simulation, pictures := startControlledSimulation(t)
+	controller := simulation.Controller()
+
+	normal, err := controller.SpawnTraveler(1, 1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wild, err := controller.SpawnTraveler(2, 3, SpeciesWild)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		x, y    int
+		species string
+		error   string
+	}{
+		{"occupied node", 1, 1, SpeciesWild, "not empty"},
+		{"off the card", 4, 0, "", "must be on the card"},
+		{"unknown species", 0, 0, "ghost", "unknown species"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := controller.SpawnTraveler(test.x, test.y, test.species)
+			if err == nil || !strings.Contains(err.Error(), test.error) {
+				t.Errorf("error %v, expected %q", err, test.error)
+			}
+		})
+	}
+
+	pictures.waitFor(t, "the spawned travelers", func(picture *Picture) bool {
+		return picture.Cell(1, 1).TravelerId == normal && picture.Cell(1, 1).Kind == SpeciesNormal &&
+			picture.Cell(2, 3).TravelerId == wild && picture.Cell(2, 3).Kind == SpeciesWild
+	})
+
+	simulation.Stop()
+	if _, err := controller.SpawnTraveler(0, 0, ""); err == nil {
+		t.Error("spawned a traveler after the simulation stopped")
+	}
+}
+
+func TestControllerPause(t *testing.T) {
+	simulation, pictures := startControlledSimulation(t)
+	controller := simulation.Controller()
+
+	pictures.waitFor(t, "a picture", func(*Picture) bool { return true })
+	if err := controller.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	if !controller.State().Paused {
+		t.Error("not paused")
+	}
+
+	// the camera may still be taking the picture it started
+	time.Sleep(100 * time.Millisecond)
+	paused, _ := pictures.latest()
+	time.Sleep(300 * time.Millisecond)
+	if count, _ := pictures.latest(); count != paused {
+		t.Errorf("%d pictures taken while paused", count-paused)
+	}
+
+	// the travelers spawned while paused wait where they are
+	if err := controller.SetProbs(NodeProbs{Spawn: 0.5, Move: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := controller.SpawnTraveler(0, 0, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := controller.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	pictures.waitFor(t, "new travelers", func(picture *Picture) bool {
+		return countTravelers(picture) > 1
+	})
+}
+
+// TestControllerPauseTravelers checks that nothing happens to the travelers
+// between Pause and Resume, while wild travelers keep expiring before and
+// after.
+func TestControllerPauseTravelers(t *testing.T) {
+	var eventLog bytes.Buffer
+	exits := make([][]bool, 6)
+	for y := range exits {
+		exits[y] = make([]bool, 6)
+	}
+	exits[0][0] = true
+
+	simulation, err := NewSimulation(Config{
+		Width:        6,
+		Height:       6,
+		MaxTravelers: 10,
+		Probs:        NodeProbs{Spawn: 0.2, Move: 0.9, Wild: 0.8},
+		Exits:        exits,
+		Lifetime:     3,
+		Seed:         1,
+		Speed:        100,
+		Duration:     time.Minute,
+		EventLog:     &eventLog,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	simulation.Start()
+	defer simulation.Stop()
+	controller := simulation.Controller()
+
+	time.Sleep(300 * time.Millisecond)
+	if err := controller.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	paused := time.Now()
+	time.Sleep(500 * time.Millisecond)
+	resumed := time.Now()
+	if err := controller.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	simulation.Stop()
+
+	var before, during, after int
+	for _, line := range strings.Split(strings.TrimSpace(eventLog.String()), "\n") {
+		var event Event
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatal(err)
+		}
+		switch event.Type {
+		case EventSpawn, EventReserve, EventHealth, EventTerminate, EventLeave:
+		default:
+			continue
+		}
+
+		switch {
+		case event.Time.Before(paused):
+			before++
+		case event.Time.Before(resumed):
+			during++
+			t.Errorf("%s of traveler %d at (%d,%d) while paused", event.Type, event.Traveler, event.X, event.Y)
+		default:
+			after++
+		}
+	}
+	if before == 0 || after == 0 {
+		t.Errorf("%d events before the pause and %d after it", before, after)
+	}
+}
+
+func TestControllerSetProbs(t *testing.T) {
+	simulation, pictures := startControlledSimulation(t)
+	controller := simulation.Controller()
+
+	tests := []struct {
+		name  string
+		probs NodeProbs
+		error string
+	}{
+		{"negative spawn_prob", NodeProbs{Spawn: -0.1}, "invalid value of spawn_prob"},
+		{"move_prob above 1", NodeProbs{Move: 1.5}, "invalid value of move_prob"},
+		{"probabilities above 1", NodeProbs{Spawn: 0.5, Wild: 0.3, Danger: 0.3}, "add up to 1.1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := controller.SetProbs(test.probs)
+			if err == nil || !strings.Contains(err.Error(), test.error) {
+				t.Errorf("error %v, expected %q", err, test.error)
+			}
+		})
+	}
+	if probs := controller.State().Probs; probs != (NodeProbs{}) {
+		t.Errorf("probabilities %+v changed by the invalid ones", probs)
+	}
+
+	if err := controller.SetProbs(NodeProbs{Wild: 1}); err != nil {
+		t.Fatal(err)
+	}
+	pictures.waitFor(t, "wild travelers", func(picture *Picture) bool {
+		for y := 0; y < picture.Height(); y++ {
+			for x := 0; x < picture.Width(); x++ {
+				if picture.Cell(x, y).Kind == SpeciesWild {
+					return true
+				}
+			}
+		}
+		return false
+	})
+}
+
+func TestControllerStartDangerZone(t *testing.T) {
+	simulation, pictures := startControlledSimulation(t)
+	controller := simulation.Controller()
+
+	if err := controller.StartDangerZone(0, 0, DangerZoneShapeE("star"), 0); err == nil {
+		t.Error("started a danger zone of an unknown shape")
+	}
+	if err := controller.StartDangerZone(0, 4, "", 0); err == nil {
+		t.Error("started a danger zone off the card")
+	}
+
+	if err := controller.StartDangerZone(2, 1, "", 0); err != nil {
+		t.Fatal(err)
+	}
+	pictures.waitFor(t, "the danger zone", func(picture *Picture) bool {
+		return picture.Cell(2, 1).DangerZone && !picture.Cell(1, 1).DangerZone
+	})
+}
+
+func TestControlServer(t *testing.T) {
+	simulation, _ := startControlledSimulation(t)
+	httpServer := httptest.NewServer(NewControlServer("", simulation.Controller()).Handler())
+	defer httpServer.Close()
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		status   int
+		response string
+	}{
+		{"state", http.MethodGet, "/state", "", http.StatusOK, `"paused":false`},
+		{"spawn", http.MethodPost, "/spawn", `{"x": 3, "y": 0}`, http.StatusOK, `"traveler":0`},
+		{"spawn on a traveler", http.MethodPost, "/spawn", `{"x": 3, "y": 0, "species": "wild"}`,
+			http.StatusConflict, "not empty"},
+		{"spawn without a body", http.MethodPost, "/spawn", "", http.StatusBadRequest, `"error"`},
+		{"spawn with an unknown field", http.MethodPost, "/spawn", `{"x": 0, "z": 0}`,
+			http.StatusBadRequest, "unknown field"},
+		{"get spawn", http.MethodGet, "/spawn", "", http.StatusMethodNotAllowed, "method not allowed"},
+		{"danger zone", http.MethodPost, "/danger-zone", `{"x": 0, "y": 0, "shape": "cross", "duration": 3}`,
+			http.StatusOK, `"paused":false`},
+		{"danger zone off the card", http.MethodPost, "/danger-zone", `{"x": -1, "y": 0}`,
+			http.StatusConflict, "must be on the card"},
+		{"pause", http.MethodPost, "/pause", "", http.StatusOK, `"paused":true`},
+		{"probs", http.MethodPost, "/probs", `{"danger_prob": 0.25}`, http.StatusOK, `"danger_prob":0.25`},
+		{"partial probs", http.MethodPost, "/probs", `{"move_prob": 0.5}`, http.StatusOK,
+			`"probs":{"spawn_prob":0,"move_prob":0.5,"wild_prob":0,"danger_prob":0.25}`},
+		{"invalid probs", http.MethodPost, "/probs", `{"spawn_prob": 2}`, http.StatusBadRequest,
+			"invalid value of spawn_prob"},
+		{"resume", http.MethodPost, "/resume", "", http.StatusOK, `"paused":false`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request, err := http.NewRequest(test.method, httpServer.URL+test.path, strings.NewReader(test.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			request.Header.Set("Content-Type", "application/json; charset=utf-8")
+			response, err := http.DefaultClient.Do(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer response.Body.Close()
+
+			var body json.RawMessage
+			if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if response.StatusCode != test.status || !strings.Contains(string(body), test.response) {
+				t.Errorf("%s %s, expected %d %s", response.Status, body, test.status, test.response)
+			}
+		})
+	}
+}
+
+// TestControlServerContentType rejects the commands a web page of another
+// site can send without asking the server first.
+func TestControlServerContentType(t *testing.T) {
+	simulation, _ := startControlledSimulation(t)
+	httpServer := httptest.NewServer(NewControlServer("", simulation.Controller()).Handler())
+	defer httpServer.Close()
+
+	for _, contentType := range []string{"", "text/plain", "application/x-www-form-urlencoded", "multipart/form-data"} {
+		for _, path := range []string{"/spawn", "/pause", "/probs"} {
+			response, err := http.Post(httpServer.URL+path, contentType, strings.NewReader(`{"x": 0, "y": 0}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			response.Body.Close()
+			if response.StatusCode != http.StatusUnsupportedMediaType {
+				t.Errorf("%s as %q: %s", path, contentType, response.Status)
+			}
+		}
+	}
+	if state := simulation.Controller().State(); state.Paused {
+		t.Error("paused by a request which is not JSON")
+	}
+}
@@ -0,0 +1,162 @@
+package travelers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Type aliases
+
+type DangerZoneShapeE string
+
+// Constants
+
+const ( // DangerZoneShapeE
+	DangerZoneShapeNode  DangerZoneShapeE = "node"
+	DangerZoneShapeCross DangerZoneShapeE = "cross"
+	DangerZoneShapeDisc  DangerZoneShapeE = "disc"
+)
+
+// a shape covers the nodes up to its radius moves away from its center
+var dangerZoneShapeRadius = map[DangerZoneShapeE]int{
+	DangerZoneShapeNode:  0,
+	DangerZoneShapeCross: 1,
+	DangerZoneShapeDisc:  2,
+}
+
+func DangerZoneShapeNames() []string {
+	names := make([]string, 0, len(dangerZoneShapeRadius))
+	for name := range dangerZoneShapeRadius {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func validateDangerZoneShape(shape *DangerZoneShapeE) error {
+	if *shape == "" {
+		*shape = DangerZoneShapeNode
+	}
+	if _, exists := dangerZoneShapeRadius[*shape]; !exists {
+		return fmt.Errorf("shape must be one of: %s", strings.Join(DangerZoneShapeNames(), ", "))
+	}
+	return nil
+}
+
+// dangerZoneCells returns the nodes of the shape around c, c first and then
+// the others in the order of their distance from it.
+func (topology *Topology) dangerZoneCells(c Coordinates, shape DangerZoneShapeE) []Coordinates {
+	cells := []Coordinates{c}
+	distances := map[Coordinates]int{c: 0}
+
+	for i := 0; i < len(cells); i++ {
+		if distances[cells[i]] == dangerZoneShapeRadius[shape] {
+			continue
+		}
+		for _, link := range topology.neighbours(cells[i]) {
+			if _, exists := distances[link.c]; !exists {
+				distances[link.c] = distances[cells[i]] + 1
+				cells = append(cells, link.c)
+			}
+		}
+	}
+	return cells
+}
+
+// Structures - ScheduledDangerZone
+
+type ScenarioCell struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// ScheduledDangerZone starts danger zones of the Shape around every one of
+// the Cells once At has passed since the start of the simulation, they last
+// Duration ticks (the DangerZoneDuration of the simulation if 0).
+type ScheduledDangerZone struct {
+	At       Duration         `json:"at"`
+	Cells    []ScenarioCell   `json:"cells"`
+	Shape    DangerZoneShapeE `json:"shape,omitempty"`
+	Duration int              `json:"duration,omitempty"`
+}
+
+// LoadScenario reads a JSON array of scheduled danger zones.
+func LoadScenario(reader io.Reader) ([]ScheduledDangerZone, error) {
+	decoder := json.NewDecoder(reader)
+	decoder.DisallowUnknownFields()
+
+	var scenario []ScheduledDangerZone
+	if err := decoder.Decode(&scenario); err != nil {
+		return nil, err
+	}
+	return scenario, nil
+}
+
+func (zone *ScheduledDangerZone) validate(config *Config) error {
+	if zone.At < 0 {
+		return errors.New("at must be non-negative")
+	}
+
+	if len(zone.Cells) == 0 {
+		return errors.New("cells must not be empty")
+	}
+	for _, cell := range zone.Cells {
+		if cell.X < 0 || cell.X >= config.Width || cell.Y < 0 || cell.Y >= config.Height {
+			return fmt.Errorf("cell (%d,%d) must be on the card", cell.X, cell.Y)
+		}
+		if (config.Walls != nil && config.Walls[cell.Y][cell.X]) ||
+			(config.Exits != nil && config.Exits[cell.Y][cell.X]) {
+			return fmt.Errorf("cell (%d,%d) must not be a wall or an exit", cell.X, cell.Y)
+		}
+	}
+
+	if err := validateDangerZoneShape(&zone.Shape); err != nil {
+		return err
+	}
+
+	if zone.Duration == 0 {
+		zone.Duration = config.DangerZoneDuration
+	}
+	if zone.Duration < 1 || zone.Duration > maxDangerZoneDuration {
+		return fmt.Errorf("duration must be in range [1, %d]", maxDangerZoneDuration)
+	}
+	return nil
+}
+
+// Structures - Scenario
+
+// Scenario is the actor starting the scheduled danger zones, each of them
+// on the next tick of its nodes.
+type Scenario struct {
+	zones      []ScheduledDangerZone
+	clockActor ClockActor
+}
+
+func newScenario(zones []ScheduledDangerZone, clockActor ClockActor) *Scenario {
+	zones = append([]ScheduledDangerZone(nil), zones...)
+	sort.SliceStable(zones, func(i, j int) bool {
+		return zones[i].At < zones[j].At
+	})
+	return &Scenario{zones: zones, clockActor: clockActor}
+}
+
+func (scenario *Scenario) start(ctx context.Context, card *TravelersCard) {
+	defer card.waitGroup.Done()
+	defer scenario.clockActor.leave()
+
+	var elapsed time.Duration
+	for _, zone := range scenario.zones {
+		if !scenario.clockActor.sleep(ctx, time.Duration(zone.At)-elapsed) {
+			return
+		}
+		elapsed = time.Duration(zone.At)
+
+		card.sendDangerZone(&zone)
+	}
+}
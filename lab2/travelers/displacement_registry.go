@@ -0,0 +1,78 @@
+package travelers
+
+import "sync"
+
+// Structures - DisplacementRegistry
+
+// DisplacementRegistryRequest.response is only set for a lookup, whose
+// response is nil if the traveler is not registered.
+type DisplacementRegistryRequest struct {
+	request  DisplacementRegistryRequestE
+	id       TravelerId
+	channel  DisplacementChannel
+	response chan DisplacementChannel
+}
+
+type DisplacementRegistryRequestChannel chan DisplacementRegistryRequest
+
+// DisplacementRegistry holds the displacement channels of the displaceable
+// travelers on the card. The nodes register a traveler when they spawn it
+// and look it up when it has to make room, the traveler unregisters itself
+// once it is gone, so the registry is the only one touching its map.
+type DisplacementRegistry struct {
+	channels map[TravelerId]DisplacementChannel
+	channel  DisplacementRegistryRequestChannel
+}
+
+func newDisplacementRegistry(queueSize int) *DisplacementRegistry {
+	return &DisplacementRegistry{
+		channels: make(map[TravelerId]DisplacementChannel),
+		channel:  make(DisplacementRegistryRequestChannel, queueSize),
+	}
+}
+
+func (registry *DisplacementRegistry) register(id TravelerId, channel DisplacementChannel) {
+	registry.channel <- DisplacementRegistryRequest{
+		request: registerDisplacement,
+		id:      id,
+		channel: channel,
+	}
+}
+
+func (registry *DisplacementRegistry) lookup(id TravelerId) (DisplacementChannel, bool) {
+	request := DisplacementRegistryRequest{
+		request:  lookupDisplacement,
+		id:       id,
+		response: make(chan DisplacementChannel, 1),
+	}
+	registry.channel <- request
+
+	channel := <-request.response
+	return channel, channel != nil
+}
+
+func (registry *DisplacementRegistry) unregister(id TravelerId) {
+	registry.channel <- DisplacementRegistryRequest{request: unregisterDisplacement, id: id}
+}
+
+// stop may only be called once the travelers and the nodes are done.
+func (registry *DisplacementRegistry) stop() {
+	close(registry.channel)
+}
+
+func (registry *DisplacementRegistry) start(waitGroup *sync.WaitGroup) {
+	defer waitGroup.Done()
+
+	for request := range registry.channel {
+		switch request.request {
+		case registerDisplacement:
+			registry.channels[request.id] = request.channel
+
+		case lookupDisplacement:
+			request.response <- registry.channels[request.id]
+
+		case unregisterDisplacement:
+			delete(registry.channels, request.id)
+		}
+	}
+}
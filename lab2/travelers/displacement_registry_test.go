@@ -0,0 +1,51 @@
+package travelers
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestDisplacementRegistry registers, looks up and unregisters thousands of
+// travelers from many goroutines at once, run it with -race.
+func TestDisplacementRegistry(t *testing.T) {
+	const (
+		goroutines = 16
+		travelers  = 1000
+	)
+
+	registry := newDisplacementRegistry(bufferSize)
+	var servicesWaitGroup sync.WaitGroup
+	servicesWaitGroup.Add(1)
+	go registry.start(&servicesWaitGroup)
+
+	var waitGroup sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		waitGroup.Add(1)
+		go func(g int) {
+			defer waitGroup.Done()
+
+			for i := 0; i < travelers; i++ {
+				id := TravelerId(g*travelers + i)
+				channel := make(DisplacementChannel, 1)
+				registry.register(id, channel)
+
+				if found, exists := registry.lookup(id); !exists || found != channel {
+					t.Errorf("traveler %d: got %v, %v after registering it", id, found, exists)
+				}
+
+				registry.unregister(id)
+				if _, exists := registry.lookup(id); exists {
+					t.Errorf("traveler %d: still registered after unregistering it", id)
+				}
+			}
+		}(g)
+	}
+
+	waitGroup.Wait()
+	registry.stop()
+	servicesWaitGroup.Wait()
+
+	if len(registry.channels) != 0 {
+		t.Errorf("%d travelers left in the registry", len(registry.channels))
+	}
+}
@@ -0,0 +1,147 @@
+package travelers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Structures - EventLog
+
+type EventTypeE string
+
+const ( // EventTypeE
+	EventSpawn            EventTypeE = "spawn"
+	EventReserve          EventTypeE = "reserve"
+	EventCancel           EventTypeE = "cancel"
+	EventReleaseOut       EventTypeE = "release.1"
+	EventAssign           EventTypeE = "assign"
+	EventReleaseFinal     EventTypeE = "release.2"
+	EventDisplace         EventTypeE = "displace"
+	EventUnlock           EventTypeE = "unlock"
+	EventHealth           EventTypeE = "health"
+	EventTerminate        EventTypeE = "terminate"
+	EventLeave            EventTypeE = "leave"
+	EventDangerZoneStart  EventTypeE = "danger-zone.start"
+	EventDangerZoneExpire EventTypeE = "danger-zone.expire"
+	EventCameraBlock      EventTypeE = "camera.block"
+	EventCameraSnapshot   EventTypeE = "camera.snapshot"
+	EventCameraRelease    EventTypeE = "camera.release"
+)
+
+// Event.Kind is the name of the species of the traveler.
+type Event struct {
+	Seq      uint64          `json:"seq"`
+	Time     time.Time       `json:"time"`
+	Type     EventTypeE      `json:"event"`
+	Traveler TravelerId      `json:"traveler"`
+	Kind     string          `json:"kind,omitempty"`
+	X        int             `json:"x"`
+	Y        int             `json:"y"`
+	Health   *TravelerHealth `json:"hp,omitempty"`
+}
+
+type EventChannel chan Event
+
+// EventLog writes the events as JSON Lines in the order they were emitted.
+// Nodes emit an event before answering the request that caused it, so the
+// order of the log respects the order of the state transitions.
+// A nil *EventLog discards all events. Once an event cannot be written the
+// following ones are dropped and the error is kept for Simulation.Stop.
+type EventLog struct {
+	nextSeq      uint64
+	clock        Clock
+	encoder      *json.Encoder
+	channel      EventChannel
+	abortChannel chan struct{}
+	done         chan struct{}
+	err          error
+}
+
+func newEventLog(writer io.Writer, clock Clock, queueSize int) *EventLog {
+	return &EventLog{
+		nextSeq:      0,
+		clock:        clock,
+		encoder:      json.NewEncoder(writer),
+		channel:      make(EventChannel, queueSize),
+		abortChannel: make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+}
+
+func (eventLog *EventLog) start(waitGroup *sync.WaitGroup) {
+	defer waitGroup.Done()
+	defer close(eventLog.done)
+
+	for {
+		select {
+		case event, ok := <-eventLog.channel:
+			if !ok {
+				return
+			}
+			eventLog.write(event)
+
+		case <-eventLog.abortChannel:
+			// the events emitted before the abort are still written
+			for {
+				select {
+				case event := <-eventLog.channel:
+					eventLog.write(event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (eventLog *EventLog) write(event Event) {
+	event.Seq = eventLog.nextSeq
+	eventLog.nextSeq++
+
+	if eventLog.err != nil {
+		return
+	}
+	if err := eventLog.encoder.Encode(event); err != nil {
+		eventLog.err = fmt.Errorf("cannot write the event log - %w", err)
+	}
+}
+
+// stop may only be called once no actor emits events anymore.
+func (eventLog *EventLog) stop() {
+	if eventLog != nil {
+		close(eventLog.channel)
+	}
+}
+
+// abort stops the event log while the actors may still be emitting, the
+// events they emit from then on are dropped. It returns once the events
+// emitted before are written.
+func (eventLog *EventLog) abort() {
+	if eventLog != nil {
+		close(eventLog.abortChannel)
+		<-eventLog.done
+	}
+}
+
+// error may only be called once the event log has stopped.
+func (eventLog *EventLog) error() error {
+	if eventLog == nil {
+		return nil
+	}
+	return eventLog.err
+}
+
+func (eventLog *EventLog) emit(event Event) {
+	if eventLog == nil {
+		return
+	}
+
+	event.Time = eventLog.clock.now()
+	select {
+	case eventLog.channel <- event:
+	case <-eventLog.abortChannel:
+	}
+}
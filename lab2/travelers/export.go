@@ -0,0 +1,212 @@
+package travelers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Structures - Exporter
+
+// exportEncoder writes the pictures to the file as they are taken, finish
+// completes the file once there are no more of them.
+type exportEncoder interface {
+	encode(picture *Picture) error
+	finish() error
+}
+
+type newExportEncoderFunc func(file *os.File) exportEncoder
+
+var exportFormats = map[string]newExportEncoderFunc{
+	".gif": newGIFEncoder,
+	".svg": newSVGEncoder,
+}
+
+func exportFormat(path string) (newExportEncoderFunc, error) {
+	newEncoder, exists := exportFormats[strings.ToLower(filepath.Ext(path))]
+	if !exists {
+		return nil, fmt.Errorf("unknown export format of %q - must be .gif or .svg", path)
+	}
+	return newEncoder, nil
+}
+
+// the largest card which can be exported, so that a frame stays within a
+// few megapixels
+const maxExportSize = 100
+
+func validateExportSize(width int, height int) error {
+	if width > maxExportSize || height > maxExportSize {
+		return fmt.Errorf("cannot export a %dx%d card - must be at most %dx%d",
+			width, height, maxExportSize, maxExportSize)
+	}
+	return nil
+}
+
+// ValidateExport checks the format of the file and the size of the card
+// without creating the file.
+func ValidateExport(path string, width int, height int) error {
+	if _, err := exportFormat(path); err != nil {
+		return err
+	}
+	return validateExportSize(width, height)
+}
+
+// Exporter writes every picture of the camera to a file as it is taken,
+// the format is chosen by the file extension. The file is created by
+// NewExporter, so that a bad path is reported before the run starts.
+type Exporter struct {
+	path     string
+	file     *os.File
+	encoder  exportEncoder
+	mutex    sync.Mutex
+	pictures int
+	err      error
+}
+
+func NewExporter(path string) (*Exporter, error) {
+	newEncoder, err := exportFormat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Exporter{path: path, file: file, encoder: newEncoder(file)}, nil
+}
+
+// ObservePicture keeps the first error for Close, the pictures after it
+// are dropped.
+func (exporter *Exporter) ObservePicture(picture *Picture) {
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+
+	if exporter.err != nil {
+		return
+	}
+	if exporter.err = validateExportSize(picture.Width(), picture.Height()); exporter.err != nil {
+		return
+	}
+	exporter.err = exporter.encoder.encode(picture)
+	exporter.pictures++
+}
+
+// Close completes the file, so it should only be called once the
+// simulation has stopped. A run without pictures leaves no file.
+func (exporter *Exporter) Close() error {
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+
+	if exporter.pictures == 0 {
+		exporter.file.Close()
+		os.Remove(exporter.path)
+		if exporter.err != nil {
+			return exporter.err
+		}
+		return fmt.Errorf("no pictures to export to %q", exporter.path)
+	}
+
+	err := exporter.err
+	if err == nil {
+		err = exporter.encoder.finish()
+	}
+	if closeErr := exporter.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Layout of a picture in the exported images, in pixels. The gap between
+// the cells holds the motion lines of the travelers.
+
+const (
+	exportCellSize = 28
+	exportGapSize  = 8
+	exportMargin   = 8
+	exportHeader   = 16
+)
+
+// exportCellOrigin returns the top left corner of the cell, the odd rows of
+// a hex card are shifted by half a cell.
+func exportCellOrigin(picture *Picture, x int, y int) (int, int) {
+	left := exportMargin + x*(exportCellSize+exportGapSize)
+	if picture.Topology() == TopologyHex && y%2 == 1 {
+		left += (exportCellSize + exportGapSize) / 2
+	}
+	return left, exportHeader + exportMargin + y*(exportCellSize+exportGapSize)
+}
+
+func exportPictureSize(picture *Picture) (int, int) {
+	width := 2*exportMargin + picture.Width()*(exportCellSize+exportGapSize) - exportGapSize
+	if picture.Topology() == TopologyHex && picture.Height() > 1 {
+		width += (exportCellSize + exportGapSize) / 2
+	}
+	height := exportHeader + 2*exportMargin +
+		picture.Height()*(exportCellSize+exportGapSize) - exportGapSize
+	return width, height
+}
+
+// exportCellLabel is the text drawn in a cell, the same one as in the
+// ascii renderer.
+func exportCellLabel(cell PictureCell) string {
+	switch {
+	case cell.Wall:
+		return ""
+	case cell.DangerZone:
+		return dangerZoneMarker
+	case cell.TravelerId == NullTraveler:
+		return ""
+	case cell.Glyph != "":
+		return strings.Repeat(cell.Glyph, 2)
+	default:
+		return fmt.Sprintf("%02d", cell.TravelerId)
+	}
+}
+
+// exportMotionLine is a line of a blur trail, from a point inside the cell
+// over the edge into the gap (or the next cell on a hex card).
+type exportMotionLine struct {
+	x1, y1 int
+	x2, y2 int
+}
+
+// exportMotionLines returns the blur trails of the edges owned by the cell,
+// each one drawn as three parallel lines.
+func exportMotionLines(picture *Picture, x int, y int) []exportMotionLine {
+	cell := picture.Cell(x, y)
+	left, top := exportCellOrigin(picture, x, y)
+	right, bottom := left+exportCellSize, top+exportCellSize
+	centerX, centerY := left+exportCellSize/2, top+exportCellSize/2
+
+	// the lower neighbours of a hex node are half a cell to the side
+	diagonalX, antiDiagonalX := right, left
+	if picture.Topology() == TopologyHex {
+		diagonalX, antiDiagonalX = right-exportCellSize/4, left+exportCellSize/4
+	}
+
+	var lines []exportMotionLine
+	for _, offset := range []int{-6, 0, 6} {
+		if cell.HorizontalEdgeBlur {
+			lines = append(lines, exportMotionLine{
+				right - 4, centerY + offset, right + exportGapSize + 4, centerY + offset})
+		}
+		if cell.VerticalEdgeBlur {
+			lines = append(lines, exportMotionLine{
+				centerX + offset, bottom - 4, centerX + offset, bottom + exportGapSize + 4})
+		}
+		if cell.DiagonalEdgeBlur {
+			lines = append(lines, exportMotionLine{
+				diagonalX - 4 + offset, bottom - 4 - offset,
+				diagonalX + exportGapSize/2 + 4 + offset, bottom + exportGapSize/2 + 4 - offset})
+		}
+		if cell.AntiDiagonalEdgeBlur {
+			lines = append(lines, exportMotionLine{
+				antiDiagonalX + 4 + offset, bottom - 4 + offset,
+				antiDiagonalX - exportGapSize/2 - 4 + offset, bottom + exportGapSize/2 + 4 + offset})
+		}
+	}
+	return lines
+}
@@ -0,0 +1,238 @@
+package travelers
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"os"
+)
+
+const (
+	gifBackground uint8 = iota
+	gifGrid       uint8 = iota
+	gifText       uint8 = iota
+	gifTraveler   uint8 = iota
+	gifWild       uint8 = iota
+	gifDanger     uint8 = iota
+	gifMotion     uint8 = iota
+	gifWall       uint8 = iota
+)
+
+var gifPalette = color.Palette{
+	gifBackground: color.RGBA{0xff, 0xff, 0xff, 0xff},
+	gifGrid:       color.RGBA{0xc0, 0xc0, 0xc0, 0xff},
+	gifText:       color.RGBA{0x00, 0x00, 0x00, 0xff},
+	gifTraveler:   color.RGBA{0x9b, 0xe7, 0x9b, 0xff},
+	gifWild:       color.RGBA{0xe7, 0x9b, 0xe7, 0xff},
+	gifDanger:     color.RGBA{0xe7, 0x6b, 0x6b, 0xff},
+	gifMotion:     color.RGBA{0xf0, 0xa0, 0x20, 0xff},
+	gifWall:       color.RGBA{0x60, 0x60, 0x60, 0xff},
+}
+
+// delay between the frames in 100ths of a second
+const gifFrameDelay = 50
+
+// image/gif cannot draw text, so the labels use a tiny 3x5 bitmap font
+// scaled by gifGlyphScale.
+var gifGlyphs = map[rune][5]string{
+	'0': {"###", "#.#", "#.#", "#.#", "###"},
+	'1': {".#.", "##.", ".#.", ".#.", "###"},
+	'2': {"###", "..#", "###", "#..", "###"},
+	'3': {"###", "..#", "###", "..#", "###"},
+	'4': {"#.#", "#.#", "###", "..#", "..#"},
+	'5': {"###", "#..", "###", "..#", "###"},
+	'6': {"###", "#..", "###", "#.#", "###"},
+	'7': {"###", "..#", "..#", "..#", "..#"},
+	'8': {"###", "#.#", "###", "#.#", "###"},
+	'9': {"###", "#.#", "###", "..#", "###"},
+	'*': {"...", "#.#", ".#.", "#.#", "..."},
+	'#': {"#.#", "###", "#.#", "###", "#.#"},
+}
+
+// the species may have any printable glyph, those missing from the font are
+// drawn as a filled square
+var gifMissingGlyph = [5]string{"...", "###", "###", "###", "..."}
+
+const (
+	gifGlyphWidth  = 3
+	gifGlyphHeight = 5
+	gifGlyphScale  = 2
+)
+
+// the blocks written around the frames of image/gif to make an animation
+// which loops forever
+var (
+	gifLoopExtension = []byte{
+		0x21, 0xFF, 0x0B, 'N', 'E', 'T', 'S', 'C', 'A', 'P', 'E', '2', '.', '0', 0x03, 0x01, 0x00, 0x00, 0x00}
+	gifFrameExtension = []byte{0x21, 0xF9, 0x04, 0x00, gifFrameDelay, 0x00, 0x00, 0x00}
+)
+
+const gifTrailer byte = 0x3B
+
+// gifEncoder draws every picture as a single frame of an animation. It
+// encodes each frame as a GIF of its own with image/gif, which cannot
+// write the frames one at a time, and copies its image block into the
+// animation.
+type gifEncoder struct {
+	w       io.Writer
+	encoded bytes.Buffer
+	started bool
+}
+
+func newGIFEncoder(file *os.File) exportEncoder {
+	return &gifEncoder{w: file}
+}
+
+func (encoder *gifEncoder) encode(picture *Picture) error {
+	encoder.encoded.Reset()
+	if err := gif.Encode(&encoder.encoded, drawGIFPicture(picture), nil); err != nil {
+		return err
+	}
+
+	// the header, the screen descriptor and the global color table come
+	// first, the trailer last
+	encoded := encoder.encoded.Bytes()
+	headerSize := 13
+	if flags := encoded[10]; flags&0x80 != 0 {
+		headerSize += 3 << (flags&0x07 + 1)
+	}
+
+	if !encoder.started {
+		if _, err := encoder.w.Write(encoded[:headerSize]); err != nil {
+			return err
+		}
+		if _, err := encoder.w.Write(gifLoopExtension); err != nil {
+			return err
+		}
+		encoder.started = true
+	}
+
+	if _, err := encoder.w.Write(gifFrameExtension); err != nil {
+		return err
+	}
+	_, err := encoder.w.Write(encoded[headerSize : len(encoded)-1])
+	return err
+}
+
+func (encoder *gifEncoder) finish() error {
+	_, err := encoder.w.Write([]byte{gifTrailer})
+	return err
+}
+
+func drawGIFPicture(picture *Picture) *image.Paletted {
+	width, height := exportPictureSize(picture)
+	frame := image.NewPaletted(image.Rect(0, 0, width, height), gifPalette)
+
+	drawGIFText(frame, exportMargin, (exportHeader+exportMargin-gifGlyphHeight*gifGlyphScale)/2,
+		fmt.Sprint(picture.Number()))
+
+	for y := 0; y < picture.Height(); y++ {
+		for x := 0; x < picture.Width(); x++ {
+			cell := picture.Cell(x, y)
+			left, top := exportCellOrigin(picture, x, y)
+			cellRect := image.Rect(left, top, left+exportCellSize, top+exportCellSize)
+
+			switch {
+			case cell.Wall:
+				fillGIFRect(frame, cellRect, gifWall)
+			case cell.DangerZone:
+				fillGIFRect(frame, cellRect, gifDanger)
+			case cell.TravelerId == NullTraveler:
+			case cell.Glyph != "":
+				fillGIFRect(frame, cellRect, gifWild)
+			default:
+				fillGIFRect(frame, cellRect, gifTraveler)
+			}
+			strokeGIFRect(frame, cellRect, gifGrid)
+
+			// the color of the cell has to do for the ids too long to fit
+			label := exportCellLabel(cell)
+			labelWidth := len(label)*(gifGlyphWidth+1)*gifGlyphScale - gifGlyphScale
+			if labelWidth <= exportCellSize-2 {
+				drawGIFText(frame,
+					left+(exportCellSize-labelWidth)/2,
+					top+(exportCellSize-gifGlyphHeight*gifGlyphScale)/2,
+					label)
+			}
+
+		}
+	}
+
+	// the trails are drawn over all the cells
+	for y := 0; y < picture.Height(); y++ {
+		for x := 0; x < picture.Width(); x++ {
+			for _, line := range exportMotionLines(picture, x, y) {
+				drawGIFLine(frame, line, gifMotion)
+			}
+		}
+	}
+	return frame
+}
+
+// drawGIFLine draws a 2 pixels wide line, which must be horizontal,
+// vertical or diagonal.
+func drawGIFLine(frame *image.Paletted, line exportMotionLine, colorIndex uint8) {
+	dx, dy := gifSign(line.x2-line.x1), gifSign(line.y2-line.y1)
+	x, y := line.x1, line.y1
+	for {
+		fillGIFRect(frame, image.Rect(x, y, x+2, y+2), colorIndex)
+		if x == line.x2 && y == line.y2 {
+			return
+		}
+		x, y = x+dx, y+dy
+	}
+}
+
+func gifSign(value int) int {
+	switch {
+	case value > 0:
+		return 1
+	case value < 0:
+		return -1
+	}
+	return 0
+}
+
+func fillGIFRect(frame *image.Paletted, rect image.Rectangle, colorIndex uint8) {
+	rect = rect.Intersect(frame.Bounds())
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			frame.SetColorIndex(x, y, colorIndex)
+		}
+	}
+}
+
+func strokeGIFRect(frame *image.Paletted, rect image.Rectangle, colorIndex uint8) {
+	for x := rect.Min.X; x < rect.Max.X; x++ {
+		frame.SetColorIndex(x, rect.Min.Y, colorIndex)
+		frame.SetColorIndex(x, rect.Max.Y-1, colorIndex)
+	}
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		frame.SetColorIndex(rect.Min.X, y, colorIndex)
+		frame.SetColorIndex(rect.Max.X-1, y, colorIndex)
+	}
+}
+
+func drawGIFText(frame *image.Paletted, left int, top int, text string) {
+	for _, char := range text {
+		glyph, exists := gifGlyphs[char]
+		if !exists {
+			glyph = gifMissingGlyph
+		}
+		for row := range glyph {
+			for column, pixel := range glyph[row] {
+				if pixel != '#' {
+					continue
+				}
+
+				x, y := left+column*gifGlyphScale, top+row*gifGlyphScale
+				fillGIFRect(frame,
+					image.Rect(x, y, x+gifGlyphScale, y+gifGlyphScale), gifText)
+			}
+		}
+		left += (gifGlyphWidth + 1) * gifGlyphScale
+	}
+}
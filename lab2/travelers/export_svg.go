@@ -0,0 +1,120 @@
+package travelers
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"os"
+)
+
+const (
+	svgBackground = "#ffffff"
+	svgGrid       = "#c0c0c0"
+	svgWall       = "#606060"
+	svgText       = "#000000"
+	svgTraveler   = "#9be79b"
+	svgWild       = "#e79be7"
+	svgDanger     = "#e76b6b"
+	svgMotion     = "#f0a020"
+)
+
+// the width of the filmstrip is only known at the end, it is written over
+// a placeholder of as many digits in the header
+const (
+	svgWidthOffset = len(svgHeaderStart)
+	svgWidthDigits = 10
+	svgHeaderStart = "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\""
+)
+
+// svgEncoder draws the pictures next to each other as a filmstrip.
+type svgEncoder struct {
+	file       *os.File
+	buffer     *bufio.Writer
+	frameWidth int
+	pictures   int
+}
+
+func newSVGEncoder(file *os.File) exportEncoder {
+	return &svgEncoder{file: file, buffer: bufio.NewWriter(file)}
+}
+
+func (encoder *svgEncoder) encode(picture *Picture) error {
+	buffer := encoder.buffer
+	if encoder.pictures == 0 {
+		frameWidth, frameHeight := exportPictureSize(picture)
+		encoder.frameWidth = frameWidth
+		fmt.Fprintf(buffer, "%s%0*d\" height=\"%d\" font-family=\"monospace\" font-size=\"12\">\n",
+			svgHeaderStart, svgWidthDigits, 0, frameHeight)
+		fmt.Fprintf(buffer, "<rect width=\"100%%\" height=\"100%%\" fill=\"%s\"/>\n", svgBackground)
+	}
+
+	fmt.Fprintf(buffer, "<g transform=\"translate(%d,0)\">\n", encoder.pictures*encoder.frameWidth)
+	writeSVGPicture(buffer, picture)
+	fmt.Fprintln(buffer, "</g>")
+	encoder.pictures++
+
+	// a picture at a time, so that the file keeps up with the run
+	return buffer.Flush()
+}
+
+func (encoder *svgEncoder) finish() error {
+	fmt.Fprintln(encoder.buffer, "</svg>")
+	if err := encoder.buffer.Flush(); err != nil {
+		return err
+	}
+
+	width := fmt.Sprintf("%0*d", svgWidthDigits, encoder.pictures*encoder.frameWidth)
+	_, err := encoder.file.WriteAt([]byte(width), int64(svgWidthOffset))
+	return err
+}
+
+func writeSVGPicture(w io.Writer, picture *Picture) {
+	fmt.Fprintf(w, "<text x=\"%d\" y=\"%d\" fill=\"%s\">Picture: %d</text>\n",
+		exportMargin, exportMargin+exportHeader/2, svgText, picture.Number())
+
+	for y := 0; y < picture.Height(); y++ {
+		for x := 0; x < picture.Width(); x++ {
+			cell := picture.Cell(x, y)
+			left, top := exportCellOrigin(picture, x, y)
+
+			fill := svgBackground
+			switch {
+			case cell.Wall:
+				fill = svgWall
+			case cell.DangerZone:
+				fill = svgDanger
+			case cell.TravelerId == NullTraveler:
+			case cell.Glyph != "":
+				fill = svgWild
+			default:
+				fill = svgTraveler
+			}
+
+			fmt.Fprintf(w, "<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" "+
+				"fill=\"%s\" stroke=\"%s\"/>\n",
+				left, top, exportCellSize, exportCellSize, fill, svgGrid)
+
+			if label := exportCellLabel(cell); label != "" {
+				fmt.Fprintf(w, "<text x=\"%d\" y=\"%d\" fill=\"%s\" "+
+					"text-anchor=\"middle\" dominant-baseline=\"central\">%s</text>\n",
+					left+exportCellSize/2, top+exportCellSize/2, svgText, html.EscapeString(label))
+			}
+
+		}
+	}
+
+	// the trails are drawn over all the cells
+	for y := 0; y < picture.Height(); y++ {
+		for x := 0; x < picture.Width(); x++ {
+			for _, line := range exportMotionLines(picture, x, y) {
+				writeSVGMotionLine(w, line)
+			}
+		}
+	}
+}
+
+func writeSVGMotionLine(w io.Writer, line exportMotionLine) {
+	fmt.Fprintf(w, "<line x1=\"%d\" y1=\"%d\" x2=\"%d\" y2=\"%d\" "+
+		"stroke=\"%s\" stroke-width=\"2\"/>\n", line.x1, line.y1, line.x2, line.y2, svgMotion)
+}
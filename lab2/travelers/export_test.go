@@ -0,0 +1,145 @@
+package travelers
+
+import (
+	"encoding/xml"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func exportTestPictures(t *testing.T, path string, pictures int) {
+	t.Helper()
+
+	exporter, err := NewExporter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= pictures; i++ {
+		exporter.ObservePicture(newTestPicture(uint(i)))
+
+		// the file keeps up with the run
+		if info, err := os.Stat(path); err != nil || info.Size() == 0 {
+			t.Fatalf("picture %d not written: %v", i, err)
+		}
+	}
+	if err := exporter.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExportSVG(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.svg")
+	exportTestPictures(t, path, 3)
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var svg struct {
+		Width  int `xml:"width,attr"`
+		Height int `xml:"height,attr"`
+		Groups []struct {
+			Transform string `xml:"transform,attr"`
+		} `xml:"g"`
+	}
+	if err := xml.NewDecoder(file).Decode(&svg); err != nil {
+		t.Fatal(err)
+	}
+
+	frameWidth, frameHeight := exportPictureSize(newTestPicture(1))
+	if svg.Width != 3*frameWidth || svg.Height != frameHeight || len(svg.Groups) != 3 {
+		t.Errorf("%dx%d with %d pictures, expected %dx%d with 3",
+			svg.Width, svg.Height, len(svg.Groups), 3*frameWidth, frameHeight)
+	}
+}
+
+func TestExportGIF(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.gif")
+	exportTestPictures(t, path, 3)
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	animation, err := gif.DecodeAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(animation.Image) != 3 || animation.LoopCount != 0 {
+		t.Fatalf("%d frames looping %d times", len(animation.Image), animation.LoopCount)
+	}
+	for i, frame := range animation.Image {
+		expected := drawGIFPicture(newTestPicture(uint(i + 1)))
+		if animation.Delay[i] != gifFrameDelay || frame.Bounds() != expected.Bounds() ||
+			string(frame.Pix) != string(expected.Pix) {
+			t.Errorf("frame %d differs from its picture", i)
+		}
+	}
+}
+
+func TestExporterErrors(t *testing.T) {
+	directory := t.TempDir()
+
+	if _, err := NewExporter(filepath.Join(directory, "run.png")); err == nil ||
+		!strings.Contains(err.Error(), "unknown export format") {
+		t.Errorf("error %v, expected an unknown format", err)
+	}
+	if _, err := NewExporter(filepath.Join(directory, "missing", "run.gif")); err == nil {
+		t.Error("exported to a missing directory")
+	}
+
+	path := filepath.Join(directory, "empty.svg")
+	exporter, err := NewExporter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := exporter.Close(); err == nil || !strings.Contains(err.Error(), "no pictures") {
+		t.Errorf("error %v, expected no pictures", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("the file of a run without pictures is left: %v", err)
+	}
+
+	// the pictures of a card too large are dropped, not drawn
+	exporter, err = NewExporter(filepath.Join(directory, "large.gif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cells := make([][]PictureCell, maxExportSize+1)
+	for y := range cells {
+		cells[y] = []PictureCell{{TravelerId: NullTraveler}}
+	}
+	exporter.ObservePicture(&Picture{number: 1, cells: cells})
+	if err := exporter.Close(); err == nil || !strings.Contains(err.Error(), "cannot export a 1x101 card") {
+		t.Errorf("error %v, expected a card too large", err)
+	}
+}
+
+// TestGIFGlyphs draws the glyphs of the species missing from the font too.
+func TestGIFGlyphs(t *testing.T) {
+	for _, glyph := range []string{"*", "o", "W", "@"} {
+		picture := &Picture{number: 1, cells: [][]PictureCell{
+			{{TravelerId: 7, Kind: SpeciesWild, Glyph: glyph}},
+		}}
+		frame := drawGIFPicture(picture)
+
+		left, top := exportCellOrigin(picture, 0, 0)
+		textPixels := 0
+		for y := top; y < top+exportCellSize; y++ {
+			for x := left; x < left+exportCellSize; x++ {
+				if frame.ColorIndexAt(x, y) == gifText {
+					textPixels++
+				}
+			}
+		}
+		if textPixels == 0 {
+			t.Errorf("glyph %q not drawn", glyph)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package travelers
+
+import "sync"
+
+// Structures - TravelerIdManager
+
+type TravelerIdChannel chan TravelerId
+
+// TravelerIdRequest.id is the id given back by a releaseId request, which
+// has no response.
+type TravelerIdRequest struct {
+	request  TravelerIdRequestE
+	species  int
+	id       TravelerId
+	response TravelerIdChannel
+}
+
+func newTravelerIdRequest(species *Species) TravelerIdRequest {
+	return TravelerIdRequest{
+		request:  getId,
+		species:  species.index,
+		response: make(TravelerIdChannel, bufferSize),
+	}
+}
+
+func newTravelerIdRelease(species *Species, id TravelerId) TravelerIdRequest {
+	return TravelerIdRequest{request: releaseId, species: species.index, id: id}
+}
+
+type TravelerIdRequestChannel chan TravelerIdRequest
+
+// TravelerIdRange holds the ids of a single species, so there are never
+// more than MaxTravelers travelers of the species on the card.
+type TravelerIdRange struct {
+	nextId      TravelerId
+	maxId       TravelerId
+	releasedIds []TravelerId
+}
+
+// The ids of the travelers who left the card, expired or were killed are
+// given out again, the longest released one first.
+type TravelerIdManager struct {
+	ranges  []TravelerIdRange
+	channel TravelerIdRequestChannel
+}
+
+// Every species gets the ids following the ones of the species before it.
+func newTravelerIdManager(species []*Species, queueSize int) *TravelerIdManager {
+	ranges := make([]TravelerIdRange, len(species))
+	maxId := TravelerId(0)
+	for i, current := range species {
+		ranges[i] = TravelerIdRange{
+			nextId: maxId,
+			maxId:  maxId + TravelerId(current.MaxTravelers),
+		}
+		maxId = ranges[i].maxId
+	}
+
+	return &TravelerIdManager{
+		ranges:  ranges,
+		channel: make(TravelerIdRequestChannel, queueSize),
+	}
+}
+
+// release gives the id of a traveler who is gone back to the manager.
+func (travelerIdManager *TravelerIdManager) release(species *Species, id TravelerId) {
+	travelerIdManager.channel <- newTravelerIdRelease(species, id)
+}
+
+// stop may only be called once the nodes stopped spawning travelers.
+func (travelerIdManager *TravelerIdManager) stop() {
+	close(travelerIdManager.channel)
+}
+
+func (travelerIdManager *TravelerIdManager) start(waitGroup *sync.WaitGroup) {
+	defer waitGroup.Done()
+
+	for request := range travelerIdManager.channel {
+		idRange := &travelerIdManager.ranges[request.species]
+
+		switch request.request {
+		case getId:
+			if len(idRange.releasedIds) > 0 {
+				request.response <- idRange.releasedIds[0]
+				idRange.releasedIds = idRange.releasedIds[1:]
+			} else if idRange.nextId < idRange.maxId {
+				request.response <- idRange.nextId
+				idRange.nextId++
+			} else {
+				request.response <- NullTraveler
+			}
+
+		case releaseId:
+			idRange.releasedIds = append(idRange.releasedIds, request.id)
+		}
+	}
+}
@@ -0,0 +1,905 @@
+package travelers
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Type aliases
+
+type (
+	modelPcE   uint8
+	modelStepE uint8
+)
+
+// Constants
+
+const defaultModelMaxStates int = 2_000_000
+
+const ( // modelPcE
+	modelIdle modelPcE = iota
+	// a suspended reservation waits for the answer of the node
+	modelReserving
+	modelReleasing
+	modelAssigning
+	modelReleasingFinal
+	// a wild traveler between its attempts to make room
+	modelMakingRoom
+)
+
+var modelPcNames = map[modelPcE]string{
+	modelIdle:           "idle",
+	modelReserving:      "reserving",
+	modelReleasing:      "releasing",
+	modelAssigning:      "assigning",
+	modelReleasingFinal: "releasing final",
+	modelMakingRoom:     "making room",
+}
+
+const ( // modelStepE
+	modelStepReserve modelStepE = iota
+	modelStepAnswer
+	modelStepRelease
+	modelStepAssign
+	modelStepReleaseFinal
+	modelStepDisplacement
+	modelStepUnlock
+	modelStepCameraSend
+	modelStepCameraCollect
+)
+
+const modelNoCameraRequest NodeRequestE = 255
+
+// Structures - ModelConfig
+
+// ModelConfig describes the small card whose every interleaving the model
+// checker explores: the normal Travelers and then the WildTravelers stand
+// on the first nodes in row-major order, the camera keeps taking pictures
+// if Camera is set. The search gives up after MaxStates states (a default
+// limit if 0).
+type ModelConfig struct {
+	Width         int
+	Height        int
+	Topology      TopologyE
+	Travelers     int
+	WildTravelers int
+	Camera        bool
+	MaxStates     int
+}
+
+// ModelReport.Trace lists the steps leading from the initial state to the
+// Violation, which is empty if the model checker found none.
+type ModelReport struct {
+	States      int
+	Transitions int
+	Violation   string
+	Trace       []string
+}
+
+// Structures - ModelState
+
+type modelNode struct {
+	travelerState NodeTravelerStateE
+	cameraState   NodeCameraStateE
+	travelerId    TravelerId
+	isWaiting     bool
+	waiter        TravelerId
+}
+
+type modelRegion struct {
+	cameraState   NodeCameraStateE
+	pendingCamera NodeRequestE
+	movingNodes   int
+}
+
+// modelTraveler mirrors the control flow of Traveler.move and makeRoom,
+// remaining holds the neighbours a traveler making room has not tried yet.
+type modelTraveler struct {
+	pc            modelPcE
+	c             Coordinates
+	from          Coordinates
+	target        Coordinates
+	answered      bool
+	answer        NodeResponseE
+	makingRoom    bool
+	remaining     uint16
+	chain         []TravelerId
+	displacements []Displacement
+}
+
+type modelCameraAnswer struct {
+	sent      bool
+	answered  bool
+	response  NodeResponseE
+	travelers []TravelerId
+}
+
+// modelCamera mirrors TravelersCard.snapshot, it sends the request of
+// the phase to the regions one by one and then collects the answers.
+type modelCamera struct {
+	phase   NodeRequestE
+	answers []modelCameraAnswer
+}
+
+// modelState is everything the next steps depend on, the nodes and the
+// regions are restored into the real ones before every step.
+type modelState struct {
+	nodes     []modelNode
+	regions   []modelRegion
+	travelers []modelTraveler
+	camera    modelCamera
+}
+
+func (state *modelState) clone() modelState {
+	clone := modelState{
+		nodes:     slices.Clone(state.nodes),
+		regions:   slices.Clone(state.regions),
+		travelers: slices.Clone(state.travelers),
+		camera: modelCamera{
+			phase:   state.camera.phase,
+			answers: slices.Clone(state.camera.answers),
+		},
+	}
+	for i := range clone.travelers {
+		clone.travelers[i].chain = slices.Clone(clone.travelers[i].chain)
+		clone.travelers[i].displacements = slices.Clone(clone.travelers[i].displacements)
+	}
+	return clone
+}
+
+func (state *modelState) key() string {
+	return fmt.Sprintf("%v", *state)
+}
+
+type modelStep struct {
+	step     modelStepE
+	traveler int
+	target   Coordinates
+}
+
+// Structures - ModelChecker
+
+// modelChecker runs the real handlers of the nodes and the regions of a
+// simulation which is never started, the travelers and the camera are
+// replaced by the state machines of the model.
+type modelChecker struct {
+	config               ModelConfig
+	card                 *TravelersCard
+	species              []*Species
+	responses            []NodeTravelerResponseChannel
+	displacementChannels []DisplacementChannel
+	cameraResponses      []RegionCameraResponseChannel
+}
+
+// CheckModel explores every interleaving of the steps of the travelers and
+// the camera on the card of the config breadth-first, so the trace of a
+// violation is as short as possible. After every step it checks that the
+// nodes, the regions and the travelers agree on who is where, a picture
+// must show every traveler exactly once, on its node. A state in which
+// nothing can change anymore while some traveler or the camera waits is
+// a deadlock, and every state must lead back to one in which nobody waits.
+func CheckModel(config ModelConfig) (*ModelReport, error) {
+	if config.MaxStates == 0 {
+		config.MaxStates = defaultModelMaxStates
+	}
+	travelers := config.Travelers + config.WildTravelers
+	if config.Width*config.Height > 16 {
+		return nil, errors.New("invalid size - the card must have at most 16 nodes")
+	}
+	if config.Travelers < 0 || config.WildTravelers < 0 || travelers > config.Width*config.Height {
+		return nil, errors.New("invalid number of travelers - must fit on the card")
+	}
+
+	simulation, err := NewSimulation(Config{
+		Width:        config.Width,
+		Height:       config.Height,
+		MaxTravelers: max(config.Travelers, 1),
+		Probs:        NodeProbs{Spawn: 0.5, Move: 0.5, Wild: 0.5},
+		Topology:     config.Topology,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	checker := &modelChecker{config: config, card: simulation.card}
+	registry := checker.card.displacementRegistry
+	go registry.start(&simulation.servicesWaitGroup)
+	simulation.servicesWaitGroup.Add(1)
+	defer func() {
+		registry.stop()
+		simulation.servicesWaitGroup.Wait()
+	}()
+
+	initial := checker.initialState()
+	return checker.explore(initial)
+}
+
+func (checker *modelChecker) initialState() modelState {
+	card := checker.card
+	var normal, wild *Species
+	for _, species := range card.species {
+		switch species.Name {
+		case SpeciesNormal:
+			normal = species
+		case SpeciesWild:
+			wild = species
+		}
+	}
+
+	state := modelState{
+		nodes:   make([]modelNode, card.width*card.height),
+		regions: make([]modelRegion, len(card.regions)),
+		camera: modelCamera{
+			phase:   cameraBlockNode,
+			answers: make([]modelCameraAnswer, len(card.regions)),
+		},
+	}
+	for i := range state.nodes {
+		state.nodes[i] = modelNode{travelerId: NullTraveler, waiter: NullTraveler}
+	}
+	for i := range state.regions {
+		state.regions[i].pendingCamera = modelNoCameraRequest
+	}
+	checker.cameraResponses = make([]RegionCameraResponseChannel, len(card.regions))
+	for i := range checker.cameraResponses {
+		checker.cameraResponses[i] = make(RegionCameraResponseChannel, 1)
+	}
+
+	for i := 0; i < checker.config.Travelers+checker.config.WildTravelers; i++ {
+		species := normal
+		var displacementChannel DisplacementChannel
+		if i >= checker.config.Travelers {
+			species = wild
+			displacementChannel = make(DisplacementChannel, bufferSize)
+			card.displacementRegistry.register(TravelerId(i), displacementChannel)
+		}
+
+		checker.species = append(checker.species, species)
+		checker.responses = append(checker.responses, make(NodeTravelerResponseChannel, bufferSize))
+		checker.displacementChannels = append(checker.displacementChannels, displacementChannel)
+
+		c := Coordinates{i % card.width, i / card.width}
+		state.nodes[i] = modelNode{
+			travelerState: nodeOccupied,
+			travelerId:    TravelerId(i),
+			waiter:        NullTraveler,
+		}
+		state.travelers = append(state.travelers, modelTraveler{c: c})
+	}
+	return state
+}
+
+func (checker *modelChecker) explore(initial modelState) (*ModelReport, error) {
+	states := []modelState{initial}
+	parents := []int{-1}
+	labels := []string{""}
+	successors := [][]int{}
+	indices := map[string]int{initial.key(): 0}
+	report := &ModelReport{}
+
+	trace := func(i int) []string {
+		var trace []string
+		for ; parents[i] >= 0; i = parents[i] {
+			trace = append(trace, labels[i])
+		}
+		slices.Reverse(trace)
+		return trace
+	}
+
+	for i := 0; i < len(states); i++ {
+		state := states[i]
+		if violation := checker.checkInvariants(&state); violation != "" {
+			report.States = len(states)
+			report.Violation = violation
+			report.Trace = trace(i)
+			return report, nil
+		}
+
+		key := state.key()
+		changed := false
+		var next []int
+		for _, step := range checker.steps(&state) {
+			successor := state.clone()
+			checker.restore(&successor)
+			label, violation := checker.apply(&successor, step)
+			checker.capture(&successor)
+			report.Transitions++
+
+			successorKey := successor.key()
+			j, seen := indices[successorKey]
+			if !seen {
+				if len(states) >= checker.config.MaxStates {
+					return nil, fmt.Errorf("more than %d states", checker.config.MaxStates)
+				}
+				j = len(states)
+				indices[successorKey] = j
+				states = append(states, successor)
+				parents = append(parents, i)
+				labels = append(labels, label)
+			}
+			if violation != "" {
+				report.States = len(states)
+				report.Violation = violation
+				report.Trace = append(trace(i), label)
+				return report, nil
+			}
+
+			changed = changed || successorKey != key
+			next = append(next, j)
+		}
+		successors = append(successors, next)
+
+		if !changed && !checker.isIdle(&state) {
+			report.States = len(states)
+			report.Violation = "deadlock - nothing can change anymore:\n" + checker.describe(&state)
+			report.Trace = trace(i)
+			return report, nil
+		}
+	}
+
+	report.States = len(states)
+	if stuck := checker.findStuckState(states, successors); stuck >= 0 {
+		report.Violation = "livelock - the travelers and the camera can never all be idle again:\n" +
+			checker.describe(&states[stuck])
+		report.Trace = trace(stuck)
+	}
+	return report, nil
+}
+
+// findStuckState returns the first state (in the order of the search)
+// which cannot lead to a state in which nobody waits, or -1.
+func (checker *modelChecker) findStuckState(states []modelState, successors [][]int) int {
+	predecessors := make([][]int, len(states))
+	for i, next := range successors {
+		for _, j := range next {
+			predecessors[j] = append(predecessors[j], i)
+		}
+	}
+
+	reaches := make([]bool, len(states))
+	var queue []int
+	for i := range states {
+		if checker.isIdle(&states[i]) {
+			reaches[i] = true
+			queue = append(queue, i)
+		}
+	}
+	for len(queue) > 0 {
+		j := queue[0]
+		queue = queue[1:]
+		for _, i := range predecessors[j] {
+			if !reaches[i] {
+				reaches[i] = true
+				queue = append(queue, i)
+			}
+		}
+	}
+
+	return slices.Index(reaches, false)
+}
+
+// isIdle tells whether no traveler is in the middle of a move or has to
+// make room and the camera is between two pictures.
+func (checker *modelChecker) isIdle(state *modelState) bool {
+	for _, traveler := range state.travelers {
+		if traveler.pc != modelIdle || len(traveler.displacements) > 0 {
+			return false
+		}
+	}
+	if checker.config.Camera {
+		for _, answer := range state.camera.answers {
+			if answer.sent {
+				return false
+			}
+		}
+		return state.camera.phase == cameraBlockNode
+	}
+	return true
+}
+
+func (checker *modelChecker) neighbours(c Coordinates) []Coordinates {
+	var neighbours []Coordinates
+	for _, link := range checker.card.topology.neighbours(c) {
+		neighbours = append(neighbours, link.c)
+	}
+	return neighbours
+}
+
+func (checker *modelChecker) steps(state *modelState) []modelStep {
+	var steps []modelStep
+	for i, traveler := range state.travelers {
+		switch traveler.pc {
+		case modelIdle:
+			if checker.species[i].Displaceable {
+				if len(traveler.displacements) > 0 {
+					steps = append(steps, modelStep{step: modelStepDisplacement, traveler: i})
+				}
+				continue
+			}
+			for _, c := range checker.neighbours(traveler.c) {
+				steps = append(steps, modelStep{step: modelStepReserve, traveler: i, target: c})
+			}
+
+		case modelMakingRoom:
+			if traveler.remaining == 0 {
+				steps = append(steps, modelStep{step: modelStepUnlock, traveler: i})
+				continue
+			}
+			for k, c := range checker.neighbours(traveler.c) {
+				if traveler.remaining&(1<<k) != 0 {
+					steps = append(steps, modelStep{step: modelStepReserve, traveler: i, target: c})
+				}
+			}
+
+		case modelReserving:
+			if traveler.answered {
+				steps = append(steps, modelStep{step: modelStepAnswer, traveler: i})
+			}
+
+		case modelReleasing:
+			steps = append(steps, modelStep{step: modelStepRelease, traveler: i})
+
+		case modelAssigning:
+			steps = append(steps, modelStep{step: modelStepAssign, traveler: i})
+
+		case modelReleasingFinal:
+			steps = append(steps, modelStep{step: modelStepReleaseFinal, traveler: i})
+		}
+	}
+
+	if checker.config.Camera {
+		camera := &state.camera
+		next := slices.IndexFunc(camera.answers, func(answer modelCameraAnswer) bool {
+			return !answer.sent
+		})
+		if next >= 0 {
+			steps = append(steps, modelStep{step: modelStepCameraSend, traveler: next})
+		} else if !slices.ContainsFunc(camera.answers, func(answer modelCameraAnswer) bool {
+			return !answer.answered
+		}) {
+			steps = append(steps, modelStep{step: modelStepCameraCollect})
+		}
+	}
+	return steps
+}
+
+// restore makes the nodes and the regions of the card match the state,
+// the channels are all empty in between the steps.
+func (checker *modelChecker) restore(state *modelState) {
+	card := checker.card
+	for i, model := range state.nodes {
+		node := card.grid[i/card.width][i%card.width]
+		node.travelerState = model.travelerState
+		node.cameraState = model.cameraState
+		node.travelerId = model.travelerId
+		node.species = nil
+		if model.travelerId != NullTraveler {
+			node.species = checker.species[model.travelerId]
+		}
+		node.isWaiting = model.isWaiting
+		node.waitingRequest = NodeRequest{}
+		if model.isWaiting {
+			node.waitingRequest = checker.newRequest(
+				state, int(model.waiter), travelerReserveNode, state.travelers[model.waiter].c)
+		}
+		node.isDoomed = false
+		node.isStopped = false
+		node.isPaused = false
+		node.dangerZone = dangerZoneNotActive
+		node.pendingDangerZone = dangerZoneNotActive
+		node.dangerChannel = nil
+		node.edgeBlur = 0
+	}
+
+	for i, model := range state.regions {
+		region := card.regions[i]
+		region.cameraState = model.cameraState
+		region.movingNodes = model.movingNodes
+		region.pendingCamera = nil
+		if model.pendingCamera != modelNoCameraRequest {
+			region.pendingCamera = &RegionCameraRequest{
+				request:  model.pendingCamera,
+				response: checker.cameraResponses[i],
+			}
+		}
+	}
+}
+
+// capture reads the nodes and the regions of the card back into the state
+// and takes the answers which arrived during the step.
+func (checker *modelChecker) capture(state *modelState) {
+	card := checker.card
+	for i := range state.nodes {
+		node := card.grid[i/card.width][i%card.width]
+		state.nodes[i] = modelNode{
+			travelerState: node.travelerState,
+			cameraState:   node.cameraState,
+			travelerId:    node.travelerId,
+			isWaiting:     node.isWaiting,
+			waiter:        NullTraveler,
+		}
+		if node.isWaiting {
+			state.nodes[i].waiter = node.waitingRequest.travelerData.id
+		}
+	}
+
+	for i, region := range card.regions {
+		state.regions[i] = modelRegion{
+			cameraState:   region.cameraState,
+			pendingCamera: modelNoCameraRequest,
+			movingNodes:   region.movingNodes,
+		}
+		if region.pendingCamera != nil {
+			state.regions[i].pendingCamera = region.pendingCamera.request
+		}
+	}
+
+	for i := range state.travelers {
+		traveler := &state.travelers[i]
+		select {
+		case response := <-checker.responses[i]:
+			traveler.answered = true
+			traveler.answer = response
+		default:
+		}
+
+		if checker.species[i].Displaceable {
+			for len(checker.displacementChannels[i]) > 0 {
+				traveler.displacements = append(traveler.displacements, <-checker.displacementChannels[i])
+			}
+		}
+	}
+
+	for i := range state.camera.answers {
+		answer := &state.camera.answers[i]
+		select {
+		case response := <-checker.cameraResponses[i]:
+			answer.answered = true
+			answer.response = response.response
+			answer.travelers = nil
+			for _, node := range response.nodes {
+				answer.travelers = append(answer.travelers, node.travelerId)
+			}
+		default:
+		}
+	}
+}
+
+func (checker *modelChecker) newRequest(
+	state *modelState, i int, request NodeRequestE, c Coordinates,
+) NodeRequest {
+	traveler := &state.travelers[i]
+	nodeRequest := newNodeTravelerRequest(request, TravelerId(i), checker.species[i], c)
+	nodeRequest.travelerResponse = checker.responses[i]
+	nodeRequest.travelerData.clockActor = realClockActor{}
+	if request == travelerReserveNode && traveler.makingRoom {
+		nodeRequest.travelerData.chain = traveler.chain
+	}
+	return nodeRequest
+}
+
+// request sends the request of the traveler to the node at target, every
+// request is answered right away, if only by suspending it.
+func (checker *modelChecker) request(
+	state *modelState, i int, request NodeRequestE, target Coordinates, c Coordinates,
+) NodeResponseE {
+	nodeRequest := checker.newRequest(state, i, request, c)
+	node := checker.card.grid[target.y][target.x]
+	node.region.handleTravelerRequest(node, &nodeRequest, checker.card)
+	return <-nodeRequest.travelerResponse
+}
+
+func (checker *modelChecker) apply(state *modelState, step modelStep) (string, string) {
+	if step.step == modelStepCameraSend || step.step == modelStepCameraCollect {
+		return checker.applyCamera(state, step)
+	}
+
+	i := step.traveler
+	traveler := &state.travelers[i]
+	label := fmt.Sprintf("traveler %d at (%d,%d): ", i, traveler.c.x, traveler.c.y)
+	var response NodeResponseE
+
+	switch step.step {
+	case modelStepReserve:
+		traveler.target = step.target
+		response = checker.request(state, i, travelerReserveNode, step.target, traveler.c)
+		label += fmt.Sprintf("reserve (%d,%d)", step.target.x, step.target.y)
+		checker.reserved(state, i, response)
+
+	case modelStepAnswer:
+		response = traveler.answer
+		traveler.answered = false
+		label += fmt.Sprintf("answer of (%d,%d)", traveler.target.x, traveler.target.y)
+		checker.reserved(state, i, response)
+
+	case modelStepRelease:
+		response = checker.request(state, i, travelerReleaseNode, traveler.c, traveler.target)
+		label += "release"
+		if response == requestAccepted {
+			traveler.pc = modelAssigning
+		}
+
+	case modelStepAssign:
+		response = checker.request(state, i, travelerAssignNode, traveler.target, traveler.c)
+		label += fmt.Sprintf("assign (%d,%d)", traveler.target.x, traveler.target.y)
+		if response == requestAccepted {
+			traveler.from, traveler.c = traveler.c, traveler.target
+			traveler.pc = modelReleasingFinal
+		}
+
+	case modelStepReleaseFinal:
+		response = checker.request(state, i, travelerReleaseNode, traveler.from, traveler.c)
+		label += fmt.Sprintf("release (%d,%d) for good", traveler.from.x, traveler.from.y)
+		if response == requestAccepted {
+			traveler.pc = modelIdle
+			traveler.makingRoom = false
+			traveler.chain = nil
+		}
+
+	case modelStepDisplacement:
+		displacement := traveler.displacements[0]
+		traveler.displacements = traveler.displacements[1:]
+		label += fmt.Sprintf("asked to make room on (%d,%d)", displacement.c.x, displacement.c.y)
+		if displacement.c != traveler.c {
+			response = checker.request(state, i, travelerUnlockNode, displacement.c, traveler.c)
+			label += ", unlock it"
+			break
+		}
+
+		traveler.pc = modelMakingRoom
+		traveler.makingRoom = true
+		traveler.chain = append(slices.Clone(displacement.chain), TravelerId(i))
+		traveler.remaining = 1<<len(checker.neighbours(traveler.c)) - 1
+		return label, ""
+
+	case modelStepUnlock:
+		response = checker.request(state, i, travelerUnlockNode, traveler.c, traveler.c)
+		label += "unlock"
+		traveler.pc = modelIdle
+		traveler.makingRoom = false
+		traveler.chain = nil
+	}
+
+	label += " -> " + modelResponseName(response)
+	if response == terminateTraveler {
+		return label, fmt.Sprintf("traveler %d terminated without a danger zone", i)
+	}
+	return label, ""
+}
+
+// reserved moves the traveler on after the node answered its reservation.
+func (checker *modelChecker) reserved(state *modelState, i int, response NodeResponseE) {
+	traveler := &state.travelers[i]
+	switch response {
+	case requestAccepted:
+		traveler.pc = modelReleasing
+
+	case requestSuspended:
+		traveler.pc = modelReserving
+
+	default:
+		traveler.pc = modelIdle
+		if traveler.makingRoom {
+			traveler.pc = modelMakingRoom
+			k := slices.Index(checker.neighbours(traveler.c), traveler.target)
+			traveler.remaining &^= 1 << k
+		}
+	}
+}
+
+func (checker *modelChecker) applyCamera(state *modelState, step modelStep) (string, string) {
+	camera := &state.camera
+	phase := cameraRequestName(camera.phase)
+
+	if step.step == modelStepCameraSend {
+		request := &RegionCameraRequest{
+			request:  camera.phase,
+			response: checker.cameraResponses[step.traveler],
+		}
+		checker.card.regions[step.traveler].handleCameraRequest(request, checker.card)
+		camera.answers[step.traveler].sent = true
+		return fmt.Sprintf("camera: %s region %d", phase, step.traveler), ""
+	}
+
+	label := "camera: " + phase + " done"
+	for i, answer := range camera.answers {
+		if answer.response != requestAccepted {
+			return label, fmt.Sprintf("region %d denied the %s request of the camera", i, phase)
+		}
+	}
+	if camera.phase == cameraSnapshotNode {
+		if violation := checker.checkPicture(state); violation != "" {
+			return label, violation
+		}
+	}
+
+	camera.phase = map[NodeRequestE]NodeRequestE{
+		cameraBlockNode:    cameraDrainNode,
+		cameraDrainNode:    cameraSnapshotNode,
+		cameraSnapshotNode: cameraReleaseNode,
+		cameraReleaseNode:  cameraBlockNode,
+	}[camera.phase]
+	for i := range camera.answers {
+		camera.answers[i] = modelCameraAnswer{}
+	}
+	return label, ""
+}
+
+// checkPicture makes sure the picture shows every traveler once, on its
+// node, no traveler can be in the middle of a move once the card is drained.
+func (checker *modelChecker) checkPicture(state *modelState) string {
+	card := checker.card
+	pictured := make(map[TravelerId]Coordinates)
+	for i, answer := range state.camera.answers {
+		for j, id := range answer.travelers {
+			if id == NullTraveler {
+				continue
+			}
+			if _, seen := pictured[id]; seen {
+				return fmt.Sprintf("the picture shows traveler %d twice", id)
+			}
+			pictured[id] = card.regions[i].nodes[j].c
+		}
+	}
+
+	for i, traveler := range state.travelers {
+		c, seen := pictured[TravelerId(i)]
+		if !seen {
+			return fmt.Sprintf("the picture misses traveler %d", i)
+		}
+		if c != traveler.c {
+			return fmt.Sprintf("the picture shows traveler %d on (%d,%d) instead of (%d,%d)",
+				i, c.x, c.y, traveler.c.x, traveler.c.y)
+		}
+		if traveler.pc != modelIdle && traveler.pc != modelReserving && traveler.pc != modelMakingRoom {
+			return fmt.Sprintf("traveler %d is %s while the picture is taken", i, modelPcNames[traveler.pc])
+		}
+	}
+	return ""
+}
+
+// checkInvariants compares the nodes and the regions with what the
+// travelers think they hold.
+func (checker *modelChecker) checkInvariants(state *modelState) string {
+	card := checker.card
+	node := func(c Coordinates) *modelNode {
+		return &state.nodes[c.y*card.width+c.x]
+	}
+
+	for i, region := range card.regions {
+		moving := 0
+		for _, regionNode := range region.nodes {
+			if state := node(regionNode.c).travelerState; state == nodeReservedIn || state == nodeReservedOut {
+				moving++
+			}
+		}
+		if moving != state.regions[i].movingNodes {
+			return fmt.Sprintf("region %d counts %d moving nodes instead of %d",
+				i, state.regions[i].movingNodes, moving)
+		}
+	}
+
+	held := make([]int, len(state.travelers))
+	for i, model := range state.nodes {
+		c := Coordinates{i % card.width, i / card.width}
+		if (model.travelerState == nodeAvailable) != (model.travelerId == NullTraveler) {
+			return fmt.Sprintf("node (%d,%d) is %s by traveler %d",
+				c.x, c.y, nodeTravelerStateNames[model.travelerState], model.travelerId)
+		}
+		if model.travelerId != NullTraveler {
+			held[model.travelerId]++
+		}
+
+		if model.isWaiting {
+			if model.travelerId == NullTraveler || !checker.species[model.travelerId].Displaceable {
+				return fmt.Sprintf("node (%d,%d) waits for traveler %d, who cannot make room",
+					c.x, c.y, model.travelerId)
+			}
+			waiter := state.travelers[model.waiter]
+			if waiter.pc != modelReserving || waiter.target != c || waiter.answered {
+				return fmt.Sprintf("node (%d,%d) keeps the reservation of traveler %d, who is %s",
+					c.x, c.y, model.waiter, modelPcNames[waiter.pc])
+			}
+		}
+	}
+
+	expect := func(i int, c Coordinates, state NodeTravelerStateE) string {
+		if model := node(c); model.travelerId != TravelerId(i) || model.travelerState != state {
+			return fmt.Sprintf("traveler %d expects (%d,%d) %s by it, it is %s by %d",
+				i, c.x, c.y, nodeTravelerStateNames[state],
+				nodeTravelerStateNames[model.travelerState], model.travelerId)
+		}
+		return ""
+	}
+
+	for i, traveler := range state.travelers {
+		var violation string
+		holds := 1
+		switch traveler.pc {
+		case modelIdle, modelMakingRoom:
+			violation = expect(i, traveler.c, nodeOccupied)
+
+		case modelReserving:
+			violation = expect(i, traveler.c, nodeOccupied)
+			if violation == "" && traveler.answered && traveler.answer == requestAccepted {
+				violation = expect(i, traveler.target, nodeReservedIn)
+				holds = 2
+			}
+
+		case modelReleasing:
+			violation = expect(i, traveler.c, nodeOccupied)
+			if violation == "" {
+				violation = expect(i, traveler.target, nodeReservedIn)
+			}
+			holds = 2
+
+		case modelAssigning:
+			violation = expect(i, traveler.c, nodeReservedOut)
+			if violation == "" {
+				violation = expect(i, traveler.target, nodeReservedIn)
+			}
+			holds = 2
+
+		case modelReleasingFinal:
+			violation = expect(i, traveler.c, nodeOccupied)
+			if violation == "" {
+				violation = expect(i, traveler.from, nodeReservedOut)
+			}
+			holds = 2
+		}
+
+		if violation != "" {
+			return violation
+		}
+		if held[i] != holds {
+			return fmt.Sprintf("traveler %d holds %d nodes instead of %d", i, held[i], holds)
+		}
+	}
+	return ""
+}
+
+func (checker *modelChecker) describe(state *modelState) string {
+	var builder strings.Builder
+	for i, traveler := range state.travelers {
+		fmt.Fprintf(&builder, "  traveler %d (%s) at (%d,%d) is %s",
+			i, checker.species[i].Name, traveler.c.x, traveler.c.y, modelPcNames[traveler.pc])
+		if traveler.pc != modelIdle {
+			fmt.Fprintf(&builder, ", target (%d,%d)", traveler.target.x, traveler.target.y)
+		}
+		builder.WriteString("\n")
+	}
+	if checker.config.Camera {
+		fmt.Fprintf(&builder, "  camera in the %s phase\n", cameraRequestName(state.camera.phase))
+	}
+	return strings.TrimSuffix(builder.String(), "\n")
+}
+
+func modelResponseName(response NodeResponseE) string {
+	switch response {
+	case requestAccepted:
+		return "accepted"
+	case requestDenied:
+		return "denied"
+	case requestSuspended:
+		return "suspended"
+	case terminateTraveler:
+		return "terminated"
+	}
+	return "unknown"
+}
+
+func cameraRequestName(request NodeRequestE) string {
+	switch request {
+	case cameraBlockNode:
+		return "block"
+	case cameraDrainNode:
+		return "drain"
+	case cameraSnapshotNode:
+		return "snapshot"
+	case cameraReleaseNode:
+		return "release"
+	}
+	return "unknown"
+}
@@ -0,0 +1,99 @@
+package travelers
+
+import (
+	"flag"
+	"testing"
+)
+
+// the flags of TestCheckModelFlags, which checks a card of any size, e.g.
+// go test ./travelers -run CheckModelFlags -args -model.width 3 -model.wild 2
+var (
+	modelWidthFlag     = flag.Int("model.width", 0, "width of the card to check, the test is skipped without it")
+	modelHeightFlag    = flag.Int("model.height", 1, "height of the card to check")
+	modelTopologyFlag  = flag.String("model.topology", string(TopologyGrid), "topology of the card to check")
+	modelTravelersFlag = flag.Int("model.travelers", 1, "number of normal travelers")
+	modelWildFlag      = flag.Int("model.wild", 0, "number of wild travelers")
+	modelCameraFlag    = flag.Bool("model.camera", true, "keep the camera in the model")
+	modelMaxStatesFlag = flag.Int("model.max-states", 0, "give up after the given number of states")
+)
+
+func TestCheckModel(t *testing.T) {
+	tests := []struct {
+		name   string
+		config ModelConfig
+		long   bool
+	}{
+		{"normal travelers", ModelConfig{Width: 2, Height: 2, Travelers: 2, Camera: true}, false},
+		{"wild traveler", ModelConfig{Width: 2, Height: 2, Travelers: 1, WildTravelers: 1, Camera: true}, false},
+		{"wild travelers in a chain", ModelConfig{Width: 3, Height: 1, Travelers: 1, WildTravelers: 2}, false},
+		{"hex", ModelConfig{
+			Width: 2, Height: 2, Topology: TopologyHex, Travelers: 1, WildTravelers: 1, Camera: true,
+		}, false},
+		{"camera with a wild traveler", ModelConfig{
+			Width: 2, Height: 2, Travelers: 2, WildTravelers: 1, Camera: true,
+		}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.long && testing.Short() {
+				t.Skip("explores too many states for -short")
+			}
+			if test.long && raceEnabled {
+				t.Skip("explores too many states for -race")
+			}
+
+			checkModel(t, test.config)
+		})
+	}
+}
+
+func TestCheckModelFlags(t *testing.T) {
+	if *modelWidthFlag == 0 {
+		t.Skip("no -model.width given")
+	}
+
+	report := checkModel(t, ModelConfig{
+		Width:         *modelWidthFlag,
+		Height:        *modelHeightFlag,
+		Topology:      TopologyE(*modelTopologyFlag),
+		Travelers:     *modelTravelersFlag,
+		WildTravelers: *modelWildFlag,
+		Camera:        *modelCameraFlag,
+		MaxStates:     *modelMaxStatesFlag,
+	})
+	t.Logf("%d state(s), %d transition(s)", report.States, report.Transitions)
+}
+
+// checkModel fails the test with the trace of the violation found.
+func checkModel(t *testing.T, config ModelConfig) *ModelReport {
+	t.Helper()
+
+	report, err := CheckModel(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Violation != "" {
+		t.Errorf("%s", report.Violation)
+		for i, step := range report.Trace {
+			t.Logf("%4d. %s", i+1, step)
+		}
+	}
+	if report.States < 2 {
+		t.Errorf("only %d states explored", report.States)
+	}
+	return report
+}
+
+func TestCheckModelInvalidConfig(t *testing.T) {
+	configs := []ModelConfig{
+		{Width: 5, Height: 5, Travelers: 1},
+		{Width: 2, Height: 2, Travelers: 3, WildTravelers: 2},
+		{Width: 2, Height: 2, Travelers: -1},
+	}
+	for _, config := range configs {
+		if _, err := CheckModel(config); err == nil {
+			t.Errorf("%+v accepted", config)
+		}
+	}
+}
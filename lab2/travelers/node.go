@@ -0,0 +1,508 @@
+package travelers
+
+import (
+	"context"
+	"math/rand"
+	"slices"
+)
+
+// Structures - Node::Requests
+
+type NodeRequest struct {
+	request          NodeRequestE
+	travelerData     NodeTravelerRequestData
+	travelerResponse NodeTravelerResponseChannel
+}
+
+func newNodeTravelerRequest(
+	request NodeRequestE, id TravelerId, species *Species, c Coordinates,
+) NodeRequest {
+	return NodeRequest{
+		request: request,
+		travelerData: NodeTravelerRequestData{
+			id:      id,
+			species: species,
+			c:       c,
+		},
+		travelerResponse: make(NodeTravelerResponseChannel, bufferSize),
+	}
+}
+
+type NodeCameraResponse struct {
+	travelerId TravelerId
+	species    *Species
+	dangerZone bool
+	wall       bool
+	edgeBlur   EdgeE
+}
+
+type NodeTravelerResponseChannel chan NodeResponseE
+
+// NodeTravelerRequestData.dangerChannel and clockActor are only set for
+// a reservation, chain lists the displaceable travelers which are making
+// room for each other, the traveler sending the request being the last.
+type NodeTravelerRequestData struct {
+	id            TravelerId
+	species       *Species
+	c             Coordinates
+	dangerChannel DangerChannel
+	clockActor    ClockActor
+	chain         []TravelerId
+}
+
+// Structures - Node
+
+// Node is only ever accessed by the goroutine of its region. A traveler
+// is doomed once a danger zone started on its node, the node keeps it until
+// the traveler learns of it and answers its next request with termination.
+// A waiting node keeps the reservation its traveler makes room for and
+// answers it once the traveler is gone or gave up, a stopped traveler is
+// not asked to make room anymore. A paused node lets nothing new happen on
+// it until the Controller resumes it.
+type Node struct {
+	c                 Coordinates
+	dangerZone        DangerZone
+	pendingDangerZone DangerZone
+	cameraState       NodeCameraStateE
+	travelerState     NodeTravelerStateE
+	isWaiting         bool
+	waitingRequest    NodeRequest
+	isDoomed          bool
+	isStopped         bool
+	isPaused          bool
+	travelerId        TravelerId
+	species           *Species
+	dangerChannel     DangerChannel
+	edgeBlur          EdgeE
+	region            *Region
+	statistics        NodeStatistics
+}
+
+func newNode(c Coordinates, region *Region) *Node {
+	return &Node{
+		c:                 c,
+		dangerZone:        dangerZoneNotActive,
+		pendingDangerZone: dangerZoneNotActive,
+		cameraState:       nodeRunning,
+		travelerState:     nodeAvailable,
+		isWaiting:         false,
+		isDoomed:          false,
+		isStopped:         false,
+		isPaused:          false,
+		travelerId:        NullTraveler,
+		edgeBlur:          0,
+		region:            region,
+	}
+}
+
+// NodeProbs.Spawn, Move and Wild make up the default species, an empty
+// node which did not spawn a traveler starts a danger zone with Danger.
+// The Controller changes them for the species named normal and wild.
+type NodeProbs struct {
+	Spawn  float64 `json:"spawn_prob"`
+	Move   float64 `json:"move_prob"`
+	Wild   float64 `json:"wild_prob"`
+	Danger float64 `json:"danger_prob"`
+}
+
+// send passes the request to the goroutine of the region of the node.
+func (node *Node) send(request NodeRequest) {
+	node.region.requestChannel <- RegionRequest{node: node, travelerRequest: request}
+}
+
+func (node *Node) tick(ctx context.Context, card *TravelersCard, rng *rand.Rand) {
+	node.statistics.ticks++
+	if node.hasTraveler() {
+		node.statistics.occupiedTicks++
+	}
+
+	if node.cameraState != nodeRunning || node.isPaused || card.topology.isWall(node.c) {
+		return
+	}
+
+	// the danger zone of a doomed traveler lasts until the traveler is gone
+	if node.dangerZone.active() && !node.isDoomed {
+		node.dangerZone--
+		if !node.dangerZone.active() {
+			card.logEvent(EventDangerZoneExpire, NullTraveler, nil, node.c)
+		}
+	}
+
+	if node.pendingDangerZone.active() {
+		node.startDangerZone(card, node.pendingDangerZone)
+		node.pendingDangerZone = dangerZoneNotActive
+	}
+
+	// a spread danger zone lasts as long as the one it spread from still
+	// does, so it cannot spread forever
+	if node.dangerZone > 0 && card.dangerZoneSpread > 0 && rng.Float64() < card.dangerZoneSpread {
+		if neighbours := card.topology.neighbours(node.c); len(neighbours) > 0 {
+			neighbour := neighbours[rng.Intn(len(neighbours))].c
+			node.region.spreadDangerZone(card.grid[neighbour.y][neighbour.x], node.dangerZone)
+		}
+	}
+
+	if node.travelerState != nodeAvailable || node.dangerZone.active() || card.isExit(node.c) {
+		return
+	}
+
+	probs := node.region.probs
+	for _, species := range card.species {
+		if rng.Float64() >= probs.spawn[species.index] {
+			continue
+		}
+
+		node.spawnTraveler(ctx, card, species, rng)
+		return
+	}
+
+	if rng.Float64() < probs.danger {
+		node.startDangerZone(card, card.dangerZoneDuration)
+		for _, c := range card.topology.dangerZoneCells(node.c, card.dangerZoneShape)[1:] {
+			node.region.spreadDangerZone(card.grid[c.y][c.x], card.dangerZoneDuration)
+		}
+	}
+}
+
+// spawnTraveler puts a new traveler of the species on the empty node, it
+// returns NullTraveler if there are as many travelers of the species as
+// it allows.
+func (node *Node) spawnTraveler(
+	ctx context.Context, card *TravelersCard, species *Species, rng *rand.Rand,
+) TravelerId {
+	travelerIdRequest := newTravelerIdRequest(species)
+	card.travelerIdManager.channel <- travelerIdRequest
+
+	travelerId := <-travelerIdRequest.response
+	if travelerId == NullTraveler {
+		return NullTraveler
+	}
+
+	newTraveler := newTraveler(travelerId, node.c, species, card.clock.join(), rng.Int63())
+	newTraveler.moveProb = node.region.probs.move[species.index]
+	newTraveler.spawnNumber = card.spawnCount.Add(1)
+	if species.Displaceable {
+		card.displacementRegistry.register(travelerId, newTraveler.displacementChannel)
+	}
+
+	node.travelerState = nodeOccupied
+	node.travelerId = travelerId
+	node.species = species
+	node.dangerChannel = newTraveler.dangerChannel
+	card.logEvent(EventSpawn, travelerId, species, node.c)
+
+	card.waitGroup.Add(1)
+	go newTraveler.start(ctx, card)
+	return travelerId
+}
+
+// addDangerZone makes the node start the danger zone on its next tick.
+func (node *Node) addDangerZone(duration DangerZone) {
+	node.pendingDangerZone = max(node.pendingDangerZone, duration)
+}
+
+// startDangerZone starts a danger zone lasting duration ticks on an empty
+// node or extends the active one. On an occupied node it only starts if the
+// danger zones kill the travelers standing on their nodes, dooming the
+// traveler unless its species survives danger zones. An exit never gets
+// a danger zone, so the travelers can always flee through it.
+func (node *Node) startDangerZone(card *TravelersCard, duration DangerZone) {
+	if card.topology.isWall(node.c) || card.isExit(node.c) {
+		return
+	}
+
+	if node.dangerZone.active() {
+		node.dangerZone = max(node.dangerZone, duration)
+		return
+	}
+
+	switch node.travelerState {
+	case nodeAvailable:
+	case nodeOccupied:
+		if !card.dangerZonesKill || node.species.SurvivesDangerZones {
+			return
+		}
+	default:
+		return
+	}
+
+	node.dangerZone = duration
+	card.logEvent(EventDangerZoneStart, NullTraveler, nil, node.c)
+
+	if node.travelerState == nodeOccupied {
+		node.isDoomed = true
+		node.dangerChannel <- node.c
+	}
+}
+
+// killDoomedTraveler answers the request of a doomed traveler, the danger
+// zone stays on the node.
+func (node *Node) killDoomedTraveler(request *NodeRequest, card *TravelersCard) {
+	card.logEvent(EventTerminate, request.travelerData.id, request.travelerData.species, node.c)
+	request.travelerResponse <- terminateTraveler
+	node.removeTraveler(card)
+}
+
+func (node *Node) hasMovement() bool {
+	return node.travelerState == nodeReservedIn || node.travelerState == nodeReservedOut
+}
+
+func (node *Node) hasTraveler() bool {
+	return node.travelerState == nodeOccupied || node.travelerState == nodeReservedOut
+}
+
+func (node *Node) reset(card *TravelersCard) {
+	node.dangerZone = dangerZoneNotActive
+	node.removeTraveler(card)
+}
+
+func (node *Node) removeTraveler(card *TravelersCard) {
+	node.travelerState = nodeAvailable
+	node.isDoomed = false
+	node.isStopped = false
+	node.travelerId = NullTraveler
+	node.species = nil
+	node.dangerChannel = nil
+	node.answerWaitingRequest(card)
+}
+
+func (node *Node) reserve(request *NodeRequest, card *TravelersCard) {
+	card.logEvent(EventReserve, request.travelerData.id, request.travelerData.species, node.c)
+	request.travelerResponse <- requestAccepted
+	node.travelerState = nodeReservedIn
+	node.travelerId = request.travelerData.id
+	node.species = request.travelerData.species
+	node.dangerChannel = request.travelerData.dangerChannel
+}
+
+// answerWaitingRequest grants the reservation the node was waiting with if
+// its traveler has made room by now and denies it otherwise. The waiting
+// traveler is unparked first, so it runs right after the one answering.
+func (node *Node) answerWaitingRequest(card *TravelersCard) {
+	if !node.isWaiting {
+		return
+	}
+
+	request := node.waitingRequest
+	node.isWaiting = false
+	node.waitingRequest = NodeRequest{}
+
+	request.travelerData.clockActor.unpark()
+	if node.travelerState == nodeAvailable && node.cameraState == nodeRunning && !node.isPaused {
+		node.reserve(&request, card)
+	} else {
+		node.statistics.deniedReserves++
+		request.travelerResponse <- requestDenied
+	}
+}
+
+func (node *Node) block(card *TravelersCard) {
+	node.cameraState = nodeBlocekd
+	card.logEvent(EventCameraBlock, NullTraveler, nil, node.c)
+}
+
+// snapshot freezes the node, so it may only be called once no move is in
+// progress on it.
+func (node *Node) snapshot(card *TravelersCard) NodeCameraResponse {
+	node.cameraState = nodeFrozen
+	response := NodeCameraResponse{
+		dangerZone: node.dangerZone.active(),
+		wall:       card.topology.isWall(node.c),
+		edgeBlur:   node.edgeBlur,
+	}
+
+	if node.dangerZone.active() || !node.hasTraveler() {
+		response.travelerId = NullTraveler
+	} else {
+		response.travelerId = node.travelerId
+		response.species = node.species
+	}
+
+	node.edgeBlur = 0
+
+	card.logEvent(EventCameraSnapshot, response.travelerId, response.species, node.c)
+	return response
+}
+
+func (node *Node) release(card *TravelersCard) {
+	card.logEvent(EventCameraRelease, NullTraveler, nil, node.c)
+	node.cameraState = nodeRunning
+}
+
+func (node *Node) handleTravelerRequest(request *NodeRequest, card *TravelersCard) {
+	// a blocked node still lets the moves in progress finish, unlocking and
+	// stopping a node do not change its picture
+	if (node.cameraState == nodeFrozen && request.request != travelerUnlockNode &&
+		request.request != travelerStopNode) ||
+		(node.cameraState == nodeBlocekd && request.request == travelerReserveNode) ||
+		(node.isPaused &&
+			(request.request == travelerReserveNode || request.request == travelerLeaveNode)) {
+		node.statistics.blockedRequests++
+		request.travelerResponse <- requestDenied
+		return
+	}
+
+	switch request.request {
+	case travelerReserveNode:
+		node.handleTravelerReserveRequest(request, card)
+
+	case travelerAssignNode:
+		node.handleTravelerAssignRequest(request, card)
+
+	case travelerReleaseNode:
+		node.handleTravelerReleaseRequest(request, card)
+
+	case travelerLeaveNode:
+		node.handleTravelerLeaveRequest(request, card)
+
+	case travelerUnlockNode:
+		if node.isWaiting && node.travelerId == request.travelerData.id {
+			card.logEvent(EventUnlock, request.travelerData.id, request.travelerData.species, node.c)
+			request.travelerResponse <- requestAccepted
+			node.answerWaitingRequest(card)
+		} else {
+			request.travelerResponse <- requestDenied
+		}
+
+	case travelerStopNode:
+		if node.hasTraveler() && node.travelerId == request.travelerData.id {
+			request.travelerResponse <- requestAccepted
+			node.isStopped = true
+			node.answerWaitingRequest(card)
+		} else {
+			request.travelerResponse <- requestDenied
+		}
+
+	default:
+		request.travelerResponse <- requestDenied
+	}
+}
+
+func (node *Node) handleTravelerReserveRequest(request *NodeRequest, card *TravelersCard) {
+	if node.travelerState == nodeAvailable {
+		node.reserve(request, card)
+		return
+	}
+
+	if node.displaceableBy(&request.travelerData) {
+		if displacementChannel, exists := card.displacementRegistry.lookup(node.travelerId); exists {
+			node.isWaiting = true
+			node.waitingRequest = *request
+			card.logEvent(EventDisplace, node.travelerId, node.species, node.c)
+			displacementChannel <- Displacement{c: node.c, chain: request.travelerData.chain}
+			request.travelerResponse <- requestSuspended
+			return
+		}
+	}
+
+	node.statistics.deniedReserves++
+	request.travelerResponse <- requestDenied
+}
+
+// displaceableBy tells whether the traveler of the node is asked to make
+// room for the one reserving it. A displaceable traveler may only push
+// another one while making room itself, unless that one is already in its
+// chain, since the chain would then wait for itself. A doomed traveler is
+// not asked to make room anymore.
+func (node *Node) displaceableBy(data *NodeTravelerRequestData) bool {
+	if node.travelerState != nodeOccupied || node.isWaiting || node.isDoomed || node.isStopped ||
+		!node.species.Displaceable {
+		return false
+	}
+	if !data.species.Displaceable {
+		return true
+	}
+	return len(data.chain) > 0 && !slices.Contains(data.chain, node.travelerId)
+}
+
+// A traveler leaves the card only when no picture is being taken, so that
+// every picture either has it on the node or not.
+func (node *Node) handleTravelerLeaveRequest(request *NodeRequest, card *TravelersCard) {
+	if node.cameraState != nodeRunning || node.travelerState != nodeOccupied ||
+		node.travelerId != request.travelerData.id {
+		request.travelerResponse <- requestDenied
+		return
+	}
+
+	if node.isDoomed {
+		node.killDoomedTraveler(request, card)
+		return
+	}
+
+	card.logEvent(EventLeave, request.travelerData.id, request.travelerData.species, node.c)
+	request.travelerResponse <- requestAccepted
+	node.reset(card)
+}
+
+func (node *Node) handleTravelerAssignRequest(request *NodeRequest, card *TravelersCard) {
+	if node.travelerState != nodeReservedIn ||
+		node.travelerId != request.travelerData.id {
+		request.travelerResponse <- requestDenied
+		return
+	}
+
+	if node.dangerZone.active() && !request.travelerData.species.SurvivesDangerZones {
+		card.logEvent(EventTerminate, request.travelerData.id, request.travelerData.species, node.c)
+		card.logEvent(EventDangerZoneExpire, NullTraveler, nil, node.c)
+		request.travelerResponse <- terminateTraveler
+		node.reset(card)
+		return
+	}
+
+	if node.dangerZone.active() {
+		// a traveler who survives the danger zone uses it up all the same
+		card.logEvent(EventDangerZoneExpire, NullTraveler, nil, node.c)
+		node.dangerZone = dangerZoneNotActive
+	}
+
+	card.logEvent(EventAssign, request.travelerData.id, request.travelerData.species, node.c)
+	request.travelerResponse <- requestAccepted
+	node.travelerState = nodeOccupied
+	node.blurEdge(card, request.travelerData.c)
+}
+
+func (node *Node) blurEdge(card *TravelersCard, c Coordinates) {
+	if edge, owned := card.topology.edge(node.c, c); owned {
+		node.edgeBlur |= edge
+	}
+}
+
+func (node *Node) handleTravelerReleaseRequest(request *NodeRequest, card *TravelersCard) {
+	switch node.travelerState {
+	case nodeOccupied:
+		if node.travelerId == request.travelerData.id && node.isDoomed {
+			node.killDoomedTraveler(request, card)
+		} else if node.travelerId == request.travelerData.id {
+			card.logEvent(EventReleaseOut, request.travelerData.id, request.travelerData.species, node.c)
+			request.travelerResponse <- requestAccepted
+			node.travelerState = nodeReservedOut
+		} else {
+			request.travelerResponse <- requestDenied
+		}
+
+	case nodeReservedOut:
+		if node.travelerId == request.travelerData.id {
+			card.logEvent(EventReleaseFinal, request.travelerData.id, request.travelerData.species, node.c)
+			request.travelerResponse <- requestAccepted
+			node.blurEdge(card, request.travelerData.c)
+			node.reset(card)
+		} else {
+			request.travelerResponse <- requestDenied
+		}
+
+	case nodeReservedIn:
+		if node.travelerId == request.travelerData.id {
+			card.logEvent(EventCancel, request.travelerData.id, request.travelerData.species, node.c)
+			request.travelerResponse <- requestAccepted
+			node.travelerState = nodeAvailable
+			node.travelerId = NullTraveler
+			node.species = nil
+			node.dangerChannel = nil
+		} else {
+			request.travelerResponse <- requestDenied
+		}
+
+	case nodeAvailable:
+		request.travelerResponse <- requestDenied
+	}
+}
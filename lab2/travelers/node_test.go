@@ -0,0 +1,397 @@
+package travelers
+
+import (
+	"slices"
+	"sync"
+	"testing"
+)
+
+const (
+	owner    TravelerId = 1
+	stranger TravelerId = 2
+)
+
+var nodeCameraStateNames = map[NodeCameraStateE]string{
+	nodeRunning: "running",
+	nodeBlocekd: "blocked",
+	nodeFrozen:  "frozen",
+}
+
+// newTestCard returns the card of a simulation which is never started, only
+// its displacement registry runs.
+func newTestCard(t *testing.T) *TravelersCard {
+	t.Helper()
+
+	simulation, err := NewSimulation(Config{
+		Width:        3,
+		Height:       3,
+		MaxTravelers: 4,
+		Probs:        NodeProbs{Spawn: 0.5, Move: 0.5, Wild: 0.1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	card := simulation.card
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(1)
+	go card.displacementRegistry.start(&waitGroup)
+	t.Cleanup(func() {
+		card.displacementRegistry.stop()
+		waitGroup.Wait()
+	})
+	return card
+}
+
+func testSpecies(t *testing.T, card *TravelersCard, name string) *Species {
+	t.Helper()
+
+	for _, species := range card.species {
+		if species.Name == name {
+			return species
+		}
+	}
+	t.Fatalf("no species %s", name)
+	return nil
+}
+
+// newTestNode returns the middle node of the card in the states, held by
+// the owner of the species unless it is available.
+func newTestNode(
+	card *TravelersCard, state NodeTravelerStateE, cameraState NodeCameraStateE, species *Species,
+) *Node {
+	node := card.grid[1][1]
+	node.travelerState = state
+	node.cameraState = cameraState
+	if state != nodeAvailable {
+		node.travelerId = owner
+		node.species = species
+		node.dangerChannel = make(DangerChannel, 1)
+	}
+	return node
+}
+
+func newTestRequest(
+	request NodeRequestE, id TravelerId, species *Species, c Coordinates, chain ...TravelerId,
+) NodeRequest {
+	nodeRequest := newNodeTravelerRequest(request, id, species, c)
+	nodeRequest.travelerData.dangerChannel = make(DangerChannel, 1)
+	nodeRequest.travelerData.clockActor = realClockActor{}
+	nodeRequest.travelerData.chain = chain
+	return nodeRequest
+}
+
+// sendTravelerRequest returns the response of the node and whether the node
+// answered the request right away.
+func sendTravelerRequest(node *Node, card *TravelersCard, request NodeRequest) (NodeResponseE, bool) {
+	node.handleTravelerRequest(&request, card)
+
+	select {
+	case response := <-request.travelerResponse:
+		return response, true
+	default:
+		return requestDenied, false
+	}
+}
+
+func TestNodeTravelerRequests(t *testing.T) {
+	// the responses are the ones of a running, blocked and frozen node, the
+	// node keeps its state whenever a request is denied
+	type responses [3]NodeResponseE
+	const (
+		accepted = requestAccepted
+		denied   = requestDenied
+	)
+
+	tests := []struct {
+		state     NodeTravelerStateE
+		request   NodeRequestE
+		id        TravelerId
+		responses responses
+		after     NodeTravelerStateE
+	}{
+		{nodeAvailable, travelerReserveNode, stranger, responses{accepted, denied, denied}, nodeReservedIn},
+		{nodeAvailable, travelerAssignNode, stranger, responses{denied, denied, denied}, nodeAvailable},
+		{nodeAvailable, travelerReleaseNode, stranger, responses{denied, denied, denied}, nodeAvailable},
+		{nodeAvailable, travelerUnlockNode, stranger, responses{denied, denied, denied}, nodeAvailable},
+		{nodeAvailable, travelerLeaveNode, stranger, responses{denied, denied, denied}, nodeAvailable},
+		{nodeAvailable, travelerStopNode, stranger, responses{denied, denied, denied}, nodeAvailable},
+
+		{nodeReservedIn, travelerReserveNode, owner, responses{denied, denied, denied}, nodeReservedIn},
+		{nodeReservedIn, travelerReserveNode, stranger, responses{denied, denied, denied}, nodeReservedIn},
+		{nodeReservedIn, travelerAssignNode, owner, responses{accepted, accepted, denied}, nodeOccupied},
+		{nodeReservedIn, travelerAssignNode, stranger, responses{denied, denied, denied}, nodeReservedIn},
+		{nodeReservedIn, travelerReleaseNode, owner, responses{accepted, accepted, denied}, nodeAvailable},
+		{nodeReservedIn, travelerReleaseNode, stranger, responses{denied, denied, denied}, nodeReservedIn},
+		{nodeReservedIn, travelerUnlockNode, owner, responses{denied, denied, denied}, nodeReservedIn},
+		{nodeReservedIn, travelerLeaveNode, owner, responses{denied, denied, denied}, nodeReservedIn},
+		{nodeReservedIn, travelerStopNode, owner, responses{denied, denied, denied}, nodeReservedIn},
+
+		{nodeReservedOut, travelerReserveNode, owner, responses{denied, denied, denied}, nodeReservedOut},
+		{nodeReservedOut, travelerReserveNode, stranger, responses{denied, denied, denied}, nodeReservedOut},
+		{nodeReservedOut, travelerAssignNode, owner, responses{denied, denied, denied}, nodeReservedOut},
+		{nodeReservedOut, travelerReleaseNode, owner, responses{accepted, accepted, denied}, nodeAvailable},
+		{nodeReservedOut, travelerReleaseNode, stranger, responses{denied, denied, denied}, nodeReservedOut},
+		{nodeReservedOut, travelerUnlockNode, owner, responses{denied, denied, denied}, nodeReservedOut},
+		{nodeReservedOut, travelerLeaveNode, owner, responses{denied, denied, denied}, nodeReservedOut},
+		{nodeReservedOut, travelerStopNode, owner, responses{accepted, accepted, accepted}, nodeReservedOut},
+		{nodeReservedOut, travelerStopNode, stranger, responses{denied, denied, denied}, nodeReservedOut},
+
+		{nodeOccupied, travelerReserveNode, owner, responses{denied, denied, denied}, nodeOccupied},
+		{nodeOccupied, travelerReserveNode, stranger, responses{denied, denied, denied}, nodeOccupied},
+		{nodeOccupied, travelerAssignNode, owner, responses{denied, denied, denied}, nodeOccupied},
+		{nodeOccupied, travelerReleaseNode, owner, responses{accepted, accepted, denied}, nodeReservedOut},
+		{nodeOccupied, travelerReleaseNode, stranger, responses{denied, denied, denied}, nodeOccupied},
+		{nodeOccupied, travelerUnlockNode, owner, responses{denied, denied, denied}, nodeOccupied},
+		{nodeOccupied, travelerLeaveNode, owner, responses{accepted, denied, denied}, nodeAvailable},
+		{nodeOccupied, travelerLeaveNode, stranger, responses{denied, denied, denied}, nodeOccupied},
+		{nodeOccupied, travelerStopNode, owner, responses{accepted, accepted, accepted}, nodeOccupied},
+		{nodeOccupied, travelerStopNode, stranger, responses{denied, denied, denied}, nodeOccupied},
+	}
+
+	for _, test := range tests {
+		for cameraState, response := range test.responses {
+			cameraState := NodeCameraStateE(cameraState)
+			name := nodeTravelerStateNames[test.state] + "/" + nodeRequestNames[test.request]
+			if test.id == stranger {
+				name += " by a stranger"
+			}
+			name += " while " + nodeCameraStateNames[cameraState]
+
+			t.Run(name, func(t *testing.T) {
+				card := newTestCard(t)
+				normal := testSpecies(t, card, SpeciesNormal)
+				node := newTestNode(card, test.state, cameraState, normal)
+
+				after := test.after
+				if response == requestDenied {
+					after = test.state
+				}
+
+				request := newTestRequest(test.request, test.id, normal, Coordinates{1, 2})
+				got, answered := sendTravelerRequest(node, card, request)
+				if !answered || got != response {
+					t.Errorf("response %d (answered %v), expected %d", got, answered, response)
+				}
+				if node.travelerState != after {
+					t.Errorf("state %s, expected %s",
+						nodeTravelerStateNames[node.travelerState], nodeTravelerStateNames[after])
+				}
+
+				expectedId := NullTraveler
+				if after == nodeReservedIn && test.state == nodeAvailable {
+					expectedId = test.id
+				} else if after != nodeAvailable {
+					expectedId = owner
+				}
+				if node.travelerId != expectedId {
+					t.Errorf("traveler %d, expected %d", node.travelerId, expectedId)
+				}
+				if node.isStopped != (test.request == travelerStopNode && response == requestAccepted) {
+					t.Errorf("stopped %v", node.isStopped)
+				}
+				if node.cameraState != cameraState || node.isWaiting || node.isDoomed {
+					t.Errorf("camera %s, waiting %v, doomed %v",
+						nodeCameraStateNames[node.cameraState], node.isWaiting, node.isDoomed)
+				}
+			})
+		}
+	}
+}
+
+func TestNodeDisplacement(t *testing.T) {
+	// the occupant is wild and registered unless the test says otherwise,
+	// then finish is sent on its behalf once the node waits for it
+	tests := []struct {
+		name       string
+		normal     bool
+		chain      []TravelerId
+		doomed     bool
+		stopped    bool
+		unknown    bool
+		displaced  bool
+		finish     []NodeRequestE
+		block      bool
+		answer     NodeResponseE
+		afterState NodeTravelerStateE
+	}{
+		{name: "not displaceable", normal: true},
+		{name: "unknown traveler", unknown: true},
+		{name: "doomed traveler", doomed: true},
+		{name: "stopped traveler", stopped: true},
+		{name: "wild requester not making room", chain: []TravelerId{}},
+		{name: "cycle", chain: []TravelerId{5, owner}},
+		{
+			name: "unlocked", displaced: true,
+			finish: []NodeRequestE{travelerUnlockNode}, answer: requestDenied, afterState: nodeOccupied,
+		},
+		{
+			name: "left", displaced: true,
+			finish: []NodeRequestE{travelerLeaveNode}, answer: requestAccepted, afterState: nodeReservedIn,
+		},
+		{
+			name: "moved away", displaced: true,
+			finish:     []NodeRequestE{travelerReleaseNode, travelerReleaseNode},
+			answer:     requestAccepted,
+			afterState: nodeReservedIn,
+		},
+		{
+			name: "moved away while blocked", displaced: true, block: true,
+			finish:     []NodeRequestE{travelerReleaseNode, travelerReleaseNode},
+			answer:     requestDenied,
+			afterState: nodeAvailable,
+		},
+		{
+			name: "stopped", displaced: true,
+			finish: []NodeRequestE{travelerStopNode}, answer: requestDenied, afterState: nodeOccupied,
+		},
+		{
+			name: "chain", chain: []TravelerId{5, 6}, displaced: true,
+			finish: []NodeRequestE{travelerLeaveNode}, answer: requestAccepted, afterState: nodeReservedIn,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			card := newTestCard(t)
+			normal := testSpecies(t, card, SpeciesNormal)
+			wild := testSpecies(t, card, SpeciesWild)
+
+			occupantSpecies := wild
+			if test.normal {
+				occupantSpecies = normal
+			}
+			node := newTestNode(card, nodeOccupied, nodeRunning, occupantSpecies)
+			node.isDoomed = test.doomed
+			node.isStopped = test.stopped
+
+			displacementChannel := make(DisplacementChannel, 1)
+			if !test.unknown {
+				card.displacementRegistry.register(owner, displacementChannel)
+			}
+
+			requesterSpecies := normal
+			if test.chain != nil {
+				requesterSpecies = wild
+			}
+			reserveRequest := newTestRequest(
+				travelerReserveNode, stranger, requesterSpecies, Coordinates{1, 2}, test.chain...)
+
+			response, answered := sendTravelerRequest(node, card, reserveRequest)
+			if !answered {
+				t.Fatal("reservation not answered")
+			}
+			if !test.displaced {
+				if response != requestDenied || node.isWaiting || len(displacementChannel) > 0 {
+					t.Fatalf("response %d, waiting %v", response, node.isWaiting)
+				}
+				return
+			}
+
+			if response != requestSuspended || !node.isWaiting {
+				t.Fatalf("response %d, waiting %v", response, node.isWaiting)
+			}
+			displacement := <-displacementChannel
+			if displacement.c != node.c || !slices.Equal(displacement.chain, test.chain) {
+				t.Errorf("displacement %+v", displacement)
+			}
+
+			second := newTestRequest(travelerReserveNode, 3, normal, Coordinates{0, 1})
+			if response, _ := sendTravelerRequest(node, card, second); response != requestDenied {
+				t.Errorf("second reservation got %d", response)
+			}
+
+			if test.block {
+				node.block(card)
+			}
+			for _, request := range test.finish {
+				finish := newTestRequest(request, owner, wild, Coordinates{1, 0})
+				if response, _ := sendTravelerRequest(node, card, finish); response != requestAccepted {
+					t.Fatalf("%s got %d", nodeRequestNames[request], response)
+				}
+			}
+
+			select {
+			case response := <-reserveRequest.travelerResponse:
+				if response != test.answer {
+					t.Errorf("answer %d, expected %d", response, test.answer)
+				}
+			default:
+				t.Fatal("reservation still waiting")
+			}
+			if node.isWaiting || node.travelerState != test.afterState {
+				t.Errorf("waiting %v, state %s", node.isWaiting, nodeTravelerStateNames[node.travelerState])
+			}
+			if test.afterState == nodeReservedIn && node.travelerId != stranger {
+				t.Errorf("reserved by %d", node.travelerId)
+			}
+		})
+	}
+}
+
+func TestNodeDangerZones(t *testing.T) {
+	t.Run("doomed traveler released", func(t *testing.T) {
+		card := newTestCard(t)
+		normal := testSpecies(t, card, SpeciesNormal)
+		node := newTestNode(card, nodeOccupied, nodeRunning, normal)
+		card.dangerZonesKill = true
+
+		node.startDangerZone(card, 3)
+		if !node.isDoomed || len(node.dangerChannel) != 1 {
+			t.Fatalf("doomed %v, %d messages", node.isDoomed, len(node.dangerChannel))
+		}
+
+		for _, request := range []NodeRequestE{travelerLeaveNode, travelerReleaseNode} {
+			node := newTestNode(card, nodeOccupied, nodeRunning, normal)
+			node.dangerZone = 3
+			node.isDoomed = true
+
+			response, _ := sendTravelerRequest(node, card, newTestRequest(request, owner, normal, node.c))
+			if response != terminateTraveler {
+				t.Errorf("%s got %d", nodeRequestNames[request], response)
+			}
+			if node.travelerState != nodeAvailable || node.isDoomed || !node.dangerZone.active() {
+				t.Errorf("%s: state %s, doomed %v, danger zone %d", nodeRequestNames[request],
+					nodeTravelerStateNames[node.travelerState], node.isDoomed, node.dangerZone)
+			}
+		}
+	})
+
+	t.Run("survivors are not doomed", func(t *testing.T) {
+		card := newTestCard(t)
+		survivor := *testSpecies(t, card, SpeciesNormal)
+		survivor.SurvivesDangerZones = true
+		node := newTestNode(card, nodeOccupied, nodeRunning, &survivor)
+		card.dangerZonesKill = true
+
+		node.startDangerZone(card, 3)
+		if node.isDoomed || node.dangerZone.active() {
+			t.Errorf("doomed %v, danger zone %d", node.isDoomed, node.dangerZone)
+		}
+	})
+
+	t.Run("assigned into a danger zone", func(t *testing.T) {
+		card := newTestCard(t)
+		normal := testSpecies(t, card, SpeciesNormal)
+		survivor := *normal
+		survivor.SurvivesDangerZones = true
+
+		for _, species := range []*Species{normal, &survivor} {
+			node := newTestNode(card, nodeReservedIn, nodeRunning, species)
+			node.dangerZone = 3
+
+			expected, after := terminateTraveler, nodeAvailable
+			if species.SurvivesDangerZones {
+				expected, after = requestAccepted, nodeOccupied
+			}
+
+			request := newTestRequest(travelerAssignNode, owner, species, node.c)
+			response, _ := sendTravelerRequest(node, card, request)
+			if response != expected || node.travelerState != after || node.dangerZone.active() {
+				t.Errorf("survives %v: response %d, state %s, danger zone %d",
+					species.SurvivesDangerZones, response,
+					nodeTravelerStateNames[node.travelerState], node.dangerZone)
+			}
+		}
+	})
+}
@@ -0,0 +1,5 @@
+//go:build !race
+
+package travelers
+
+const raceEnabled = false
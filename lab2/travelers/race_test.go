@@ -0,0 +1,6 @@
+//go:build race
+
+package travelers
+
+// the race detector makes the long model checks take minutes
+const raceEnabled = true
@@ -0,0 +1,367 @@
+package travelers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+)
+
+// Structures - Region::Requests
+
+// RegionRequest carries either a traveler request, a danger zone or
+// a command of the Controller for one of the nodes of the region, a camera
+// request for all of them or an inspection request of the watchdog.
+type RegionRequest struct {
+	node              *Node
+	travelerRequest   NodeRequest
+	dangerZoneRequest *RegionDangerZoneRequest
+	controlRequest    *RegionControlRequest
+	cameraRequest     *RegionCameraRequest
+	inspectRequest    *RegionInspectRequest
+}
+
+type RegionRequestChannel chan RegionRequest
+
+type RegionCameraRequest struct {
+	request  NodeRequestE
+	response RegionCameraResponseChannel
+}
+
+func newRegionCameraRequest(request NodeRequestE) *RegionCameraRequest {
+	return &RegionCameraRequest{
+		request:  request,
+		response: make(RegionCameraResponseChannel, 1),
+	}
+}
+
+// RegionCameraResponse.nodes is only set for a snapshot, in the order of
+// Region.nodes.
+type RegionCameraResponse struct {
+	response NodeResponseE
+	nodes    []NodeCameraResponse
+}
+
+type RegionCameraResponseChannel chan RegionCameraResponse
+
+// RegionDangerZoneRequest is answered as soon as the node has it, the node
+// starts the danger zone on its next tick. Answering it right away keeps the
+// order of the requests of a deterministic run fixed.
+type RegionDangerZoneRequest struct {
+	duration DangerZone
+	response chan struct{}
+}
+
+func newRegionDangerZoneRequest(duration DangerZone) *RegionDangerZoneRequest {
+	return &RegionDangerZoneRequest{duration: duration, response: make(chan struct{}, 1)}
+}
+
+// RegionControlRequest.species is only set for a spawn and probs for
+// a change of the probabilities, a pause and a resume are for all the
+// nodes of the region.
+type RegionControlRequest struct {
+	request  NodeRequestE
+	species  *Species
+	probs    *RegionProbs
+	response chan RegionControlResponse
+}
+
+func newRegionControlRequest(request NodeRequestE) *RegionControlRequest {
+	return &RegionControlRequest{request: request, response: make(chan RegionControlResponse, 1)}
+}
+
+// RegionControlResponse.travelerId is only set for a spawn.
+type RegionControlResponse struct {
+	travelerId TravelerId
+	err        error
+}
+
+// errNodeBlocked is returned for a spawn while the camera takes a picture,
+// it is worth trying again.
+var errNodeBlocked = errors.New("the camera is taking a picture of the node")
+
+type RegionInspectRequest struct {
+	response chan []NodeInspection
+}
+
+func newRegionInspectRequest() *RegionInspectRequest {
+	return &RegionInspectRequest{response: make(chan []NodeInspection, 1)}
+}
+
+// Structures - Region
+
+// RegionProbs are the probabilities of the nodes of a region, the spawn and
+// move probabilities are in the order of the species registry. The move
+// probability is given to the travelers when they spawn.
+type RegionProbs struct {
+	spawn  []float64
+	move   []float64
+	danger float64
+}
+
+func newRegionProbs(species []*Species, danger float64) *RegionProbs {
+	probs := &RegionProbs{
+		spawn:  make([]float64, len(species)),
+		move:   make([]float64, len(species)),
+		danger: danger,
+	}
+	for i, current := range species {
+		probs.spawn[i] = current.SpawnProb
+		probs.move[i] = current.MoveProb
+	}
+	return probs
+}
+
+// Region is the actor serving a square of nodes of the card, so a large
+// card does not need a goroutine and a timer for every node. On a small
+// card every node is a region of its own.
+type Region struct {
+	nodes          []*Node
+	requestChannel RegionRequestChannel
+	cameraState    NodeCameraStateE
+	pendingCamera  *RegionCameraRequest
+	// nodes with a move in progress
+	movingNodes int
+	// danger zones spread by the nodes during the current tick
+	spreadDangerZones []RegionRequest
+	probs             *RegionProbs
+	// the context of the travelers spawned by the nodes, nil once the
+	// region stopped spawning
+	spawnContext context.Context
+	rng          *rand.Rand
+	clockActor   ClockActor
+}
+
+func newRegion(clockActor ClockActor, seed int64, queueSize int) *Region {
+	return &Region{
+		requestChannel: make(RegionRequestChannel, queueSize),
+		cameraState:    nodeRunning,
+		pendingCamera:  nil,
+		movingNodes:    0,
+		rng:            newRand(seed),
+		clockActor:     clockActor,
+	}
+}
+
+func (region *Region) start(ctx context.Context, card *TravelersCard) {
+	defer card.nodesWaitGroup.Done()
+
+	region.spawnContext = ctx
+	tick := region.clockActor.after(card.timings.Spawn.sample(region.rng))
+	done := ctx.Done()
+	for {
+		select {
+		case request := <-region.requestChannel:
+			region.handleRequest(request, card)
+
+		case <-tick:
+			for _, node := range region.nodes {
+				node.tick(ctx, card, region.rng)
+			}
+			region.sendDangerZones(card)
+			tick = region.clockActor.after(card.timings.Spawn.sample(region.rng))
+
+		case <-done:
+			// stop spawning, but keep serving the travelers finishing their moves
+			tick, done = nil, nil
+			region.spawnContext = nil
+			region.clockActor.leave()
+			card.spawnersWaitGroup.Done()
+
+		case <-card.stopChannel:
+			return
+		}
+	}
+}
+
+func (region *Region) handleRequest(request RegionRequest, card *TravelersCard) {
+	if request.cameraRequest != nil {
+		region.handleCameraRequest(request.cameraRequest, card)
+	} else if request.inspectRequest != nil {
+		region.handleInspectRequest(request.inspectRequest)
+	} else if request.dangerZoneRequest != nil {
+		request.node.addDangerZone(request.dangerZoneRequest.duration)
+		request.dangerZoneRequest.response <- struct{}{}
+	} else if request.controlRequest != nil {
+		region.handleControlRequest(request.node, request.controlRequest, card)
+	} else {
+		region.handleTravelerRequest(request.node, &request.travelerRequest, card)
+	}
+}
+
+// spreadDangerZone sends the danger zone to the node once the tick of the
+// region is over.
+func (region *Region) spreadDangerZone(node *Node, duration DangerZone) {
+	region.spreadDangerZones = append(region.spreadDangerZones, RegionRequest{
+		node:              node,
+		dangerZoneRequest: newRegionDangerZoneRequest(duration),
+	})
+}
+
+// sendDangerZones keeps serving the requests of the region while another
+// one takes a danger zone, so two regions spreading danger zones to each
+// other never wait for each other.
+func (region *Region) sendDangerZones(card *TravelersCard) {
+	for _, request := range region.spreadDangerZones {
+		if request.node.region == region {
+			request.node.addDangerZone(request.dangerZoneRequest.duration)
+			continue
+		}
+
+		requestChannel := request.node.region.requestChannel
+		for answered := false; !answered; {
+			select {
+			case requestChannel <- request:
+				requestChannel = nil
+			case <-request.dangerZoneRequest.response:
+				answered = true
+			case own := <-region.requestChannel:
+				region.handleRequest(own, card)
+			}
+		}
+	}
+	region.spreadDangerZones = region.spreadDangerZones[:0]
+}
+
+func (region *Region) handleTravelerRequest(
+	node *Node, request *NodeRequest, card *TravelersCard,
+) {
+	wasMoving := node.hasMovement()
+	node.handleTravelerRequest(request, card)
+
+	if isMoving := node.hasMovement(); isMoving != wasMoving {
+		if isMoving {
+			region.movingNodes++
+		} else {
+			region.movingNodes--
+		}
+		region.answerPendingCameraRequest(card)
+	}
+}
+
+// The camera takes a picture in four phases, sending each request to all
+// the regions before waiting for the responses:
+//   - block: the nodes deny new reservations and stop ticking,
+//   - drain: answered once no move is in progress in the region, so after
+//     all the regions answered, no reservation made before the block is left,
+//   - snapshot: answered with the state of the nodes once the last move
+//     releasing them has finished, the nodes are frozen from then on,
+//   - release: the nodes go back to normal.
+//
+// Hence all the snapshot responses together form a consistent cut.
+func (region *Region) handleCameraRequest(request *RegionCameraRequest, card *TravelersCard) {
+	switch request.request {
+	case cameraBlockNode:
+		if region.cameraState != nodeRunning {
+			request.response <- RegionCameraResponse{response: requestDenied}
+			return
+		}
+
+		region.cameraState = nodeBlocekd
+		for _, node := range region.nodes {
+			node.block(card)
+		}
+		request.response <- RegionCameraResponse{response: requestAccepted}
+
+	case cameraDrainNode, cameraSnapshotNode:
+		if region.cameraState != nodeBlocekd || region.pendingCamera != nil {
+			request.response <- RegionCameraResponse{response: requestDenied}
+			return
+		}
+
+		region.pendingCamera = request
+		region.answerPendingCameraRequest(card)
+
+	case cameraReleaseNode:
+		if region.cameraState == nodeRunning {
+			request.response <- RegionCameraResponse{response: requestDenied}
+			return
+		}
+
+		region.cameraState = nodeRunning
+		for _, node := range region.nodes {
+			node.release(card)
+		}
+		request.response <- RegionCameraResponse{response: requestAccepted}
+
+	default:
+		request.response <- RegionCameraResponse{response: requestDenied}
+	}
+}
+
+func (region *Region) answerPendingCameraRequest(card *TravelersCard) {
+	if region.pendingCamera == nil || region.movingNodes > 0 {
+		return
+	}
+
+	request := region.pendingCamera
+	region.pendingCamera = nil
+
+	response := RegionCameraResponse{response: requestAccepted}
+	if request.request == cameraSnapshotNode {
+		region.cameraState = nodeFrozen
+		response.nodes = make([]NodeCameraResponse, len(region.nodes))
+		for i, node := range region.nodes {
+			response.nodes[i] = node.snapshot(card)
+		}
+	}
+	request.response <- response
+}
+
+// handleControlRequest spawns a traveler of the species on an empty node
+// the way a tick does, pauses or resumes all the nodes or changes their
+// probabilities. A paused node does not tick and denies the reservations
+// and the leaves of the travelers, so nothing happens on the card except
+// the moves in progress finishing.
+func (region *Region) handleControlRequest(
+	node *Node, request *RegionControlRequest, card *TravelersCard,
+) {
+	response := RegionControlResponse{travelerId: NullTraveler}
+
+	switch request.request {
+	case controlSpawnNode:
+		switch {
+		case region.spawnContext == nil:
+			response.err = errors.New("the simulation is stopping")
+		case card.topology.isWall(node.c) || card.isExit(node.c):
+			response.err = fmt.Errorf("node (%d,%d) is a wall or an exit", node.c.x, node.c.y)
+		case node.travelerState != nodeAvailable || node.dangerZone.active():
+			response.err = fmt.Errorf("node (%d,%d) is not empty", node.c.x, node.c.y)
+		case node.cameraState != nodeRunning:
+			response.err = errNodeBlocked
+		default:
+			response.travelerId = node.spawnTraveler(region.spawnContext, card, request.species, region.rng)
+			if response.travelerId == NullTraveler {
+				response.err = fmt.Errorf("there are already as many %s travelers as allowed",
+					request.species.Name)
+			}
+		}
+
+	case controlPauseNode, controlResumeNode:
+		for _, node := range region.nodes {
+			node.isPaused = request.request == controlPauseNode
+		}
+
+	case controlProbsNode:
+		region.probs = request.probs
+
+	default:
+		response.err = errors.New("unknown request")
+	}
+
+	request.response <- response
+}
+
+func (region *Region) handleInspectRequest(request *RegionInspectRequest) {
+	inspections := make([]NodeInspection, len(region.nodes))
+	for i, node := range region.nodes {
+		inspections[i] = NodeInspection{
+			c:             node.c,
+			cameraState:   node.cameraState,
+			travelerState: node.travelerState,
+			travelerId:    node.travelerId,
+			isWaiting:     node.isWaiting,
+		}
+	}
+	request.response <- inspections
+}
@@ -0,0 +1,188 @@
+package travelers
+
+import "testing"
+
+var cameraRequestNames = map[NodeRequestE]string{
+	cameraBlockNode:    "block",
+	cameraDrainNode:    "drain",
+	cameraSnapshotNode: "snapshot",
+	cameraReleaseNode:  "release",
+}
+
+// sendCameraRequest returns the response of the region and whether the
+// region answered the request right away.
+func sendCameraRequest(
+	region *Region, card *TravelersCard, request NodeRequestE,
+) (RegionCameraResponse, bool) {
+	cameraRequest := newRegionCameraRequest(request)
+	region.handleCameraRequest(cameraRequest, card)
+
+	select {
+	case response := <-cameraRequest.response:
+		return response, true
+	default:
+		return RegionCameraResponse{}, false
+	}
+}
+
+func newTestRegion(card *TravelersCard, cameraState NodeCameraStateE) *Region {
+	region := card.grid[1][1].region
+	region.cameraState = cameraState
+	for _, node := range region.nodes {
+		node.cameraState = cameraState
+	}
+	return region
+}
+
+func TestRegionCameraRequests(t *testing.T) {
+	tests := []struct {
+		state    NodeCameraStateE
+		request  NodeRequestE
+		response NodeResponseE
+		after    NodeCameraStateE
+	}{
+		{nodeRunning, cameraBlockNode, requestAccepted, nodeBlocekd},
+		{nodeRunning, cameraDrainNode, requestDenied, nodeRunning},
+		{nodeRunning, cameraSnapshotNode, requestDenied, nodeRunning},
+		{nodeRunning, cameraReleaseNode, requestDenied, nodeRunning},
+
+		{nodeBlocekd, cameraBlockNode, requestDenied, nodeBlocekd},
+		{nodeBlocekd, cameraDrainNode, requestAccepted, nodeBlocekd},
+		{nodeBlocekd, cameraSnapshotNode, requestAccepted, nodeFrozen},
+		{nodeBlocekd, cameraReleaseNode, requestAccepted, nodeRunning},
+
+		{nodeFrozen, cameraBlockNode, requestDenied, nodeFrozen},
+		{nodeFrozen, cameraDrainNode, requestDenied, nodeFrozen},
+		{nodeFrozen, cameraSnapshotNode, requestDenied, nodeFrozen},
+		{nodeFrozen, cameraReleaseNode, requestAccepted, nodeRunning},
+	}
+
+	for _, test := range tests {
+		name := nodeCameraStateNames[test.state] + "/" + cameraRequestNames[test.request]
+		t.Run(name, func(t *testing.T) {
+			card := newTestCard(t)
+			region := newTestRegion(card, test.state)
+
+			response, answered := sendCameraRequest(region, card, test.request)
+			if !answered || response.response != test.response {
+				t.Errorf("response %d (answered %v), expected %d",
+					response.response, answered, test.response)
+			}
+			if region.cameraState != test.after {
+				t.Errorf("state %s, expected %s",
+					nodeCameraStateNames[region.cameraState], nodeCameraStateNames[test.after])
+			}
+
+			// the nodes follow the region
+			for _, node := range region.nodes {
+				if node.cameraState != region.cameraState {
+					t.Errorf("node %v is %s", node.c, nodeCameraStateNames[node.cameraState])
+				}
+			}
+
+			if test.request == cameraSnapshotNode && response.response == requestAccepted &&
+				len(response.nodes) != len(region.nodes) {
+				t.Errorf("%d nodes in the snapshot", len(response.nodes))
+			}
+		})
+	}
+}
+
+// TestRegionCameraDuringMoves checks that a drain or a snapshot waits for
+// every move in progress in the region, whether the traveler ends up on the
+// node or away from it.
+func TestRegionCameraDuringMoves(t *testing.T) {
+	tests := []struct {
+		name     string
+		state    NodeTravelerStateE
+		request  NodeRequestE
+		finish   NodeRequestE
+		traveler TravelerId
+	}{
+		{"drain, assigned", nodeReservedIn, cameraDrainNode, travelerAssignNode, owner},
+		{"drain, cancelled", nodeReservedIn, cameraDrainNode, travelerReleaseNode, NullTraveler},
+		{"drain, released", nodeReservedOut, cameraDrainNode, travelerReleaseNode, NullTraveler},
+		{"snapshot, assigned", nodeReservedIn, cameraSnapshotNode, travelerAssignNode, owner},
+		{"snapshot, released", nodeReservedOut, cameraSnapshotNode, travelerReleaseNode, NullTraveler},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			card := newTestCard(t)
+			normal := testSpecies(t, card, SpeciesNormal)
+			region := newTestRegion(card, nodeRunning)
+
+			node := newTestNode(card, test.state, nodeRunning, normal)
+			region.movingNodes = 1
+			node.edgeBlur = 1
+
+			response, _ := sendCameraRequest(region, card, cameraBlockNode)
+			if response.response != requestAccepted {
+				t.Fatalf("block got %d", response.response)
+			}
+
+			cameraRequest := newRegionCameraRequest(test.request)
+			region.handleCameraRequest(cameraRequest, card)
+			if len(cameraRequest.response) > 0 {
+				t.Fatal("answered during a move")
+			}
+
+			if response, answered := sendCameraRequest(region, card, test.request); !answered ||
+				response.response != requestDenied {
+				t.Errorf("second request: %+v, answered %v", response, answered)
+			}
+
+			finish := newTestRequest(test.finish, owner, normal, Coordinates{1, 0})
+			region.handleTravelerRequest(node, &finish, card)
+			if response := <-finish.travelerResponse; response != requestAccepted {
+				t.Fatalf("%s got %d", nodeRequestNames[test.finish], response)
+			}
+
+			select {
+			case response = <-cameraRequest.response:
+			default:
+				t.Fatal("not answered after the move")
+			}
+			if response.response != requestAccepted || region.pendingCamera != nil ||
+				region.movingNodes != 0 {
+				t.Fatalf("%+v, pending %v, %d moving nodes",
+					response, region.pendingCamera != nil, region.movingNodes)
+			}
+
+			if test.request != cameraSnapshotNode {
+				if region.cameraState != nodeBlocekd {
+					t.Errorf("drained region is %s", nodeCameraStateNames[region.cameraState])
+				}
+				return
+			}
+
+			if region.cameraState != nodeFrozen || node.cameraState != nodeFrozen {
+				t.Errorf("region %s, node %s", nodeCameraStateNames[region.cameraState],
+					nodeCameraStateNames[node.cameraState])
+			}
+			snapshot := response.nodes[0]
+			if snapshot.travelerId != test.traveler || snapshot.edgeBlur == 0 || node.edgeBlur != 0 {
+				t.Errorf("snapshot %+v, expected traveler %d, node blur %d",
+					snapshot, test.traveler, node.edgeBlur)
+			}
+		})
+	}
+}
+
+func TestRegionSnapshotHidesDangerZones(t *testing.T) {
+	card := newTestCard(t)
+	survivor := *testSpecies(t, card, SpeciesNormal)
+	survivor.SurvivesDangerZones = true
+
+	region := newTestRegion(card, nodeBlocekd)
+	node := newTestNode(card, nodeOccupied, nodeBlocekd, &survivor)
+	node.dangerZone = 2
+
+	response, answered := sendCameraRequest(region, card, cameraSnapshotNode)
+	if !answered || response.response != requestAccepted {
+		t.Fatalf("%+v, answered %v", response, answered)
+	}
+	if snapshot := response.nodes[0]; snapshot.travelerId != NullTraveler || !snapshot.dangerZone {
+		t.Errorf("snapshot %+v", snapshot)
+	}
+}
@@ -0,0 +1,382 @@
+package travelers
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Renderer draws a single picture. Renderers are stateless, so one renderer
+// can be shared by many observers.
+type Renderer interface {
+	Render(w io.Writer, picture *Picture) error
+}
+
+var renderers = map[string]Renderer{
+	"ascii":   ASCIIRenderer{},
+	"unicode": UnicodeRenderer{},
+	"plain":   PlainRenderer{},
+	"none":    NoRenderer{},
+}
+
+func RendererNames() []string {
+	names := make([]string, 0, len(renderers))
+	for name := range renderers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func NewRenderer(name string) (Renderer, error) {
+	renderer, exists := renderers[name]
+	if !exists {
+		return nil, fmt.Errorf("unknown renderer %q - must be one of: %s",
+			name, strings.Join(RendererNames(), ", "))
+	}
+	return renderer, nil
+}
+
+// RendererObserver renders every picture of the camera to its writer.
+type RendererObserver struct {
+	renderer Renderer
+	writer   io.Writer
+}
+
+func NewRendererObserver(renderer Renderer, writer io.Writer) *RendererObserver {
+	return &RendererObserver{renderer: renderer, writer: writer}
+}
+
+func (observer *RendererObserver) ObservePicture(picture *Picture) {
+	buffer := bufio.NewWriter(observer.writer)
+	if err := observer.renderer.Render(buffer, picture); err == nil {
+		buffer.Flush()
+	}
+}
+
+// Structures - NoRenderer
+
+// NoRenderer draws nothing, for the runs on cards too large to look at.
+type NoRenderer struct{}
+
+func (renderer NoRenderer) Render(w io.Writer, picture *Picture) error {
+	return nil
+}
+
+// Structures - ASCIIRenderer
+
+const (
+	dangerZoneMarker string = "##"
+	wallMarker       string = "XX"
+	horizontalBlur   string = "--"
+	verticalBlur     string = "||"
+	noBlur           string = "  "
+)
+
+// ASCIIRenderer draws the pictures the same way the original camera did.
+// The rows of a hex card are shifted and the diagonal blur trails are drawn
+// under the row as '\' and '/'.
+type ASCIIRenderer struct{}
+
+func (renderer ASCIIRenderer) Render(w io.Writer, picture *Picture) error {
+	fmt.Fprintf(w, "Picture: %d\n", picture.Number())
+
+	labelWidth := renderLabelWidth(picture)
+	nodeWidth := labelWidth + 4
+
+	for y := 0; y < picture.Height(); y++ {
+		fmt.Fprint(w, strings.Repeat(" ", renderRowIndent(picture, y, nodeWidth)))
+		for x := 0; x < picture.Width(); x++ {
+			cell := picture.Cell(x, y)
+			if cell.Wall {
+				fmt.Fprintf(w, "[%s]", renderMarker(wallMarker, labelWidth))
+			} else if cell.DangerZone {
+				fmt.Fprintf(w, "[%s]", renderMarker(dangerZoneMarker, labelWidth))
+			} else if cell.TravelerId != NullTraveler {
+				if cell.Glyph != "" {
+					fmt.Fprintf(w, "[%s]", renderMarker(cell.Glyph, labelWidth))
+				} else {
+					fmt.Fprintf(w, "[%0*d]", labelWidth, cell.TravelerId)
+				}
+			} else {
+				fmt.Fprintf(w, "[%s]", renderMarker(noBlur, labelWidth))
+			}
+
+			if cell.HorizontalEdgeBlur {
+				fmt.Fprintf(w, "%s", horizontalBlur)
+			} else {
+				fmt.Fprintf(w, "%s", noBlur)
+			}
+		}
+
+		fmt.Fprintln(w)
+		edges := renderEdgeLine(picture, y, nodeWidth, verticalBlur[:1], "\\", "/")
+		if _, err := fmt.Fprintln(w, edges); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderLabelWidth returns the number of digits of the largest traveler
+// id shown in the picture, but at least 2 like in the original pictures.
+func renderLabelWidth(picture *Picture) int {
+	maxId := TravelerId(0)
+	for y := 0; y < picture.Height(); y++ {
+		for x := 0; x < picture.Width(); x++ {
+			if cell := picture.Cell(x, y); cell.Glyph == "" && cell.TravelerId > maxId {
+				maxId = cell.TravelerId
+			}
+		}
+	}
+	return max(2, len(fmt.Sprint(maxId)))
+}
+
+// renderMarker repeats the first character of the marker to fill the label.
+func renderMarker(marker string, labelWidth int) string {
+	return strings.Repeat(marker[:1], labelWidth)
+}
+
+// Every node takes nodeWidth columns in the text renderers, the last two of
+// them are the edge on its right.
+func renderRowIndent(picture *Picture, y int, nodeWidth int) int {
+	if picture.Topology() == TopologyHex && y%2 == 1 {
+		return nodeWidth / 2
+	}
+	return 0
+}
+
+// renderEdgeLine returns the line drawn under the row y, with the markers
+// of the edges below the nodes placed between them and the nodes they lead
+// to. Each marker must take a single column.
+func renderEdgeLine(
+	picture *Picture, y int, nodeWidth int, vertical string, diagonal string, antiDiagonal string,
+) string {
+	columns := make([]string, nodeWidth*picture.Width()+renderRowIndent(picture, 1, nodeWidth))
+	for i := range columns {
+		columns[i] = " "
+	}
+
+	// the diagonal edges of a hex card lead to the nodes shifted by half
+	diagonalColumn, antiDiagonalColumn := nodeWidth-2, -1
+	if picture.Topology() == TopologyHex {
+		diagonalColumn, antiDiagonalColumn = nodeWidth/2, 0
+	}
+
+	for x := 0; x < picture.Width(); x++ {
+		cell := picture.Cell(x, y)
+		start := renderRowIndent(picture, y, nodeWidth) + nodeWidth*x
+
+		if cell.VerticalEdgeBlur {
+			columns[start+1], columns[start+2] = vertical, vertical
+		}
+		if cell.DiagonalEdgeBlur {
+			columns[start+diagonalColumn] = diagonal
+		}
+		if cell.AntiDiagonalEdgeBlur && start+antiDiagonalColumn >= 0 {
+			columns[start+antiDiagonalColumn] = antiDiagonal
+		}
+	}
+
+	return strings.Join(columns, "")
+}
+
+// Structures - UnicodeRenderer
+
+const (
+	ansiClear  string = "\033[H\033[2J"
+	ansiReset  string = "\033[0m"
+	ansiRed    string = "\033[31m"
+	ansiGreen  string = "\033[32m"
+	ansiYellow string = "\033[33m"
+	ansiPurple string = "\033[35m"
+	ansiGray   string = "\033[90m"
+)
+
+// UnicodeRenderer redraws a colored box-drawing grid in place of the
+// previous picture. The blur trails are drawn as arrows over the edge
+// the traveler crossed. A hex card is drawn without the boxes, with its
+// rows shifted like in the ascii renderer.
+type UnicodeRenderer struct{}
+
+func (renderer UnicodeRenderer) Render(w io.Writer, picture *Picture) error {
+	fmt.Fprint(w, ansiClear)
+	fmt.Fprintf(w, "Picture: %d\n", picture.Number())
+
+	if picture.Topology() == TopologyHex {
+		renderer.renderRows(w, picture)
+	} else {
+		renderer.renderBoxes(w, picture)
+	}
+
+	_, err := fmt.Fprint(w, ansiReset)
+	return err
+}
+
+func (renderer UnicodeRenderer) renderBoxes(w io.Writer, picture *Picture) {
+	width := picture.Width()
+	labelWidth := renderLabelWidth(picture)
+	fmt.Fprintln(w, renderer.border(picture, labelWidth, -1, "┌", "┬", "┐"))
+
+	for y := 0; y < picture.Height(); y++ {
+		fmt.Fprint(w, "│")
+		for x := 0; x < width; x++ {
+			cell := picture.Cell(x, y)
+			fmt.Fprint(w, renderer.cell(cell, labelWidth))
+
+			if cell.HorizontalEdgeBlur {
+				// the right border is only crossed on a torus
+				fmt.Fprint(w, ansiYellow+"↔"+ansiReset)
+			} else {
+				fmt.Fprint(w, "│")
+			}
+		}
+		fmt.Fprintln(w)
+
+		if y == picture.Height()-1 {
+			fmt.Fprintln(w, renderer.border(picture, labelWidth, y, "└", "┴", "┘"))
+		} else {
+			fmt.Fprintln(w, renderer.border(picture, labelWidth, y, "├", "┼", "┤"))
+		}
+	}
+}
+
+func (renderer UnicodeRenderer) renderRows(w io.Writer, picture *Picture) {
+	labelWidth := renderLabelWidth(picture)
+	nodeWidth := labelWidth + 4
+
+	for y := 0; y < picture.Height(); y++ {
+		fmt.Fprint(w, strings.Repeat(" ", renderRowIndent(picture, y, nodeWidth)))
+		for x := 0; x < picture.Width(); x++ {
+			cell := picture.Cell(x, y)
+			if cell.Empty() {
+				fmt.Fprint(w, " "+strings.Repeat("·", labelWidth)+" ")
+			} else {
+				fmt.Fprint(w, renderer.cell(cell, labelWidth))
+			}
+
+			if cell.HorizontalEdgeBlur {
+				fmt.Fprint(w, ansiYellow+"↔ "+ansiReset)
+			} else {
+				fmt.Fprint(w, "  ")
+			}
+		}
+		fmt.Fprintln(w)
+
+		fmt.Fprintln(w, renderEdgeLine(picture, y, nodeWidth,
+			ansiYellow+"↕"+ansiReset, ansiYellow+"╲"+ansiReset, ansiYellow+"╱"+ansiReset))
+	}
+}
+
+func (renderer UnicodeRenderer) cell(cell PictureCell, labelWidth int) string {
+	switch {
+	case cell.Wall:
+		return ansiGray + strings.Repeat("█", labelWidth+2) + ansiReset
+	case cell.DangerZone:
+		return ansiRed + " " + strings.Repeat("▓", labelWidth) + " " + ansiReset
+	case cell.TravelerId == NullTraveler:
+		return strings.Repeat(" ", labelWidth+2)
+	case cell.Glyph != "":
+		return ansiPurple + " " + renderMarker(cell.Glyph, labelWidth) + " " + ansiReset
+	default:
+		return fmt.Sprintf("%s %0*d %s", ansiGreen, labelWidth, cell.TravelerId, ansiReset)
+	}
+}
+
+// border returns the line under the row y (-1 for the top one), the blur
+// trails of the edges leaving the bottom row are only drawn on a torus.
+func (renderer UnicodeRenderer) border(
+	picture *Picture, labelWidth int, y int, left string, middle string, right string,
+) string {
+	var builder strings.Builder
+	width := picture.Width()
+
+	builder.WriteString(left)
+	for x := 0; x < width; x++ {
+		if y >= 0 && picture.Cell(x, y).VerticalEdgeBlur {
+			builder.WriteString("─" + ansiYellow + "↕" + ansiReset + strings.Repeat("─", labelWidth))
+		} else {
+			builder.WriteString(strings.Repeat("─", labelWidth+2))
+		}
+
+		if x == width-1 {
+			break
+		}
+
+		if y >= 0 && y < picture.Height()-1 &&
+			(picture.Cell(x, y).DiagonalEdgeBlur || picture.Cell(x+1, y).AntiDiagonalEdgeBlur) {
+			builder.WriteString(ansiYellow + "╳" + ansiReset)
+		} else {
+			builder.WriteString(middle)
+		}
+	}
+	builder.WriteString(right)
+
+	return builder.String()
+}
+
+// Structures - PlainRenderer
+
+// PlainRenderer writes a picture as a header line followed by one line per
+// row with a space separated token per cell:
+//
+//	picture <number> <width> <height> <unix nanoseconds> <topology>
+//	<cell> <cell> ...
+//
+// A cell is "." when empty, "X" for a wall, "#" for a danger zone,
+// "t<id>" for a traveler and "<glyph><id>" for a traveler of a species with
+// a glyph (e.g. "*<id>" for a wild traveler). It is followed
+// by ">" if a traveler moved over the edge to the right, by "v" if it moved
+// over the edge below and by "\\" or "/" if it moved over the edge to the
+// lower right or to the lower left.
+type PlainRenderer struct{}
+
+func (renderer PlainRenderer) Render(w io.Writer, picture *Picture) error {
+	fmt.Fprintf(w, "picture %d %d %d %d %s\n",
+		picture.Number(), picture.Width(), picture.Height(), picture.Time().UnixNano(),
+		picture.Topology())
+
+	for y := 0; y < picture.Height(); y++ {
+		tokens := make([]string, picture.Width())
+		for x := range tokens {
+			tokens[x] = renderer.cell(picture.Cell(x, y))
+		}
+
+		if _, err := fmt.Fprintln(w, strings.Join(tokens, " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (renderer PlainRenderer) cell(cell PictureCell) string {
+	var token string
+	switch {
+	case cell.Wall:
+		token = "X"
+	case cell.DangerZone:
+		token = "#"
+	case cell.TravelerId == NullTraveler:
+		token = "."
+	case cell.Glyph != "":
+		token = fmt.Sprintf("%s%d", cell.Glyph, cell.TravelerId)
+	default:
+		token = fmt.Sprintf("t%d", cell.TravelerId)
+	}
+
+	if cell.HorizontalEdgeBlur {
+		token += ">"
+	}
+	if cell.VerticalEdgeBlur {
+		token += "v"
+	}
+	if cell.DiagonalEdgeBlur {
+		token += "\\"
+	}
+	if cell.AntiDiagonalEdgeBlur {
+		token += "/"
+	}
+	return token
+}
@@ -0,0 +1,228 @@
+package travelers
+
+import (
+	"container/heap"
+	"math/rand"
+	"sort"
+)
+
+// Type aliases
+
+type RoutingE string
+
+// Constants
+
+const ( // RoutingE
+	RoutingRandom RoutingE = "random"
+	RoutingGoal   RoutingE = "goal"
+)
+
+var routings = []RoutingE{RoutingRandom, RoutingGoal}
+
+func RoutingNames() []string {
+	names := make([]string, len(routings))
+	for i, routing := range routings {
+		names[i] = string(routing)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Structures - Route
+
+// Route leads a traveler to a random destination, after reaching it the
+// traveler picks the next one. The path avoids the danger zones known from
+// the latest picture and the node which denied the last reservation.
+type Route struct {
+	destination    Coordinates
+	hasDestination bool
+	path           []Coordinates
+	avoid          *Coordinates
+	arrivals       uint
+	replans        uint
+}
+
+func newRoute() *Route {
+	return &Route{}
+}
+
+// next returns the node the traveler should move to from c, which is c
+// itself if there is no way to go on.
+func (route *Route) next(card *TravelersCard, c Coordinates, rng *rand.Rand) Coordinates {
+	if !route.hasDestination || route.destination == c {
+		route.chooseDestination(card, c, rng)
+	}
+
+	if len(route.path) > 0 && card.isKnownDangerZone(route.path[0]) {
+		route.path = nil
+	}
+
+	if len(route.path) == 0 {
+		route.plan(card, c)
+		if len(route.path) == 0 {
+			// the destination cannot be reached, try another one next time
+			route.hasDestination = false
+			return c
+		}
+	}
+
+	return route.path[0]
+}
+
+func (route *Route) chooseDestination(card *TravelersCard, c Coordinates, rng *rand.Rand) {
+	route.path = nil
+	route.avoid = nil
+	route.hasDestination = false
+
+	if card.topology.freeNodes() < 2 {
+		return
+	}
+
+	for !route.hasDestination {
+		destination := Coordinates{rng.Intn(card.width), rng.Intn(card.height)}
+		if destination != c && !card.topology.isWall(destination) {
+			route.destination = destination
+			route.hasDestination = true
+		}
+	}
+}
+
+// plan finds the shortest path to the destination, giving up first the
+// node to avoid and then the known danger zones if there is none.
+func (route *Route) plan(card *TravelersCard, c Coordinates) {
+	if !route.hasDestination {
+		return
+	}
+
+	blocked := func(next Coordinates) bool {
+		return next != route.destination &&
+			((route.avoid != nil && next == *route.avoid) || card.isKnownDangerZone(next))
+	}
+	route.path = findPath(card.topology, c, route.destination, blocked)
+
+	if route.path == nil && route.avoid != nil {
+		route.avoid = nil
+		route.path = findPath(card.topology, c, route.destination, blocked)
+	}
+	if route.path == nil {
+		route.path = findPath(card.topology, c, route.destination, nil)
+	}
+}
+
+// moved advances the route after the traveler entered c.
+func (route *Route) moved(c Coordinates) {
+	if len(route.path) > 0 && route.path[0] == c {
+		route.path = route.path[1:]
+	} else {
+		route.path = nil
+	}
+	route.avoid = nil
+
+	if route.hasDestination && c == route.destination {
+		route.arrivals++
+		route.hasDestination = false
+	}
+}
+
+// denied makes the traveler plan a way around c.
+func (route *Route) denied(c Coordinates) {
+	route.avoid = &c
+	route.path = nil
+	route.replans++
+}
+
+// Structures - Path search
+
+type PathNode struct {
+	c        Coordinates
+	cost     int
+	estimate int
+	order    int
+}
+
+// PathQueue prefers the lowest estimate of the whole path, then the longest
+// path so far and then the node queued first, so a search is deterministic.
+type PathQueue []PathNode
+
+func (queue PathQueue) Len() int {
+	return len(queue)
+}
+
+func (queue PathQueue) Less(i int, j int) bool {
+	if queue[i].estimate != queue[j].estimate {
+		return queue[i].estimate < queue[j].estimate
+	}
+	if queue[i].cost != queue[j].cost {
+		return queue[i].cost > queue[j].cost
+	}
+	return queue[i].order < queue[j].order
+}
+
+func (queue PathQueue) Swap(i int, j int) {
+	queue[i], queue[j] = queue[j], queue[i]
+}
+
+func (queue *PathQueue) Push(node any) {
+	*queue = append(*queue, node.(PathNode))
+}
+
+func (queue *PathQueue) Pop() any {
+	old := *queue
+	node := old[len(old)-1]
+	*queue = old[:len(old)-1]
+	return node
+}
+
+// findPath runs A* from start to destination, returning the nodes after
+// start, or nil if the destination cannot be reached without the blocked
+// nodes. A nil blocked function blocks nothing.
+func findPath(
+	topology *Topology, start Coordinates, destination Coordinates,
+	blocked func(Coordinates) bool,
+) []Coordinates {
+	costs := map[Coordinates]int{start: 0}
+	previous := make(map[Coordinates]Coordinates)
+
+	queue := &PathQueue{{c: start, cost: 0, estimate: topology.distance(start, destination)}}
+	order := 0
+	for queue.Len() > 0 {
+		current := heap.Pop(queue).(PathNode)
+		if current.c == destination {
+			break
+		}
+		if current.cost > costs[current.c] {
+			continue
+		}
+
+		for _, link := range topology.neighbours(current.c) {
+			if blocked != nil && blocked(link.c) {
+				continue
+			}
+
+			cost := current.cost + 1
+			if known, exists := costs[link.c]; exists && known <= cost {
+				continue
+			}
+
+			costs[link.c] = cost
+			previous[link.c] = current.c
+			order++
+			heap.Push(queue, PathNode{
+				c:        link.c,
+				cost:     cost,
+				estimate: cost + topology.distance(link.c, destination),
+				order:    order,
+			})
+		}
+	}
+
+	if _, reached := costs[destination]; !reached || start == destination {
+		return nil
+	}
+
+	path := make([]Coordinates, costs[destination])
+	for c, i := destination, len(path)-1; i >= 0; c, i = previous[c], i-1 {
+		path[i] = c
+	}
+	return path
+}
@@ -0,0 +1,244 @@
+package travelers
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Constants
+
+// a client too slow to take the pictures skips the ones which do not fit
+const serverClientQueueSize int = 8
+
+const serverShutdownTimeout = time.Second
+
+//go:embed web/index.html
+var serverIndex []byte
+
+// Structures - Server
+
+type serverClient struct {
+	ws     *websocketConn
+	frames chan websocketFrame
+	done   chan struct{}
+	once   sync.Once
+}
+
+func newServerClient(ws *websocketConn) *serverClient {
+	return &serverClient{
+		ws:     ws,
+		frames: make(chan websocketFrame, serverClientQueueSize),
+		done:   make(chan struct{}),
+	}
+}
+
+// send never blocks, so a slow client cannot hold the camera up.
+func (client *serverClient) send(frame websocketFrame) bool {
+	select {
+	case client.frames <- frame:
+		return true
+	default:
+		return false
+	}
+}
+
+func (client *serverClient) close() {
+	client.once.Do(func() {
+		close(client.done)
+		client.ws.close()
+	})
+}
+
+// Server shows the run in a browser: it serves a page drawing the card at
+// /, the latest picture as JSON at /picture and streams every picture as
+// a WebSocket text message at /pictures. It observes the pictures like the
+// renderers, so it only ever sees the card through the camera. A port
+// without a host only listens on localhost, and the WebSocket of a page of
+// another site is turned away.
+type Server struct {
+	address    string
+	httpServer *http.Server
+	mutex      sync.Mutex
+	picture    []byte
+	clients    map[*serverClient]bool
+	closed     bool
+}
+
+func NewServer(address string) *Server {
+	server := &Server{
+		address: address,
+		clients: make(map[*serverClient]bool),
+	}
+	server.httpServer = &http.Server{Handler: server.Handler()}
+	return server
+}
+
+func (server *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", server.handleIndex)
+	mux.HandleFunc("/picture", server.handlePicture)
+	mux.HandleFunc("/pictures", server.handlePictures)
+	return mux
+}
+
+// listenAddress gives a TCP address without a host the one of localhost,
+// so that the card is only shown to the other hosts when asked to.
+func listenAddress(address string) string {
+	if strings.HasPrefix(address, "unix:") {
+		return address
+	}
+	if host, port, err := net.SplitHostPort(address); err == nil && host == "" {
+		return net.JoinHostPort("127.0.0.1", port)
+	}
+	return address
+}
+
+// listen takes a host:port or unix:<path> address, see listenAddress.
+func listen(address string) (net.Listener, error) {
+	address = listenAddress(address)
+	if path, isUnix := strings.CutPrefix(address, "unix:"); isUnix {
+		return net.Listen("unix", path)
+	}
+	return net.Listen("tcp", address)
+}
+
+// Start listens on the address before it returns, so that a busy port is
+// reported before the simulation starts, and serves in the background.
+func (server *Server) Start() (net.Addr, error) {
+	listener, err := listen(server.address)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		if err := server.httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			server.Close()
+		}
+	}()
+	return listener.Addr(), nil
+}
+
+// Close disconnects the WebSocket clients, which the http server no longer
+// tracks, and stops the server.
+func (server *Server) Close() error {
+	server.mutex.Lock()
+	server.closed = true
+	for client := range server.clients {
+		client.close()
+		delete(server.clients, client)
+	}
+	server.mutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+	defer cancel()
+	return server.httpServer.Shutdown(ctx)
+}
+
+func (server *Server) ObservePicture(picture *Picture) {
+	encoded, err := json.Marshal(picture)
+	if err != nil {
+		return
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.picture = encoded
+	for client := range server.clients {
+		client.send(websocketFrame{opcode: websocketText, payload: encoded})
+	}
+}
+
+func (server *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(serverIndex)
+}
+
+func (server *Server) handlePicture(w http.ResponseWriter, r *http.Request) {
+	server.mutex.Lock()
+	picture := server.picture
+	server.mutex.Unlock()
+
+	if picture == nil {
+		http.Error(w, "no picture taken yet", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(picture)
+}
+
+// handlePictures writes the frames of the client until it leaves, the
+// latest picture is sent first so that the page has something to draw.
+func (server *Server) handlePictures(w http.ResponseWriter, r *http.Request) {
+	ws, err := upgradeWebSocket(w, r)
+	if err != nil {
+		return
+	}
+	client := newServerClient(ws)
+
+	server.mutex.Lock()
+	if server.closed {
+		server.mutex.Unlock()
+		client.close()
+		return
+	}
+	server.clients[client] = true
+	if server.picture != nil {
+		client.send(websocketFrame{opcode: websocketText, payload: server.picture})
+	}
+	server.mutex.Unlock()
+
+	defer func() {
+		server.mutex.Lock()
+		delete(server.clients, client)
+		server.mutex.Unlock()
+		client.close()
+	}()
+
+	go server.readFrames(client)
+	for {
+		select {
+		case frame := <-client.frames:
+			if ws.writeFrame(frame) != nil || frame.opcode == websocketClose {
+				return
+			}
+		case <-client.done:
+			return
+		}
+	}
+}
+
+// readFrames answers the pings and the close of the client, anything else
+// it sends is ignored. The close is answered by the writer, which then
+// drops the connection.
+func (server *Server) readFrames(client *serverClient) {
+	for {
+		frame, err := client.ws.readFrame()
+		if err != nil {
+			client.close()
+			return
+		}
+
+		switch frame.opcode {
+		case websocketPing:
+			client.send(websocketFrame{opcode: websocketPong, payload: frame.payload})
+		case websocketClose:
+			if !client.send(websocketFrame{opcode: websocketClose}) {
+				client.close()
+			}
+			return
+		}
+	}
+}
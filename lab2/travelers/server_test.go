@@ -0,0 +1,328 @@
+package travelers
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestPicture(number uint) *Picture {
+	return &Picture{
+		number:   number,
+		time:     time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		topology: TopologyHex,
+		cells: [][]PictureCell{
+			{{TravelerId: 3, Kind: SpeciesNormal, HorizontalEdgeBlur: true}, {TravelerId: NullTraveler, Wall: true}},
+			{{TravelerId: NullTraveler, DangerZone: true}, {TravelerId: 7, Kind: SpeciesWild, Glyph: "*"}},
+		},
+	}
+}
+
+type testPicture struct {
+	Number   uint            `json:"number"`
+	Topology TopologyE       `json:"topology"`
+	Width    int             `json:"width"`
+	Height   int             `json:"height"`
+	Cells    [][]PictureCell `json:"cells"`
+}
+
+// Structures - test WebSocket client
+
+type testWebSocket struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func dialTestWebSocket(t *testing.T, url string) *testWebSocket {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	io.WriteString(conn, "GET /pictures HTTP/1.1\r\nHost: localhost\r\n"+
+		"Upgrade: websocket\r\nConnection: keep-alive, Upgrade\r\n"+
+		"Sec-WebSocket-Key: "+key+"\r\nSec-WebSocket-Version: 13\r\n\r\n")
+
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != http.StatusSwitchingProtocols ||
+		response.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("handshake: %s, %v", response.Status, response.Header)
+	}
+	return &testWebSocket{conn: conn, reader: reader}
+}
+
+// write sends a masked frame, like every client must.
+func (ws *testWebSocket) write(t *testing.T, opcode websocketOpcodeE, payload []byte) {
+	t.Helper()
+
+	mask := []byte{1, 2, 3, 4}
+	frame := []byte{0x80 | byte(opcode), 0x80 | byte(len(payload))}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := ws.conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (ws *testWebSocket) read(t *testing.T) websocketFrame {
+	t.Helper()
+
+	var header [2]byte
+	if _, err := io.ReadFull(ws.reader, header[:]); err != nil {
+		t.Fatal(err)
+	}
+	if header[0]&0x80 == 0 || header[1]&0x80 != 0 {
+		t.Fatalf("fragmented or masked frame: %x", header)
+	}
+
+	length := uint64(header[1])
+	switch length {
+	case 126:
+		var extended [2]byte
+		io.ReadFull(ws.reader, extended[:])
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		io.ReadFull(ws.reader, extended[:])
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+
+	frame := websocketFrame{opcode: websocketOpcodeE(header[0] & 0x0F), payload: make([]byte, length)}
+	if _, err := io.ReadFull(ws.reader, frame.payload); err != nil {
+		t.Fatal(err)
+	}
+	return frame
+}
+
+func (ws *testWebSocket) readPicture(t *testing.T) testPicture {
+	t.Helper()
+
+	frame := ws.read(t)
+	if frame.opcode != websocketText {
+		t.Fatalf("opcode %d", frame.opcode)
+	}
+	var picture testPicture
+	if err := json.Unmarshal(frame.payload, &picture); err != nil {
+		t.Fatal(err)
+	}
+	return picture
+}
+
+func TestServerPicture(t *testing.T) {
+	server := NewServer("")
+	httpServer := httptest.NewServer(server.Handler())
+	defer httpServer.Close()
+
+	response, err := http.Get(httpServer.URL + "/picture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("before the first picture: %s", response.Status)
+	}
+
+	server.ObservePicture(newTestPicture(1))
+	server.ObservePicture(newTestPicture(2))
+
+	response, err = http.Get(httpServer.URL + "/picture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	var picture testPicture
+	if err := json.NewDecoder(response.Body).Decode(&picture); err != nil {
+		t.Fatal(err)
+	}
+	if picture.Number != 2 || picture.Topology != TopologyHex || picture.Width != 2 || picture.Height != 2 {
+		t.Errorf("picture %+v", picture)
+	}
+	if cells := newTestPicture(2).cells; cells[0][0] != picture.Cells[0][0] ||
+		cells[0][1] != picture.Cells[0][1] || cells[1][0] != picture.Cells[1][0] ||
+		cells[1][1] != picture.Cells[1][1] {
+		t.Errorf("cells %+v, expected %+v", picture.Cells, cells)
+	}
+}
+
+func TestServerIndex(t *testing.T) {
+	httpServer := httptest.NewServer(NewServer("").Handler())
+	defer httpServer.Close()
+
+	response, err := http.Get(httpServer.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK || !strings.Contains(string(body), "<canvas") ||
+		!strings.HasPrefix(response.Header.Get("Content-Type"), "text/html") {
+		t.Errorf("%s, %q", response.Status, response.Header.Get("Content-Type"))
+	}
+
+	if response, err := http.Get(httpServer.URL + "/missing"); err != nil ||
+		response.StatusCode != http.StatusNotFound {
+		t.Errorf("missing page: %v, %v", response, err)
+	}
+	if response, err := http.Get(httpServer.URL + "/pictures"); err != nil ||
+		response.StatusCode != http.StatusBadRequest {
+		t.Errorf("pictures without a handshake: %v, %v", response, err)
+	}
+}
+
+func TestServerPictures(t *testing.T) {
+	server := NewServer("")
+	httpServer := httptest.NewServer(server.Handler())
+	defer httpServer.Close()
+
+	server.ObservePicture(newTestPicture(1))
+
+	ws := dialTestWebSocket(t, httpServer.URL)
+	if picture := ws.readPicture(t); picture.Number != 1 {
+		t.Errorf("the latest picture first, got %d", picture.Number)
+	}
+
+	// the client is registered once it got the latest picture
+	server.ObservePicture(newTestPicture(2))
+	if picture := ws.readPicture(t); picture.Number != 2 || picture.Cells[1][1].Glyph != "*" {
+		t.Errorf("picture %+v", picture)
+	}
+
+	ws.write(t, websocketPing, []byte("ping"))
+	if frame := ws.read(t); frame.opcode != websocketPong || string(frame.payload) != "ping" {
+		t.Errorf("pong %+v", frame)
+	}
+
+	ws.write(t, websocketClose, nil)
+	if frame := ws.read(t); frame.opcode != websocketClose {
+		t.Errorf("close %+v", frame)
+	}
+}
+
+func TestServerSlowClient(t *testing.T) {
+	server := NewServer("")
+	httpServer := httptest.NewServer(server.Handler())
+	defer httpServer.Close()
+
+	server.ObservePicture(newTestPicture(1))
+	ws := dialTestWebSocket(t, httpServer.URL)
+	ws.readPicture(t)
+
+	// the client does not read, the camera must not wait for it
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10*serverClientQueueSize; i++ {
+			server.ObservePicture(newTestPicture(uint(i + 2)))
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the pictures are held up by a slow client")
+	}
+
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerStart(t *testing.T) {
+	server := NewServer("127.0.0.1:0")
+	address, err := server.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewServer(address.String()).Start(); err == nil {
+		t.Error("listened twice on the same address")
+	}
+
+	ws := dialTestWebSocket(t, "http://"+address.String())
+	server.ObservePicture(newTestPicture(1))
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the picture may or may not make it before the connection is closed
+	ws.conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.ReadAll(ws.reader); err != nil {
+		t.Errorf("connection not closed: %v", err)
+	}
+}
+
+func TestListenAddress(t *testing.T) {
+	tests := []struct {
+		address  string
+		expected string
+	}{
+		{":8081", "127.0.0.1:8081"},
+		{":0", "127.0.0.1:0"},
+		{"0.0.0.0:8081", "0.0.0.0:8081"},
+		{"[::1]:8081", "[::1]:8081"},
+		{"localhost:8081", "localhost:8081"},
+		{"unix:/tmp/travelers.sock", "unix:/tmp/travelers.sock"},
+	}
+	for _, test := range tests {
+		if address := listenAddress(test.address); address != test.expected {
+			t.Errorf("%q listens on %q, expected %q", test.address, address, test.expected)
+		}
+	}
+}
+
+// TestServerOrigin keeps the pages of the other sites from the pictures.
+func TestServerOrigin(t *testing.T) {
+	httpServer := httptest.NewServer(NewServer("").Handler())
+	defer httpServer.Close()
+
+	tests := []struct {
+		origin string
+		status int
+	}{
+		{"", http.StatusSwitchingProtocols},
+		{httpServer.URL, http.StatusSwitchingProtocols},
+		{"http://attacker.example", http.StatusForbidden},
+		{"http://127.0.0.1:1", http.StatusForbidden},
+		{"null", http.StatusForbidden},
+	}
+	for _, test := range tests {
+		request, err := http.NewRequest(http.MethodGet, httpServer.URL+"/pictures", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		request.Header.Set("Upgrade", "websocket")
+		request.Header.Set("Connection", "Upgrade")
+		request.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		request.Header.Set("Sec-WebSocket-Version", "13")
+		if test.origin != "" {
+			request.Header.Set("Origin", test.origin)
+		}
+
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		if response.StatusCode != test.status {
+			t.Errorf("origin %q: %s, expected %d", test.origin, response.Status, test.status)
+		}
+	}
+}
@@ -0,0 +1,441 @@
+// Package travelers implements the multithreaded travelers simulation:
+// every node of the card, every traveler and the camera is a goroutine
+// and they only communicate through request channels.
+package travelers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+const minSize, maxSize int = 1, 1000
+
+const maxSpeed float64 = 1000
+
+// e.g. 0.1 + 0.2 + 0.7 is slightly above 1 in floating point
+const probTolerance float64 = 1e-9
+
+const maxDangerZoneDuration, maxQueueSize int = 1000, 100000
+
+// The zero DangerZoneDuration and QueueSize of a Config stand for these.
+const (
+	DefaultDangerZoneDuration int = int(initDangerZoneDuration)
+	DefaultQueueSize          int = bufferSize
+)
+
+// Config describes a single simulation run.
+type Config struct {
+	Width        int
+	Height       int
+	MaxTravelers int
+	Probs        NodeProbs
+
+	// Topology of the card, TopologyGrid if empty. Walls (if not nil) mark
+	// the nodes no traveler can enter and must be Height rows of Width nodes.
+	Topology TopologyE
+	Walls    [][]bool
+
+	// Routing of the travelers, RoutingRandom if empty.
+	Routing RoutingE
+
+	// A traveler leaves the card once it stands on one of the Exits (if not
+	// nil, Height rows of Width nodes) or after Lifetime ticks (if not 0),
+	// its id is then given to a new traveler. No traveler spawns on an exit.
+	Exits    [][]bool
+	Lifetime uint
+
+	// Species of the travelers in the order of spawning. If nil, they are
+	// the DefaultSpecies made of Probs, MaxTravelers, Routing and Lifetime.
+	Species []Species
+
+	// A danger zone lasts DangerZoneDuration ticks of its node. The one
+	// started by a node covers the nodes of DangerZoneShape (a single node
+	// if empty) around it and every danger zone spreads to a random
+	// neighbour with DangerZoneSpread on every tick. With DangerZonesKill
+	// a danger zone kills the traveler standing on a node it reaches. The
+	// Scenario starts danger zones at the given times.
+	DangerZoneDuration int
+	DangerZoneShape    DangerZoneShapeE
+	DangerZoneSpread   float64
+	DangerZonesKill    bool
+	Scenario           []ScheduledDangerZone
+
+	// QueueSize is the capacity of the request queues of the regions, the id
+	// manager and the event log.
+	QueueSize int
+
+	// Seed of the random sources of all the actors. A run is only
+	// reproducible if it is Deterministic, which replaces the real clock
+	// with a virtual one. The virtual clock keeps the pace of a real run
+	// unless FastForward is set.
+	Seed          int64
+	Deterministic bool
+	FastForward   bool
+
+	// Timings of the actors, all of them run Speed times faster (a Speed
+	// of 0 keeps the pace of the timings).
+	Timings Timings
+	Speed   float64
+
+	// The simulation stops by itself after Duration (if it is not 0)
+	// or once MaxPictures (if it is not 0) pictures were taken.
+	Duration    time.Duration
+	MaxPictures uint
+
+	// EventLog receives the events as JSON Lines, nil disables it.
+	EventLog  io.Writer
+	Observers []PictureObserver
+
+	// Watchdog (if it is not 0) reports to WatchdogOutput the travelers
+	// waiting for a node for longer than Watchdog and the wait-for cycles.
+	// With WatchdogAbort it gives up the run after the first report.
+	Watchdog       time.Duration
+	WatchdogAbort  bool
+	WatchdogOutput io.Writer
+}
+
+// Validate checks the config and fills in the defaults of its zero values.
+func (config *Config) Validate() error {
+	if config.Width < minSize || config.Width > maxSize {
+		return errors.New("invalid value of width - must be in range [1, 1000]")
+	}
+
+	if config.Height < minSize || config.Height > maxSize {
+		return errors.New("invalid value of height - must be in range [1, 1000]")
+	}
+
+	if config.Topology == "" {
+		config.Topology = TopologyGrid
+	}
+	if _, exists := topologyDirections[config.Topology]; !exists {
+		return fmt.Errorf("invalid value of topology - must be one of: %s",
+			strings.Join(TopologyNames(), ", "))
+	}
+
+	if config.Routing == "" {
+		config.Routing = RoutingRandom
+	}
+	if config.Routing != RoutingRandom && config.Routing != RoutingGoal {
+		return fmt.Errorf("invalid value of routing - must be one of: %s",
+			strings.Join(RoutingNames(), ", "))
+	}
+
+	if config.Walls != nil {
+		if len(config.Walls) != config.Height {
+			return errors.New("invalid walls - must have a row for every row of the card")
+		}
+		for _, row := range config.Walls {
+			if len(row) != config.Width {
+				return errors.New("invalid walls - must have a node for every node of the card")
+			}
+		}
+	}
+
+	if config.Exits != nil {
+		if len(config.Exits) != config.Height {
+			return errors.New("invalid exits - must have a row for every row of the card")
+		}
+		for y, row := range config.Exits {
+			if len(row) != config.Width {
+				return errors.New("invalid exits - must have a node for every node of the card")
+			}
+			for x, exit := range row {
+				if exit && config.Walls != nil && config.Walls[y][x] {
+					return fmt.Errorf("invalid exits - node (%d,%d) is a wall", x, y)
+				}
+			}
+		}
+	}
+
+	if config.Probs.Danger < 0 || config.Probs.Danger > 1 {
+		return errors.New("invalid value of danger_prob - must be in range [0, 1]")
+	}
+
+	freeNodes := newTopology(config.Topology, config.Width, config.Height, config.Walls).freeNodes()
+	if config.Species == nil {
+		for _, prob := range []struct {
+			name  string
+			value float64
+		}{
+			{"spawn_prob", config.Probs.Spawn},
+			{"move_prob", config.Probs.Move},
+			{"wild_prob", config.Probs.Wild},
+		} {
+			if prob.value < 0 || prob.value > 1 {
+				return fmt.Errorf("invalid value of %s - must be in range [0, 1]", prob.name)
+			}
+		}
+
+		if config.MaxTravelers < minSize || config.MaxTravelers > freeNodes {
+			if config.Walls == nil {
+				return errors.New(
+					"invalid value of max_travelers - must be in range [1, width * height]")
+			}
+			return fmt.Errorf(
+				"invalid value of max_travelers - must be in range [1, %d] (free nodes)", freeNodes)
+		}
+
+		config.Species = DefaultSpecies(
+			config.Probs, config.MaxTravelers, config.Routing, config.Lifetime)
+	}
+	if _, err := newSpeciesRegistry(config.Species, freeNodes); err != nil {
+		return err
+	}
+
+	// an empty node checks the spawn probabilities and then danger_prob one
+	// after another, so together they are the share of its ticks with an event
+	total := config.Probs.Danger
+	for _, species := range config.Species {
+		total += species.SpawnProb
+	}
+	if total > 1+probTolerance {
+		return fmt.Errorf("invalid probabilities - the spawn probabilities of the species "+
+			"(spawn_prob and wild_prob by default) and danger_prob add up to %.4g, must be at most 1", total)
+	}
+
+	if config.DangerZoneDuration == 0 {
+		config.DangerZoneDuration = DefaultDangerZoneDuration
+	}
+	if config.DangerZoneDuration < 1 || config.DangerZoneDuration > maxDangerZoneDuration {
+		return fmt.Errorf("invalid value of danger_zone_duration - must be in range [1, %d]",
+			maxDangerZoneDuration)
+	}
+
+	if err := validateDangerZoneShape(&config.DangerZoneShape); err != nil {
+		return fmt.Errorf("invalid value of danger_zone_shape - %v", err)
+	}
+
+	if config.DangerZoneSpread < 0 || config.DangerZoneSpread > 1 {
+		return errors.New("invalid value of danger_zone_spread - must be in range [0, 1]")
+	}
+
+	// the defaults are filled in on a copy, the caller keeps its scenario
+	config.Scenario = append([]ScheduledDangerZone(nil), config.Scenario...)
+	for i := range config.Scenario {
+		if err := config.Scenario[i].validate(config); err != nil {
+			return fmt.Errorf("invalid scenario - danger zone %d: %v", i+1, err)
+		}
+	}
+
+	if config.QueueSize == 0 {
+		config.QueueSize = DefaultQueueSize
+	}
+	if config.QueueSize < 1 || config.QueueSize > maxQueueSize {
+		return fmt.Errorf("invalid value of queue_size - must be in range [1, %d]", maxQueueSize)
+	}
+
+	if err := config.Timings.validate(); err != nil {
+		return err
+	}
+
+	if config.Speed == 0 {
+		config.Speed = 1
+	}
+	if config.Speed < 0 || config.Speed > maxSpeed {
+		return fmt.Errorf("invalid value of speed - must be in range (0, %v]", maxSpeed)
+	}
+
+	if config.Duration < 0 {
+		return errors.New("invalid value of duration - must be non-negative")
+	}
+
+	if config.Watchdog < 0 {
+		return errors.New("invalid value of watchdog - must be non-negative")
+	}
+	if config.Watchdog > 0 && config.WatchdogOutput == nil {
+		return errors.New("invalid watchdog - must have an output")
+	}
+
+	return nil
+}
+
+// Structures - Simulation
+
+type Simulation struct {
+	config               Config
+	clock                Clock
+	eventLog             *EventLog
+	travelerIdManager    *TravelerIdManager
+	displacementRegistry *DisplacementRegistry
+	card                 *TravelersCard
+	camera               *Camera
+	watchdog             *Watchdog
+	controller           *Controller
+
+	ctx               context.Context
+	cancel            context.CancelFunc
+	servicesWaitGroup sync.WaitGroup
+	startTime         time.Time
+
+	stopOnce   sync.Once
+	statistics *Statistics
+}
+
+func NewSimulation(config Config) (*Simulation, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	var clock Clock
+	if config.Deterministic {
+		clock = newVirtualClock(!config.FastForward)
+	} else {
+		clock = newRealClock()
+	}
+
+	var eventLog *EventLog
+	if config.EventLog != nil {
+		eventLog = newEventLog(config.EventLog, clock, config.QueueSize)
+	}
+
+	topology := newTopology(config.Topology, config.Width, config.Height, config.Walls)
+	species, _ := newSpeciesRegistry(config.Species, topology.freeNodes())
+	travelerIdManager := newTravelerIdManager(species, config.QueueSize)
+	displacementRegistry := newDisplacementRegistry(config.QueueSize)
+	timings := config.Timings.scaled(config.Speed)
+	card := newTravelersCard(topology, travelerIdManager, displacementRegistry, clock, config.Seed,
+		eventLog, timings, config.Speed, species, config.QueueSize)
+
+	card.exits = config.Exits
+	card.dangerZoneDuration = DangerZone(config.DangerZoneDuration)
+	card.dangerZoneShape = config.DangerZoneShape
+	card.dangerZoneSpread = config.DangerZoneSpread
+	card.dangerZonesKill = config.DangerZonesKill
+
+	var watchdog *Watchdog
+	if config.Watchdog > 0 {
+		card.waits = newWaitTable()
+		watchdog = newWatchdog(card, config.Watchdog, config.WatchdogAbort, config.WatchdogOutput)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	simulation := &Simulation{
+		config:               config,
+		clock:                clock,
+		eventLog:             eventLog,
+		travelerIdManager:    travelerIdManager,
+		displacementRegistry: displacementRegistry,
+		card:                 card,
+		camera:               newCamera(card, config.MaxPictures, config.Observers, ^config.Seed),
+		watchdog:             watchdog,
+		ctx:                  ctx,
+		cancel:               cancel,
+	}
+	simulation.controller = newController(&simulation.config, card, simulation.camera)
+	return simulation, nil
+}
+
+// Start runs the simulation in the background, it must be called only once.
+func (simulation *Simulation) Start() {
+	simulation.startTime = simulation.clock.now()
+
+	if simulation.eventLog != nil {
+		simulation.servicesWaitGroup.Add(1)
+		go simulation.eventLog.start(&simulation.servicesWaitGroup)
+	}
+
+	simulation.servicesWaitGroup.Add(1)
+	go simulation.travelerIdManager.start(&simulation.servicesWaitGroup)
+
+	simulation.servicesWaitGroup.Add(1)
+	go simulation.displacementRegistry.start(&simulation.servicesWaitGroup)
+
+	if simulation.watchdog != nil {
+		simulation.servicesWaitGroup.Add(1)
+		go simulation.watchdog.start(&simulation.servicesWaitGroup)
+	}
+
+	if simulation.config.Duration > 0 {
+		deadline := simulation.clock.join()
+		go func() {
+			if deadline.sleep(simulation.ctx, simulation.config.Duration) {
+				simulation.cancel()
+			}
+			deadline.leave()
+		}()
+	}
+
+	simulation.card.startNodes(simulation.ctx, simulation.config.Probs)
+
+	if len(simulation.config.Scenario) > 0 {
+		scenario := newScenario(simulation.config.Scenario, simulation.clock.join())
+		simulation.card.waitGroup.Add(1)
+		go scenario.start(simulation.ctx, simulation.card)
+	}
+
+	simulation.card.waitGroup.Add(1)
+	go func() {
+		simulation.camera.start(simulation.ctx)
+		simulation.cancel()
+	}()
+}
+
+// Controller pokes the simulation once it has started, until it stops.
+func (simulation *Simulation) Controller() *Controller {
+	return simulation.controller
+}
+
+// Done is closed once the simulation wants to stop by itself, i.e. after
+// its duration or the number of its pictures was reached.
+func (simulation *Simulation) Done() <-chan struct{} {
+	return simulation.ctx.Done()
+}
+
+// Aborted is closed once the watchdog gave up the run, the actors may be
+// stuck then, so the run must be ended with Abort instead of Stop.
+func (simulation *Simulation) Aborted() <-chan struct{} {
+	return simulation.watchdog.aborted()
+}
+
+// Stop lets the in-flight moves finish, takes the final picture, stops all
+// the actors and returns the statistics of the run. It can be called many
+// times, only the first call stops the simulation. The error tells that
+// the event log could not be written, the statistics are complete anyway.
+func (simulation *Simulation) Stop() (*Statistics, error) {
+	simulation.stopOnce.Do(func() {
+		simulation.cancel()
+
+		card := simulation.card
+		card.spawnersWaitGroup.Wait()
+		card.waitGroup.Wait()
+		simulation.camera.takePicture()
+
+		simulation.watchdog.stop()
+		card.stopNodes()
+		simulation.travelerIdManager.stop()
+		simulation.displacementRegistry.stop()
+		simulation.eventLog.stop()
+		simulation.servicesWaitGroup.Wait()
+		simulation.clock.stop()
+
+		card.statistics.collect(
+			card, simulation.camera, simulation.clock.now().Sub(simulation.startTime))
+		simulation.statistics = card.statistics
+	})
+
+	return simulation.statistics, simulation.eventLog.error()
+}
+
+// Abort ends a run the watchdog gave up without waiting for the actors,
+// which may be stuck. It writes out the events emitted so far and returns
+// the statistics of the travelers gone by then, without the occupancy of
+// the nodes. Stop does nothing after it.
+func (simulation *Simulation) Abort() (*Statistics, error) {
+	simulation.stopOnce.Do(func() {
+		simulation.cancel()
+		simulation.watchdog.stop()
+		simulation.eventLog.abort()
+
+		card := simulation.card
+		card.statistics.collectPartial(card, simulation.clock.now().Sub(simulation.startTime))
+		simulation.statistics = card.statistics
+	})
+
+	return simulation.statistics, simulation.eventLog.error()
+}
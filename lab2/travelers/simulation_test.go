@@ -0,0 +1,337 @@
+package travelers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func BenchmarkMoves(b *testing.B) {
+	for _, size := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("%dx%d", size, size), func(b *testing.B) {
+			var moves uint64
+			for i := 0; i < b.N; i++ {
+				simulation, err := NewSimulation(Config{
+					Width:         size,
+					Height:        size,
+					MaxTravelers:  size * size / 20,
+					Probs:         NodeProbs{Spawn: 0.05, Move: 0.9, Wild: 0.01, Danger: 0.01},
+					Seed:          int64(i),
+					Deterministic: true,
+					FastForward:   true,
+					Duration:      5 * sleepDuration,
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				simulation.Start()
+				<-simulation.Done()
+				statistics, _ := simulation.Stop()
+				moves += uint64(statistics.TotalMoves.Successful)
+			}
+
+			b.ReportMetric(float64(moves)/b.Elapsed().Seconds(), "moves/s")
+		})
+	}
+}
+
+// TestDeterministicPictures runs the same seed twice on the virtual clock,
+// both runs must take the same pictures.
+func TestDeterministicPictures(t *testing.T) {
+	for _, topology := range []TopologyE{TopologyGrid, TopologyHex} {
+		t.Run(string(topology), func(t *testing.T) {
+			var runs [2][]*Picture
+			for i := range runs {
+				simulation, err := NewSimulation(Config{
+					Width:         8,
+					Height:        6,
+					MaxTravelers:  12,
+					Probs:         NodeProbs{Spawn: 0.3, Move: 0.8, Wild: 0.2, Danger: 0.1},
+					Topology:      topology,
+					Seed:          3,
+					Deterministic: true,
+					FastForward:   true,
+					Duration:      20 * time.Second,
+					Observers: []PictureObserver{PictureObserverFunc(func(picture *Picture) {
+						runs[i] = append(runs[i], picture)
+					})},
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				simulation.Start()
+				<-simulation.Done()
+				simulation.Stop()
+			}
+
+			if len(runs[0]) < 5 {
+				t.Fatalf("only %d pictures taken", len(runs[0]))
+			}
+			if len(runs[0]) != len(runs[1]) {
+				t.Fatalf("%d and %d pictures taken", len(runs[0]), len(runs[1]))
+			}
+			for i := range runs[0] {
+				if !reflect.DeepEqual(runs[0][i], runs[1][i]) {
+					t.Fatalf("picture %d differs:\n%+v\n%+v", i, runs[0][i], runs[1][i])
+				}
+			}
+		})
+	}
+}
+
+type failingWriter struct {
+	writes int
+}
+
+func (writer *failingWriter) Write([]byte) (int, error) {
+	writer.writes++
+	return 0, errors.New("disk full")
+}
+
+func TestEventLogError(t *testing.T) {
+	writer := &failingWriter{}
+	simulation, err := NewSimulation(Config{
+		Width:         4,
+		Height:        4,
+		MaxTravelers:  4,
+		Probs:         NodeProbs{Spawn: 0.5, Move: 0.8},
+		Seed:          1,
+		Deterministic: true,
+		FastForward:   true,
+		Duration:      10 * time.Second,
+		EventLog:      writer,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	simulation.Start()
+	<-simulation.Done()
+	statistics, err := simulation.Stop()
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("error %v, expected the one of the writer", err)
+	}
+	if statistics == nil || statistics.Species[SpeciesNormal].Spawned == 0 {
+		t.Error("no statistics of the run")
+	}
+	if writer.writes != 1 {
+		t.Errorf("%d writes after the first one failed", writer.writes-1)
+	}
+	if _, again := simulation.Stop(); again != err {
+		t.Errorf("error %v of the second stop, expected %v", again, err)
+	}
+}
+
+// TestSimulationAbort ends a run without waiting for its actors, the events
+// they emit after the abort must not reach the log.
+func TestSimulationAbort(t *testing.T) {
+	var eventLog bytes.Buffer
+	simulation, err := NewSimulation(Config{
+		Width:        6,
+		Height:       6,
+		MaxTravelers: 10,
+		Probs:        NodeProbs{Spawn: 0.5, Move: 0.9, Wild: 0.3},
+		Seed:         1,
+		Speed:        200,
+		Duration:     time.Minute,
+		EventLog:     &eventLog,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	simulation.Start()
+	time.Sleep(200 * time.Millisecond)
+	statistics, err := simulation.Abort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !statistics.Partial || statistics.Pictures == 0 || statistics.Occupancy != nil {
+		t.Errorf("statistics %+v, expected partial ones", statistics)
+	}
+
+	written := eventLog.Len()
+	lines := strings.Split(strings.TrimSpace(eventLog.String()), "\n")
+	for i, line := range lines {
+		var event Event
+		if err := json.Unmarshal([]byte(line), &event); err != nil || event.Seq != uint64(i) {
+			t.Fatalf("line %d: %q, %v", i+1, line, err)
+		}
+	}
+
+	// the actors wind down on their own
+	time.Sleep(100 * time.Millisecond)
+	if eventLog.Len() != written {
+		t.Error("events written after the abort")
+	}
+	if again, _ := simulation.Stop(); again != statistics {
+		t.Error("stopped after the abort")
+	}
+}
+
+// TestStatisticsMovesPerTraveler keeps the moves of every traveler apart,
+// even once their ids are given to other travelers.
+func TestStatisticsMovesPerTraveler(t *testing.T) {
+	simulation, err := NewSimulation(Config{
+		Width:         4,
+		Height:        4,
+		MaxTravelers:  4,
+		Probs:         NodeProbs{Spawn: 0.3, Move: 0.8, Wild: 0.2},
+		Lifetime:      5,
+		Seed:          3,
+		Deterministic: true,
+		FastForward:   true,
+		Duration:      time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	simulation.Start()
+	<-simulation.Done()
+	statistics, _ := simulation.Stop()
+
+	spawned := statistics.Species[SpeciesNormal].Spawned
+	if spawned <= 4 {
+		t.Fatalf("only %d travelers spawned, no id was given again", spawned)
+	}
+	if uint(len(statistics.Moves)) != spawned {
+		t.Errorf("moves of %d travelers, %d spawned", len(statistics.Moves), spawned)
+	}
+
+	var total MoveStatistics
+	for _, moves := range statistics.Moves {
+		total.add(moves.MoveStatistics)
+	}
+	if total != statistics.TotalMoves {
+		t.Errorf("moves %+v add up to %+v", statistics.TotalMoves, total)
+	}
+}
+
+// TestWildTravelerSpawns runs thousands of short-lived wild travelers who
+// keep being asked to make room, run it with -race. The run is on the real
+// clock, which lets the regions and the travelers run in parallel.
+func TestWildTravelerSpawns(t *testing.T) {
+	simulation, err := NewSimulation(Config{
+		Width:        8,
+		Height:       8,
+		MaxTravelers: 20,
+		Probs:        NodeProbs{Spawn: 0.05, Move: 0.9, Wild: 0.5},
+		Seed:         1,
+		Speed:        500,
+		Duration:     3 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	simulation.Start()
+	<-simulation.Done()
+	statistics, _ := simulation.Stop()
+
+	wild := statistics.Species[SpeciesWild]
+	if wild.Spawned < 1000 {
+		t.Errorf("only %d wild travelers spawned", wild.Spawned)
+	}
+	if wild.Relocated == 0 {
+		t.Error("no wild traveler made room")
+	}
+}
+
+// checkPicture reports a picture having a traveler on more than one node,
+// on a wall or in a danger zone, or more travelers of a species than there
+// can be on the card.
+func checkPicture(t *testing.T, picture *Picture, maxTravelers map[string]int) {
+	seen := make(map[TravelerId]bool)
+	travelers := make(map[string]int)
+	for y := 0; y < picture.Height(); y++ {
+		for x := 0; x < picture.Width(); x++ {
+			cell := picture.Cell(x, y)
+			if cell.TravelerId == NullTraveler {
+				continue
+			}
+
+			if cell.Wall || cell.DangerZone {
+				t.Errorf("picture %d: traveler %d on a wall or a danger zone at (%d,%d)",
+					picture.Number(), cell.TravelerId, x, y)
+			}
+			if seen[cell.TravelerId] {
+				t.Errorf("picture %d: traveler %d on more than one node", picture.Number(), cell.TravelerId)
+			}
+			seen[cell.TravelerId] = true
+			travelers[cell.Kind]++
+		}
+	}
+
+	for kind, count := range travelers {
+		if count > maxTravelers[kind] {
+			t.Errorf("picture %d: %d travelers of %s, at most %d",
+				picture.Number(), count, kind, maxTravelers[kind])
+		}
+	}
+}
+
+// TestSimulationStress keeps hundreds of travelers moving, pushing each
+// other and dying in danger zones on a small card on the real clock and
+// checks every picture, run it with -race.
+func TestSimulationStress(t *testing.T) {
+	if testing.Short() {
+		t.Skip("the stress test takes several seconds")
+	}
+
+	for _, topology := range []TopologyE{TopologyGrid, TopologyHex} {
+		t.Run(string(topology), func(t *testing.T) {
+			exits := make([][]bool, 5)
+			for y := range exits {
+				exits[y] = make([]bool, 5)
+			}
+			exits[0][0], exits[4][4] = true, true
+
+			config := Config{
+				Width:            5,
+				Height:           5,
+				MaxTravelers:     10,
+				Probs:            NodeProbs{Spawn: 0.3, Move: 0.9, Wild: 0.3, Danger: 0.05},
+				Topology:         topology,
+				Exits:            exits,
+				Lifetime:         5,
+				DangerZonesKill:  true,
+				DangerZoneSpread: 0.2,
+				Seed:             1,
+				Speed:            200,
+				Duration:         2 * time.Second,
+			}
+
+			pictures := 0
+			maxTravelers := map[string]int{SpeciesNormal: config.MaxTravelers, SpeciesWild: 25}
+			config.Observers = []PictureObserver{PictureObserverFunc(func(picture *Picture) {
+				pictures++
+				checkPicture(t, picture, maxTravelers)
+			})}
+
+			simulation, err := NewSimulation(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			simulation.Start()
+			<-simulation.Done()
+			statistics, _ := simulation.Stop()
+
+			spawned := uint(0)
+			for _, species := range statistics.Species {
+				spawned += species.Spawned
+			}
+			if spawned < 200 || pictures < 10 {
+				t.Errorf("only %d travelers spawned and %d pictures taken", spawned, pictures)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package travelers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Type aliases
+
+type MovementE string
+
+// Constants
+
+const ( // MovementE
+	MovementStill  MovementE = "still"
+	MovementRandom MovementE = "random"
+	MovementGoal   MovementE = "goal"
+)
+
+const (
+	SpeciesNormal string = "normal"
+	SpeciesWild   string = "wild"
+
+	defaultWildHealth TravelerHealth = 5
+)
+
+// a glyph must not be mistaken for the other cells of the text pictures
+const reservedGlyphs = ".#Xt"
+
+// Structures - Species
+
+// Species describes a kind of travelers. An empty node spawns a traveler of
+// the first species which draws its SpawnProb, as long as fewer than
+// MaxTravelers (the number of free nodes if 0) of them are on the card.
+//
+// A traveler which does not stand still moves with MoveProb on every tick.
+// A Displaceable traveler makes room for the travelers of the species which
+// are not displaceable, a traveler which SurvivesDangerZones uses a danger
+// zone up instead of being killed by it. A traveler leaves the card after
+// Lifetime ticks and expires after Health ticks (if they are not 0).
+// The pictures show the Glyph of a species in place of the traveler ids.
+type Species struct {
+	Name                string         `json:"name"`
+	Glyph               string         `json:"glyph,omitempty"`
+	SpawnProb           float64        `json:"spawn_prob"`
+	MaxTravelers        int            `json:"max_travelers,omitempty"`
+	Movement            MovementE      `json:"movement"`
+	MoveProb            float64        `json:"move_prob,omitempty"`
+	Displaceable        bool           `json:"displaceable,omitempty"`
+	SurvivesDangerZones bool           `json:"survives_danger_zones,omitempty"`
+	Lifetime            uint           `json:"lifetime,omitempty"`
+	Health              TravelerHealth `json:"health,omitempty"`
+
+	// position in the registry, which is also the order of spawning
+	index int
+}
+
+// DefaultSpecies returns the travelers of the original simulation: the
+// normal ones and the wild ones, which stand still until they expire and
+// make room for the normal ones.
+func DefaultSpecies(
+	probs NodeProbs, maxTravelers int, routing RoutingE, lifetime uint,
+) []Species {
+	return []Species{
+		{
+			Name:         SpeciesNormal,
+			SpawnProb:    probs.Spawn,
+			MaxTravelers: maxTravelers,
+			Movement:     MovementE(routing),
+			MoveProb:     probs.Move,
+			Lifetime:     lifetime,
+		},
+		{
+			Name:         SpeciesWild,
+			Glyph:        "*",
+			SpawnProb:    probs.Wild,
+			Movement:     MovementStill,
+			Displaceable: true,
+			Health:       defaultWildHealth,
+		},
+	}
+}
+
+// LoadSpecies reads a JSON array of species.
+func LoadSpecies(reader io.Reader) ([]Species, error) {
+	decoder := json.NewDecoder(reader)
+	decoder.DisallowUnknownFields()
+
+	var species []Species
+	if err := decoder.Decode(&species); err != nil {
+		return nil, err
+	}
+	if len(species) == 0 {
+		return nil, errors.New("no species defined")
+	}
+	return species, nil
+}
+
+func (species *Species) validate(freeNodes int) error {
+	if species.Name == "" {
+		return errors.New("name must not be empty")
+	}
+
+	if len(species.Glyph) > 1 || (species.Glyph != "" &&
+		(species.Glyph[0] <= ' ' || species.Glyph[0] > '~' ||
+			(species.Glyph[0] >= '0' && species.Glyph[0] <= '9') ||
+			strings.Contains(reservedGlyphs, species.Glyph))) {
+		return fmt.Errorf("glyph must be a single printable character other than a digit, ' ' or one of %q",
+			reservedGlyphs)
+	}
+
+	if species.SpawnProb < 0 || species.SpawnProb > 1 {
+		return errors.New("spawn_prob must be in range [0, 1]")
+	}
+	if species.MoveProb < 0 || species.MoveProb > 1 {
+		return errors.New("move_prob must be in range [0, 1]")
+	}
+
+	if species.MaxTravelers == 0 {
+		species.MaxTravelers = freeNodes
+	}
+	if species.MaxTravelers < 0 || species.MaxTravelers > freeNodes {
+		return fmt.Errorf("max_travelers must be in range [0, %d] (free nodes)", freeNodes)
+	}
+
+	switch species.Movement {
+	case "":
+		species.Movement = MovementStill
+	case MovementStill, MovementRandom, MovementGoal:
+	default:
+		return fmt.Errorf("movement must be one of: %s, %s, %s",
+			MovementGoal, MovementRandom, MovementStill)
+	}
+
+	return nil
+}
+
+func (species *Species) moves() bool {
+	return species.Movement != MovementStill
+}
+
+// newSpeciesRegistry validates the species and gives every one of them its
+// place in the registry.
+func newSpeciesRegistry(species []Species, freeNodes int) ([]*Species, error) {
+	registry := make([]*Species, len(species))
+	names := make(map[string]bool)
+
+	for i := range species {
+		current := species[i]
+		if err := current.validate(freeNodes); err != nil {
+			return nil, fmt.Errorf("invalid species %q - %v", current.Name, err)
+		}
+		if names[current.Name] {
+			return nil, fmt.Errorf("invalid species %q - defined twice", current.Name)
+		}
+		names[current.Name] = true
+
+		current.index = i
+		registry[i] = &current
+	}
+
+	return registry, nil
+}
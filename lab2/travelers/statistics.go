@@ -0,0 +1,232 @@
+package travelers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Structures - Statistics
+
+// MoveStatistics.Arrivals and Replans count the destinations reached and
+// the routes planned again after a denied move with goal routing.
+type MoveStatistics struct {
+	Successful uint `json:"successful"`
+	Denied     uint `json:"denied"`
+	Arrivals   uint `json:"arrivals,omitempty"`
+	Replans    uint `json:"replans,omitempty"`
+}
+
+// TravelerMoveStatistics are the moves of a single traveler, the id of
+// a traveler who is gone may be given to another one later.
+type TravelerMoveStatistics struct {
+	Traveler TravelerId `json:"traveler"`
+	MoveStatistics
+}
+
+// NodeStatistics are owned by the node goroutine and may only be read
+// after the nodes were stopped.
+type NodeStatistics struct {
+	ticks           uint
+	occupiedTicks   uint
+	deniedReserves  uint
+	blockedRequests uint
+}
+
+// SpeciesStatistics count the travelers of a species by the way they were
+// gone, the ones still on the card when the run stopped are only spawned.
+type SpeciesStatistics struct {
+	MaxTravelers int  `json:"max_travelers"`
+	Spawned      uint `json:"spawned"`
+	Killed       uint `json:"killed"`
+	Left         uint `json:"left"`
+	Expired      uint `json:"expired"`
+	Relocated    uint `json:"relocated"`
+}
+
+// Statistics.Moves only holds the travelers of the species which move, by
+// the number of their spawn (starting with 1) in the run. The
+// Partial statistics of an aborted run leave out the travelers still on the
+// card and the occupancy.
+type Statistics struct {
+	mutex           sync.Mutex
+	Partial         bool                               `json:"partial,omitempty"`
+	Seconds         float64                            `json:"seconds"`
+	Pictures        uint                               `json:"pictures"`
+	Species         map[string]*SpeciesStatistics      `json:"species"`
+	Moves           map[uint64]*TravelerMoveStatistics `json:"moves"`
+	TotalMoves      MoveStatistics                     `json:"total_moves"`
+	DeniedReserves  uint                               `json:"denied_reserves"`
+	BlockedRequests uint                               `json:"blocked_requests"`
+	Occupancy       [][]float64                        `json:"occupancy"`
+
+	// in the order of the registry
+	speciesNames []string
+	goalRouting  bool
+}
+
+// Print lists the moves of every traveler and the occupancy of every node
+// only if there are not too many of them.
+const (
+	maxPrintedTravelers = 100
+	maxPrintedOccupancy = 20
+)
+
+func (moves *MoveStatistics) add(other MoveStatistics) {
+	moves.Successful += other.Successful
+	moves.Denied += other.Denied
+	moves.Arrivals += other.Arrivals
+	moves.Replans += other.Replans
+}
+
+func newStatistics(species []*Species) *Statistics {
+	statistics := &Statistics{
+		Species: make(map[string]*SpeciesStatistics),
+		Moves:   make(map[uint64]*TravelerMoveStatistics),
+	}
+
+	for _, current := range species {
+		statistics.Species[current.Name] = &SpeciesStatistics{MaxTravelers: current.MaxTravelers}
+		statistics.speciesNames = append(statistics.speciesNames, current.Name)
+		if current.Movement == MovementGoal {
+			statistics.goalRouting = true
+		}
+	}
+	return statistics
+}
+
+func (statistics *Statistics) addTraveler(traveler *Traveler, outcome TravelerOutcomeE) {
+	statistics.mutex.Lock()
+	defer statistics.mutex.Unlock()
+
+	speciesStatistics := statistics.Species[traveler.species.Name]
+	speciesStatistics.Spawned++
+	speciesStatistics.Relocated += traveler.relocations
+	switch outcome {
+	case travelerKilled:
+		speciesStatistics.Killed++
+	case travelerLeft:
+		speciesStatistics.Left++
+	case travelerExpired:
+		speciesStatistics.Expired++
+	}
+
+	if !traveler.species.moves() {
+		return
+	}
+
+	statistics.Moves[traveler.spawnNumber] = &TravelerMoveStatistics{
+		Traveler:       traveler.id,
+		MoveStatistics: traveler.moves,
+	}
+	statistics.TotalMoves.add(traveler.moves)
+}
+
+// collect may only be called once all the actors of the card were stopped.
+func (statistics *Statistics) collect(card *TravelersCard, camera *Camera, elapsed time.Duration) {
+	statistics.mutex.Lock()
+	defer statistics.mutex.Unlock()
+
+	statistics.Seconds = elapsed.Seconds()
+	statistics.Pictures = camera.pictureCount
+	statistics.Occupancy = make([][]float64, card.height)
+
+	for y := range card.grid {
+		statistics.Occupancy[y] = make([]float64, card.width)
+		for x, node := range card.grid[y] {
+			nodeStatistics := &node.statistics
+			statistics.DeniedReserves += nodeStatistics.deniedReserves
+			statistics.BlockedRequests += nodeStatistics.blockedRequests
+
+			if nodeStatistics.ticks > 0 {
+				statistics.Occupancy[y][x] =
+					float64(nodeStatistics.occupiedTicks) / float64(nodeStatistics.ticks)
+			}
+		}
+	}
+}
+
+// collectPartial does not touch the nodes, the actors may still be running.
+func (statistics *Statistics) collectPartial(card *TravelersCard, elapsed time.Duration) {
+	statistics.mutex.Lock()
+	defer statistics.mutex.Unlock()
+
+	statistics.Partial = true
+	statistics.Seconds = elapsed.Seconds()
+	if picture := card.latestPicture.Load(); picture != nil {
+		statistics.Pictures = picture.number
+	}
+}
+
+func (statistics *Statistics) Print(w io.Writer) {
+	statistics.mutex.Lock()
+	defer statistics.mutex.Unlock()
+
+	if statistics.Partial {
+		fmt.Fprintf(w, "Simulation aborted after %.1fs, only the travelers gone by then are counted\n",
+			statistics.Seconds)
+	} else {
+		fmt.Fprintf(w, "Simulation finished after %.1fs\n", statistics.Seconds)
+	}
+	fmt.Fprintf(w, "Pictures taken: %d\n", statistics.Pictures)
+	for _, name := range statistics.speciesNames {
+		species := statistics.Species[name]
+		fmt.Fprintf(w, "Travelers spawned (%s): %d, at most %d at once "+
+			"(killed by danger zones: %d, left the card: %d, expired: %d, relocated: %d)\n",
+			name, species.Spawned, species.MaxTravelers,
+			species.Killed, species.Left, species.Expired, species.Relocated)
+	}
+	fmt.Fprintf(w, "Denied requests: %d reserves, %d while blocked by the camera\n",
+		statistics.DeniedReserves, statistics.BlockedRequests)
+
+	fmt.Fprintf(w, "Moves (successful/denied): %d/%d\n",
+		statistics.TotalMoves.Successful, statistics.TotalMoves.Denied)
+	if statistics.goalRouting {
+		fmt.Fprintf(w, "Destinations reached: %d (routes planned again: %d)\n",
+			statistics.TotalMoves.Arrivals, statistics.TotalMoves.Replans)
+	}
+	if len(statistics.Moves) <= maxPrintedTravelers {
+		spawnNumbers := make([]uint64, 0, len(statistics.Moves))
+		for spawnNumber := range statistics.Moves {
+			spawnNumbers = append(spawnNumbers, spawnNumber)
+		}
+		sort.Slice(spawnNumbers, func(i, j int) bool { return spawnNumbers[i] < spawnNumbers[j] })
+
+		// the travelers in the order they spawned, with the id they had
+		for _, spawnNumber := range spawnNumbers {
+			moves := statistics.Moves[spawnNumber]
+			fmt.Fprintf(w, "  %3d. [%02d] %d/%d\n",
+				spawnNumber, moves.Traveler, moves.Successful, moves.Denied)
+		}
+	}
+
+	if len(statistics.Occupancy) == 0 || len(statistics.Occupancy) > maxPrintedOccupancy ||
+		len(statistics.Occupancy[0]) > maxPrintedOccupancy {
+		return
+	}
+
+	fmt.Fprintln(w, "Occupancy:")
+	for y := range statistics.Occupancy {
+		row := make([]string, len(statistics.Occupancy[y]))
+		for x, occupancy := range statistics.Occupancy[y] {
+			row[x] = fmt.Sprintf("[%3.0f%%]", occupancy*100)
+		}
+		fmt.Fprintln(w, " ", strings.Join(row, " "))
+	}
+}
+
+func (statistics *Statistics) WriteJSON(path string) error {
+	statistics.mutex.Lock()
+	defer statistics.mutex.Unlock()
+
+	data, err := json.MarshalIndent(statistics, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
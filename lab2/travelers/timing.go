@@ -0,0 +1,209 @@
+package travelers
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Type aliases
+
+type DistributionE string
+
+// Constants
+
+const ( // DistributionE
+	DistributionFixed       DistributionE = "fixed"
+	DistributionUniform     DistributionE = "uniform"
+	DistributionExponential DistributionE = "exponential"
+)
+
+// Structures - Timing
+
+// Timing is the distribution of the time an actor waits between its steps.
+// A fixed timing always waits Mean, a uniform one waits from [Mean - Spread,
+// Mean + Spread] and an exponential one waits Mean on average.
+type Timing struct {
+	Distribution DistributionE
+	Mean         time.Duration
+	Spread       time.Duration
+}
+
+func FixedTiming(duration time.Duration) Timing {
+	return Timing{Distribution: DistributionFixed, Mean: duration}
+}
+
+// ParseTiming reads a timing written as "fixed:2s", "uniform:1s..3s" or
+// "exponential:2s", a bare duration is a fixed timing.
+func ParseTiming(value string) (Timing, error) {
+	name, parameters, found := strings.Cut(value, ":")
+	if !found {
+		name, parameters = string(DistributionFixed), value
+	}
+
+	switch distribution := DistributionE(name); distribution {
+	case DistributionFixed, DistributionExponential:
+		mean, err := time.ParseDuration(parameters)
+		if err != nil {
+			return Timing{}, fmt.Errorf("invalid timing %q - %v", value, err)
+		}
+		return Timing{Distribution: distribution, Mean: mean}, nil
+
+	case DistributionUniform:
+		lowerStr, upperStr, found := strings.Cut(parameters, "..")
+		if !found {
+			return Timing{}, fmt.Errorf("invalid timing %q - expected uniform:<min>..<max>", value)
+		}
+		lower, err := time.ParseDuration(lowerStr)
+		if err != nil {
+			return Timing{}, fmt.Errorf("invalid timing %q - %v", value, err)
+		}
+		upper, err := time.ParseDuration(upperStr)
+		if err != nil {
+			return Timing{}, fmt.Errorf("invalid timing %q - %v", value, err)
+		}
+		return Timing{Distribution: distribution, Mean: (lower + upper) / 2, Spread: (upper - lower) / 2}, nil
+
+	default:
+		return Timing{}, fmt.Errorf("invalid timing %q - unknown distribution %q", value, name)
+	}
+}
+
+func (timing Timing) String() string {
+	if timing.Distribution == DistributionUniform {
+		return fmt.Sprintf("%s:%v..%v",
+			timing.Distribution, timing.Mean-timing.Spread, timing.Mean+timing.Spread)
+	}
+	return fmt.Sprintf("%s:%v", timing.Distribution, timing.Mean)
+}
+
+// MarshalText and UnmarshalText write a timing the same way as String and
+// ParseTiming, so it takes a single string in a config file.
+func (timing Timing) MarshalText() ([]byte, error) {
+	return []byte(timing.String()), nil
+}
+
+func (timing *Timing) UnmarshalText(text []byte) error {
+	parsed, err := ParseTiming(string(text))
+	if err != nil {
+		return err
+	}
+	*timing = parsed
+	return nil
+}
+
+// Duration is a time.Duration written as e.g. "30s" in a config file.
+type Duration time.Duration
+
+func (duration Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(duration).String()), nil
+}
+
+func (duration *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return fmt.Errorf("invalid duration %q - must be e.g. 30s", text)
+	}
+	*duration = Duration(parsed)
+	return nil
+}
+
+func (timing Timing) validate() error {
+	switch timing.Distribution {
+	case DistributionFixed, DistributionExponential:
+		if timing.Spread != 0 {
+			return fmt.Errorf("%s timing cannot have a spread", timing.Distribution)
+		}
+	case DistributionUniform:
+		if timing.Spread < 0 || timing.Spread > timing.Mean {
+			return errors.New("spread of a uniform timing must be in range [0, mean]")
+		}
+	default:
+		return fmt.Errorf("unknown distribution %q", timing.Distribution)
+	}
+
+	if timing.Mean <= 0 {
+		return errors.New("mean of a timing must be positive")
+	}
+	return nil
+}
+
+func (timing Timing) scaled(speed float64) Timing {
+	return Timing{
+		Distribution: timing.Distribution,
+		Mean:         time.Duration(float64(timing.Mean) / speed),
+		Spread:       time.Duration(float64(timing.Spread) / speed),
+	}
+}
+
+// sample draws the next wait, a fixed timing does not use the rng so runs
+// with the default timings replay the same as before the timings existed.
+// The wait is never shorter than minWait, so that the virtual clock moves on.
+func (timing Timing) sample(rng *rand.Rand) time.Duration {
+	wait := timing.Mean
+	switch timing.Distribution {
+	case DistributionUniform:
+		wait = timing.Mean - timing.Spread + time.Duration(rng.Int63n(int64(2*timing.Spread)+1))
+	case DistributionExponential:
+		wait = time.Duration(rng.ExpFloat64() * float64(timing.Mean))
+	}
+	return max(wait, minWait)
+}
+
+// Structures - Timings
+
+// Timings of the actors, a zero Timing stands for the default one.
+type Timings struct {
+	// between the ticks of the nodes spawning travelers and danger zones
+	Spawn Timing
+	// between the moves of a traveler
+	Think Timing
+	// between the ticks of a traveler who stands still, e.g. a wild one
+	Patience Timing
+	// between the pictures of the camera
+	Camera Timing
+}
+
+func DefaultTimings() Timings {
+	return Timings{
+		Spawn:    FixedTiming(sleepDuration),
+		Think:    FixedTiming(sleepDuration),
+		Patience: FixedTiming(sleepDuration),
+		Camera:   FixedTiming(sleepDuration),
+	}
+}
+
+func (timings *Timings) validate() error {
+	defaults := DefaultTimings()
+	fields := []struct {
+		name         string
+		timing       *Timing
+		defaultValue Timing
+	}{
+		{"spawn", &timings.Spawn, defaults.Spawn},
+		{"think", &timings.Think, defaults.Think},
+		{"patience", &timings.Patience, defaults.Patience},
+		{"camera", &timings.Camera, defaults.Camera},
+	}
+
+	for _, field := range fields {
+		if *field.timing == (Timing{}) {
+			*field.timing = field.defaultValue
+		}
+		if err := field.timing.validate(); err != nil {
+			return fmt.Errorf("invalid value of %s timing - %v", field.name, err)
+		}
+	}
+	return nil
+}
+
+func (timings Timings) scaled(speed float64) Timings {
+	return Timings{
+		Spawn:    timings.Spawn.scaled(speed),
+		Think:    timings.Think.scaled(speed),
+		Patience: timings.Patience.scaled(speed),
+		Camera:   timings.Camera.scaled(speed),
+	}
+}
@@ -0,0 +1,286 @@
+package travelers
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Type aliases
+
+type (
+	TopologyE string
+	EdgeE     uint8
+)
+
+// Constants
+
+const ( // TopologyE
+	TopologyGrid  TopologyE = "grid"
+	TopologyTorus TopologyE = "torus"
+	TopologyGrid8 TopologyE = "grid8"
+	TopologyHex   TopologyE = "hex"
+)
+
+// Every edge belongs to the node above it or on its left, which is the one
+// drawing the blur trail of a traveler crossing it.
+const ( // EdgeE
+	edgeEast      EdgeE = 1 << iota
+	edgeSouth     EdgeE = 1 << iota
+	edgeSouthEast EdgeE = 1 << iota
+	edgeSouthWest EdgeE = 1 << iota
+)
+
+const (
+	wallMapFree byte = '.'
+	wallMapWall byte = '#'
+	wallMapExit byte = 'E'
+)
+
+// Structures - Topology
+
+type Direction struct {
+	edge EdgeE
+	dx   int
+	dy   int
+}
+
+// directions of the edges a node owns, the ones of the hex grid depend on
+// the parity of the row as odd rows are shifted right by half a node
+var topologyDirections = map[TopologyE][2][]Direction{
+	TopologyGrid: {
+		{{edgeEast, 1, 0}, {edgeSouth, 0, 1}},
+		{{edgeEast, 1, 0}, {edgeSouth, 0, 1}},
+	},
+	TopologyTorus: {
+		{{edgeEast, 1, 0}, {edgeSouth, 0, 1}},
+		{{edgeEast, 1, 0}, {edgeSouth, 0, 1}},
+	},
+	TopologyGrid8: {
+		{{edgeEast, 1, 0}, {edgeSouth, 0, 1}, {edgeSouthEast, 1, 1}, {edgeSouthWest, -1, 1}},
+		{{edgeEast, 1, 0}, {edgeSouth, 0, 1}, {edgeSouthEast, 1, 1}, {edgeSouthWest, -1, 1}},
+	},
+	TopologyHex: {
+		{{edgeEast, 1, 0}, {edgeSouthEast, 0, 1}, {edgeSouthWest, -1, 1}},
+		{{edgeEast, 1, 0}, {edgeSouthEast, 1, 1}, {edgeSouthWest, 0, 1}},
+	},
+}
+
+func TopologyNames() []string {
+	names := make([]string, 0, len(topologyDirections))
+	for name := range topologyDirections {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	return names
+}
+
+type TopologyLink struct {
+	c     Coordinates
+	owner Coordinates
+	edge  EdgeE
+}
+
+// Topology knows which nodes of the card are connected. It is immutable
+// once created, so all the actors can share it.
+type Topology struct {
+	kind   TopologyE
+	width  int
+	height int
+	walls  [][]bool
+	links  [][][]TopologyLink
+	free   int
+}
+
+func newTopology(kind TopologyE, width int, height int, walls [][]bool) *Topology {
+	topology := &Topology{
+		kind:   kind,
+		width:  width,
+		height: height,
+		walls:  walls,
+		links:  make([][][]TopologyLink, height),
+	}
+	for y := range topology.links {
+		topology.links[y] = make([][]TopologyLink, width)
+	}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			owner := Coordinates{x, y}
+			for _, direction := range topologyDirections[kind][y%2] {
+				c, exists := topology.step(owner, direction)
+				if exists {
+					topology.link(owner, c, owner, direction.edge)
+					topology.link(c, owner, owner, direction.edge)
+				}
+			}
+		}
+	}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if !topology.isWall(Coordinates{x, y}) {
+				topology.free++
+			}
+		}
+	}
+
+	return topology
+}
+
+func (topology *Topology) step(c Coordinates, direction Direction) (Coordinates, bool) {
+	x, y := c.x+direction.dx, c.y+direction.dy
+	if topology.kind == TopologyTorus {
+		x = (x + topology.width) % topology.width
+		y = (y + topology.height) % topology.height
+	}
+
+	next := Coordinates{x, y}
+	if !topology.contains(next) || topology.isWall(next) || topology.isWall(c) || next == c {
+		return next, false
+	}
+	return next, true
+}
+
+func (topology *Topology) link(from Coordinates, to Coordinates, owner Coordinates, edge EdgeE) {
+	for _, link := range topology.links[from.y][from.x] {
+		if link.c == to {
+			// a torus narrower than 3 nodes connects two nodes twice
+			return
+		}
+	}
+
+	topology.links[from.y][from.x] = append(topology.links[from.y][from.x],
+		TopologyLink{c: to, owner: owner, edge: edge})
+}
+
+func (topology *Topology) contains(c Coordinates) bool {
+	return c.x >= 0 && c.x < topology.width && c.y >= 0 && c.y < topology.height
+}
+
+func (topology *Topology) isWall(c Coordinates) bool {
+	return topology.walls != nil && topology.walls[c.y][c.x]
+}
+
+// neighbours returns the nodes a traveler can move to from c, always in
+// the same order.
+func (topology *Topology) neighbours(c Coordinates) []TopologyLink {
+	return topology.links[c.y][c.x]
+}
+
+// edge returns the edge between the two neighbours if it belongs to from.
+func (topology *Topology) edge(from Coordinates, to Coordinates) (EdgeE, bool) {
+	for _, link := range topology.links[from.y][from.x] {
+		if link.c == to {
+			return link.edge, link.owner == from
+		}
+	}
+	return 0, false
+}
+
+func (topology *Topology) freeNodes() int {
+	return topology.free
+}
+
+// distance is the number of moves between the nodes if there were no walls,
+// so it never overestimates the length of a path.
+func (topology *Topology) distance(from Coordinates, to Coordinates) int {
+	dx, dy := abs(to.x-from.x), abs(to.y-from.y)
+
+	switch topology.kind {
+	case TopologyTorus:
+		return min(dx, topology.width-dx) + min(dy, topology.height-dy)
+
+	case TopologyGrid8:
+		return max(dx, dy)
+
+	case TopologyHex:
+		// odd rows are shifted right, so the axial column of a node is
+		// x - floor(y / 2)
+		dq := (to.x - to.y/2) - (from.x - from.y/2)
+		dr := to.y - from.y
+		return (abs(dq) + abs(dr) + abs(dq+dr)) / 2
+
+	default:
+		return dx + dy
+	}
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+// LoadMap reads a map with a line per row of the card, where '.' is a
+// free node, '#' is a wall and 'E' is an exit. All the rows must have the
+// same length. The exits are nil if the map has none.
+func LoadMap(reader io.Reader) ([][]bool, [][]bool, error) {
+	var walls, exits [][]bool
+	hasExits := false
+
+	scanner := bufio.NewScanner(reader)
+	for line := 1; scanner.Scan(); line++ {
+		row := strings.TrimRight(scanner.Text(), " \t\r")
+		if row == "" {
+			continue
+		}
+
+		if len(walls) > 0 && len(row) != len(walls[0]) {
+			return nil, nil, fmt.Errorf("line %d: row has %d nodes instead of %d",
+				line, len(row), len(walls[0]))
+		}
+
+		walls = append(walls, make([]bool, len(row)))
+		exits = append(exits, make([]bool, len(row)))
+		for x := 0; x < len(row); x++ {
+			switch row[x] {
+			case wallMapFree:
+			case wallMapWall:
+				walls[len(walls)-1][x] = true
+			case wallMapExit:
+				exits[len(exits)-1][x] = true
+				hasExits = true
+			default:
+				return nil, nil, fmt.Errorf("line %d: unknown node %q - must be '%c', '%c' or '%c'",
+					line, row[x], wallMapFree, wallMapWall, wallMapExit)
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	if len(walls) == 0 {
+		return nil, nil, errors.New("the map is empty")
+	}
+	if !hasExits {
+		exits = nil
+	}
+	return walls, exits, nil
+}
+
+// ParseExits reads a list of exits written as "x,y;x,y" for a card of the
+// given size.
+func ParseExits(value string, width int, height int) ([][]bool, error) {
+	exits := make([][]bool, height)
+	for y := range exits {
+		exits[y] = make([]bool, width)
+	}
+
+	for _, exit := range strings.Split(value, ";") {
+		var x, y int
+		if _, err := fmt.Sscanf(strings.TrimSpace(exit), "%d,%d", &x, &y); err != nil {
+			return nil, fmt.Errorf("invalid exit %q - must be x,y", exit)
+		}
+		if x < 0 || x >= width || y < 0 || y >= height {
+			return nil, fmt.Errorf("invalid exit %q - must be on the card", exit)
+		}
+		exits[y][x] = true
+	}
+	return exits, nil
+}
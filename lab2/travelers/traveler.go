@@ -0,0 +1,395 @@
+package travelers
+
+import (
+	"context"
+	"math/rand"
+	"slices"
+)
+
+// Structures - Traveler
+
+// Traveler behaves as its species tells it to. On every tick it first dies
+// if a danger zone started on its node, then a displaceable one makes room
+// for the traveler who wants its node, one with health loses a point, even
+// if it keeps being asked to make room but cannot, and one which did neither
+// moves unless it stands still. The spawnNumber tells apart the travelers
+// who had the same id.
+type Traveler struct {
+	id                  TravelerId
+	spawnNumber         uint64
+	c                   Coordinates
+	species             *Species
+	moveProb            float64
+	hp                  TravelerHealth
+	moves               MoveStatistics
+	relocations         uint
+	ticks               uint
+	route               *Route
+	dangerChannel       DangerChannel
+	displacementChannel DisplacementChannel
+	rng                 *rand.Rand
+	clockActor          ClockActor
+}
+
+func newTraveler(
+	id TravelerId, c Coordinates, species *Species, clockActor ClockActor, seed int64,
+) Traveler {
+	traveler := Traveler{
+		id:            id,
+		c:             c,
+		species:       species,
+		moveProb:      species.MoveProb,
+		hp:            species.Health,
+		dangerChannel: make(DangerChannel, 1),
+		rng:           newRand(seed),
+		clockActor:    clockActor,
+	}
+	if species.Displaceable {
+		traveler.displacementChannel = make(DisplacementChannel, bufferSize)
+	}
+	if species.Movement == MovementGoal {
+		traveler.route = newRoute()
+	}
+	return traveler
+}
+
+func (traveler *Traveler) key() TravelerKey {
+	return TravelerKey{id: traveler.id, spawnNumber: traveler.spawnNumber}
+}
+
+func (traveler *Traveler) start(ctx context.Context, card *TravelersCard) {
+	defer card.waitGroup.Done()
+	defer traveler.clockActor.leave()
+
+	outcome := travelerStopped
+	defer func() {
+		if outcome == travelerStopped && traveler.species.Displaceable {
+			traveler.stopNode(card)
+		}
+		if traveler.route != nil {
+			traveler.moves.Arrivals = traveler.route.arrivals
+			traveler.moves.Replans = traveler.route.replans
+		}
+		card.statistics.addTraveler(traveler, outcome)
+
+		if outcome != travelerStopped {
+			card.travelerIdManager.release(traveler.species, traveler.id)
+		}
+	}()
+
+	timing := card.timings.Think
+	if !traveler.species.moves() {
+		timing = card.timings.Patience
+	}
+
+	for traveler.clockActor.sleep(ctx, timing.sample(traveler.rng)) {
+		if !card.pauseGate.enter() {
+			// a paused traveler only makes room, the nodes deny its moves
+			if traveler.makeRoomWhilePaused(card) {
+				outcome = travelerKilled
+				traveler.stopDisplacements(card)
+				return
+			}
+			continue
+		}
+
+		tickOutcome, isDone := traveler.tick(ctx, card)
+		card.pauseGate.leave()
+		if isDone {
+			outcome = tickOutcome
+			return
+		}
+	}
+}
+
+// tick returns true once the traveler is gone, along with the way it was.
+func (traveler *Traveler) tick(ctx context.Context, card *TravelersCard) (TravelerOutcomeE, bool) {
+	traveler.ticks++
+
+	select {
+	case <-traveler.dangerChannel:
+		traveler.stopDisplacements(card)
+		traveler.terminate(card)
+		return travelerKilled, true
+
+	default:
+	}
+
+	madeRoom := false
+	if traveler.species.Displaceable {
+		select {
+		case displacement := <-traveler.displacementChannel:
+			if traveler.makeRoom(card, displacement) {
+				traveler.stopDisplacements(card)
+				return travelerKilled, true
+			}
+			madeRoom = true
+
+		default:
+		}
+	}
+
+	if traveler.species.Health > 0 {
+		traveler.hp--
+		health := traveler.hp
+		card.eventLog.emit(Event{
+			Type:     EventHealth,
+			Traveler: traveler.id,
+			Kind:     traveler.species.Name,
+			X:        traveler.c.x,
+			Y:        traveler.c.y,
+			Health:   &health,
+		})
+
+		if traveler.hp == 0 {
+			// a dying traveler cannot make room anymore
+			traveler.stopDisplacements(card)
+			if traveler.terminate(card) {
+				return travelerKilled, true
+			}
+			return travelerExpired, true
+		}
+	}
+
+	if madeRoom {
+		return travelerStopped, false
+	}
+
+	if traveler.mustLeave(card) {
+		switch traveler.leave(card) {
+		case requestAccepted:
+			traveler.stopDisplacements(card)
+			return travelerLeft, true
+
+		case terminateTraveler:
+			traveler.stopDisplacements(card)
+			return travelerKilled, true
+		}
+	}
+
+	if !traveler.species.moves() || traveler.rng.Float64() > traveler.moveProb {
+		return travelerStopped, false
+	}
+
+	newC := traveler.nextPosition(card)
+	if traveler.route != nil && newC == traveler.c {
+		return travelerStopped, false
+	}
+
+	moved, terminate := traveler.move(card, newC, nil)
+	if moved {
+		traveler.moves.Successful++
+		if traveler.route != nil {
+			traveler.route.moved(newC)
+		}
+	} else if !terminate && ctx.Err() == nil {
+		traveler.moves.Denied++
+		if traveler.route != nil {
+			traveler.route.denied(newC)
+		}
+	}
+
+	if terminate {
+		traveler.stopDisplacements(card)
+		return travelerKilled, true
+	}
+	return travelerStopped, false
+}
+
+// makeRoomWhilePaused answers a node which asked the traveler to make room
+// for a move started before the pause, it returns true if the traveler was
+// killed on the way.
+func (traveler *Traveler) makeRoomWhilePaused(card *TravelersCard) bool {
+	if !traveler.species.Displaceable {
+		return false
+	}
+
+	select {
+	case displacement := <-traveler.displacementChannel:
+		return traveler.makeRoom(card, displacement)
+	default:
+		return false
+	}
+}
+
+// stopDisplacements keeps the nodes from asking a traveler who is gone to
+// make room.
+func (traveler *Traveler) stopDisplacements(card *TravelersCard) {
+	if traveler.species.Displaceable {
+		card.displacementRegistry.unregister(traveler.id)
+	}
+}
+
+// makeRoom moves the traveler to a random neighbour it can enter after
+// the node asked it to, or unlocks the node if it cannot. The traveler may
+// push the displaceable travelers on the neighbours in turn, as long as they
+// are not in the chain yet. It returns true if the traveler was killed on
+// the way.
+func (traveler *Traveler) makeRoom(card *TravelersCard, displacement Displacement) bool {
+	if displacement.c != traveler.c {
+		// the traveler has left the node since
+		traveler.unlockNode(card, displacement.c)
+		return false
+	}
+
+	chain := append(slices.Clone(displacement.chain), traveler.id)
+	neighbours := card.topology.neighbours(traveler.c)
+	for _, i := range traveler.rng.Perm(len(neighbours)) {
+		moved, terminate := traveler.move(card, neighbours[i].c, chain)
+		if terminate {
+			return true
+		}
+		if moved {
+			traveler.relocations++
+			if traveler.route != nil {
+				traveler.route.moved(neighbours[i].c)
+			}
+			return false
+		}
+	}
+
+	traveler.unlockNode(card, traveler.c)
+	return false
+}
+
+// move takes the traveler to newC, it returns whether the traveler got
+// there and whether it was killed on the way. A node whose displaceable
+// traveler is asked to make room suspends the reservation, the traveler
+// then lets the others run until the node tells it whether it got the node.
+func (traveler *Traveler) move(card *TravelersCard, newC Coordinates, chain []TravelerId) (bool, bool) {
+	defer card.waits.done(traveler.key())
+
+	currNode := card.grid[traveler.c.y][traveler.c.x]
+	newNode := card.grid[newC.y][newC.x]
+
+	reserveRequest := newNodeTravelerRequest(
+		travelerReserveNode, traveler.id, traveler.species, traveler.c)
+	reserveRequest.travelerData.dangerChannel = traveler.dangerChannel
+	reserveRequest.travelerData.clockActor = traveler.clockActor
+	reserveRequest.travelerData.chain = chain
+
+	response := card.request(traveler.key(), newNode, reserveRequest)
+	if response == requestSuspended {
+		<-traveler.clockActor.park()
+		response = <-reserveRequest.travelerResponse
+	}
+	if response != requestAccepted {
+		return false, false
+	}
+
+	releaseRequest := newNodeTravelerRequest(
+		travelerReleaseNode, traveler.id, traveler.species, newC)
+	for {
+		response := card.request(traveler.key(), currNode, releaseRequest)
+		if response == requestAccepted {
+			break
+		}
+		if response == terminateTraveler {
+			// a danger zone started on the node, the reservation is given back
+			cancelRequest := newNodeTravelerRequest(
+				travelerReleaseNode, traveler.id, traveler.species, traveler.c)
+			for card.request(traveler.key(), newNode, cancelRequest) != requestAccepted {
+			}
+			return false, true
+		}
+	}
+
+	moved, terminate := false, false
+	assignRequest := newNodeTravelerRequest(
+		travelerAssignNode, traveler.id, traveler.species, traveler.c)
+	for {
+		response := card.request(traveler.key(), newNode, assignRequest)
+
+		if response == terminateTraveler {
+			terminate = true
+			break
+		} else if response == requestAccepted {
+			traveler.c = newC
+			moved = true
+			break
+		}
+	}
+
+	for {
+		response := card.request(traveler.key(), currNode, releaseRequest)
+		if response == requestAccepted {
+			break
+		}
+	}
+
+	return moved, terminate
+}
+
+func (traveler *Traveler) nextPosition(card *TravelersCard) Coordinates {
+	if traveler.route == nil {
+		return card.getNewPosition(traveler.c, traveler.rng)
+	}
+	return traveler.route.next(card, traveler.c, traveler.rng)
+}
+
+// Only the travelers who move look for the exits, a displaceable one which
+// stands still may be pushed onto an exit.
+func (traveler *Traveler) mustLeave(card *TravelersCard) bool {
+	return (traveler.species.moves() && card.isExit(traveler.c)) ||
+		(traveler.species.Lifetime > 0 && traveler.ticks >= traveler.species.Lifetime)
+}
+
+// leave is denied if the node does not let the traveler go yet, i.e. while
+// the camera takes a picture.
+func (traveler *Traveler) leave(card *TravelersCard) NodeResponseE {
+	defer card.waits.done(traveler.key())
+
+	leaveRequest := newNodeTravelerRequest(
+		travelerLeaveNode, traveler.id, traveler.species, traveler.c)
+	return card.request(traveler.key(), card.grid[traveler.c.y][traveler.c.x], leaveRequest)
+}
+
+// stopNode keeps the node of a traveler stopped with the simulation from
+// waiting for it to make room.
+func (traveler *Traveler) stopNode(card *TravelersCard) {
+	defer card.waits.done(traveler.key())
+
+	stopRequest := newNodeTravelerRequest(
+		travelerStopNode, traveler.id, traveler.species, traveler.c)
+	card.request(traveler.key(), card.grid[traveler.c.y][traveler.c.x], stopRequest)
+}
+
+func (traveler *Traveler) unlockNode(card *TravelersCard, c Coordinates) {
+	defer card.waits.done(traveler.key())
+
+	unlockRequest := newNodeTravelerRequest(
+		travelerUnlockNode, traveler.id, traveler.species, traveler.c)
+
+	// a denied unlock means the node is not waiting anymore
+	card.request(traveler.key(), card.grid[c.y][c.x], unlockRequest)
+}
+
+// terminate releases the node of a traveler who expired or was doomed by
+// a danger zone, it returns true in the latter case.
+func (traveler *Traveler) terminate(card *TravelersCard) bool {
+	defer card.waits.done(traveler.key())
+
+	currNode := card.grid[traveler.c.y][traveler.c.x]
+	releaseRequest := newNodeTravelerRequest(
+		travelerReleaseNode, traveler.id, traveler.species, traveler.c)
+
+	finalRelease := false
+
+	for {
+		response := card.request(traveler.key(), currNode, releaseRequest)
+		if response == terminateTraveler {
+			return true
+		}
+		if response == requestAccepted {
+			if !finalRelease {
+				finalRelease = true
+				continue
+			}
+			card.logEvent(EventTerminate, traveler.id, traveler.species, traveler.c)
+			return false
+		}
+
+		// the node is frozen by the camera
+		<-traveler.clockActor.after(card.retryDuration)
+	}
+}
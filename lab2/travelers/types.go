@@ -0,0 +1,109 @@
+package travelers
+
+import "time"
+
+// Type aliases
+
+type (
+	// General
+	TravelerId     int32
+	TravelerHealth uint8
+	DangerZone     int16
+
+	// State enums
+	NodeCameraStateE   uint8
+	NodeTravelerStateE uint8
+
+	// Request enums
+	NodeRequestE                 uint8
+	NodeResponseE                uint8
+	TravelerIdRequestE           uint8
+	DisplacementRegistryRequestE uint8
+
+	TravelerOutcomeE uint8
+)
+
+func (dangerZone DangerZone) active() bool {
+	return dangerZone > -1
+}
+
+// constants
+
+const ( // general
+	// default capacity of the request queues of the actors, the response
+	// channels always get it
+	bufferSize = 10
+
+	NullTraveler TravelerId = -1
+
+	dangerZoneNotActive    DangerZone = -1
+	initDangerZoneDuration DangerZone = 3
+)
+
+const ( // NodeCameraStateE
+	nodeRunning NodeCameraStateE = iota
+	nodeBlocekd NodeCameraStateE = iota
+	nodeFrozen  NodeCameraStateE = iota
+)
+
+const ( // NodeTravelerStateE
+	nodeAvailable   NodeTravelerStateE = iota
+	nodeReservedIn  NodeTravelerStateE = iota
+	nodeReservedOut NodeTravelerStateE = iota
+	nodeOccupied    NodeTravelerStateE = iota
+)
+
+const ( // NodeRequestE
+	cameraBlockNode     NodeRequestE = iota
+	cameraDrainNode     NodeRequestE = iota
+	cameraSnapshotNode  NodeRequestE = iota
+	cameraReleaseNode   NodeRequestE = iota
+	travelerReserveNode NodeRequestE = iota
+	travelerAssignNode  NodeRequestE = iota
+	travelerReleaseNode NodeRequestE = iota
+	travelerUnlockNode  NodeRequestE = iota
+	travelerLeaveNode   NodeRequestE = iota
+	travelerStopNode    NodeRequestE = iota
+	controlSpawnNode    NodeRequestE = iota
+	controlPauseNode    NodeRequestE = iota
+	controlResumeNode   NodeRequestE = iota
+	controlProbsNode    NodeRequestE = iota
+)
+
+const ( // NodeResponseE
+	requestAccepted   NodeResponseE = iota
+	requestDenied     NodeResponseE = iota
+	requestSuspended  NodeResponseE = iota
+	terminateTraveler NodeResponseE = iota
+)
+
+const ( // TravelerIdRequestE
+	getId     TravelerIdRequestE = iota
+	releaseId TravelerIdRequestE = iota
+)
+
+const ( // DisplacementRegistryRequestE
+	registerDisplacement   DisplacementRegistryRequestE = iota
+	lookupDisplacement     DisplacementRegistryRequestE = iota
+	unregisterDisplacement DisplacementRegistryRequestE = iota
+)
+
+const ( // TravelerOutcomeE
+	travelerStopped TravelerOutcomeE = iota
+	travelerKilled  TravelerOutcomeE = iota
+	travelerLeft    TravelerOutcomeE = iota
+	travelerExpired TravelerOutcomeE = iota
+)
+
+const (
+	sleepDuration time.Duration = 2 * time.Second
+	retryDuration time.Duration = sleepDuration / 20
+	minWait       time.Duration = time.Millisecond
+)
+
+// Structures - general
+
+type Coordinates struct {
+	x int
+	y int
+}
@@ -0,0 +1,398 @@
+package travelers
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	minWatchdogInterval time.Duration = 10 * time.Millisecond
+	inspectTimeout      time.Duration = time.Second
+)
+
+var nodeRequestNames = map[NodeRequestE]string{
+	travelerReserveNode: "reserve",
+	travelerAssignNode:  "assign",
+	travelerReleaseNode: "release",
+	travelerUnlockNode:  "unlock",
+	travelerLeaveNode:   "leave",
+	travelerStopNode:    "stop",
+}
+
+var nodeTravelerStateNames = map[NodeTravelerStateE]string{
+	nodeAvailable:   "available",
+	nodeReservedIn:  "reserved in by",
+	nodeReservedOut: "reserved out by",
+	nodeOccupied:    "occupied by",
+}
+
+// Structures - WaitTable
+
+// TravelerKey tells apart the travelers who had the same id, see
+// Traveler.spawnNumber.
+type TravelerKey struct {
+	id          TravelerId
+	spawnNumber uint64
+}
+
+func (key TravelerKey) less(other TravelerKey) bool {
+	if key.id != other.id {
+		return key.id < other.id
+	}
+	return key.spawnNumber < other.spawnNumber
+}
+
+// TravelerWait is the request a traveler keeps sending to a node until it
+// gets the response it needs.
+type TravelerWait struct {
+	species  string
+	node     Coordinates
+	request  NodeRequestE
+	since    time.Time
+	attempts uint
+}
+
+// WaitTable holds the outstanding requests of all the travelers, the
+// watchdog builds the wait-for graph from it. The ids are given again, so
+// the waits are kept by TravelerKey. A nil *WaitTable keeps nothing.
+type WaitTable struct {
+	mutex sync.Mutex
+	waits map[TravelerKey]TravelerWait
+}
+
+func newWaitTable() *WaitTable {
+	return &WaitTable{waits: make(map[TravelerKey]TravelerWait)}
+}
+
+// wait records another attempt of the traveler, sending the same request
+// to the same node again keeps the time the traveler started waiting.
+func (table *WaitTable) wait(key TravelerKey, species *Species, c Coordinates, request NodeRequestE) {
+	if table == nil {
+		return
+	}
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	wait, exists := table.waits[key]
+	if !exists || wait.node != c || wait.request != request {
+		wait = TravelerWait{species: species.Name, node: c, request: request, since: time.Now()}
+	}
+	wait.attempts++
+	table.waits[key] = wait
+}
+
+func (table *WaitTable) done(key TravelerKey) {
+	if table == nil {
+		return
+	}
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	delete(table.waits, key)
+}
+
+func (table *WaitTable) snapshot() map[TravelerKey]TravelerWait {
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	waits := make(map[TravelerKey]TravelerWait, len(table.waits))
+	for key, wait := range table.waits {
+		waits[key] = wait
+	}
+	return waits
+}
+
+// Structures - Watchdog
+
+// NodeInspection is the state of a node as seen by the watchdog.
+type NodeInspection struct {
+	c             Coordinates
+	cameraState   NodeCameraStateE
+	travelerState NodeTravelerStateE
+	travelerId    TravelerId
+	isWaiting     bool
+}
+
+func (inspection NodeInspection) String() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "node (%d,%d) is %s",
+		inspection.c.x, inspection.c.y, nodeTravelerStateNames[inspection.travelerState])
+	if inspection.travelerState != nodeAvailable {
+		fmt.Fprintf(&builder, " %d", inspection.travelerId)
+	}
+	if inspection.isWaiting {
+		builder.WriteString(", waiting for its traveler to make room")
+	}
+	switch inspection.cameraState {
+	case nodeBlocekd:
+		builder.WriteString(", blocked by the camera")
+	case nodeFrozen:
+		builder.WriteString(", frozen by the camera")
+	}
+	return builder.String()
+}
+
+// Watchdog checks the outstanding requests of the travelers in real time,
+// so it also notices a run on a virtual clock which stopped advancing.
+// A traveler is stuck once it has waited for longer than the threshold and
+// a wait-for cycle is a cycle of travelers waiting for the nodes held by
+// each other, which outlived a whole check interval. Every new problem is
+// written as a report, with abort set the watchdog then gives up the run.
+type Watchdog struct {
+	card         *TravelersCard
+	threshold    time.Duration
+	abort        bool
+	writer       io.Writer
+	reported     map[TravelerKey]time.Time
+	stopChannel  chan struct{}
+	abortChannel chan struct{}
+}
+
+func newWatchdog(
+	card *TravelersCard, threshold time.Duration, abort bool, writer io.Writer,
+) *Watchdog {
+	return &Watchdog{
+		card:         card,
+		threshold:    threshold,
+		abort:        abort,
+		writer:       writer,
+		reported:     make(map[TravelerKey]time.Time),
+		stopChannel:  make(chan struct{}),
+		abortChannel: make(chan struct{}),
+	}
+}
+
+func (watchdog *Watchdog) start(waitGroup *sync.WaitGroup) {
+	defer waitGroup.Done()
+
+	ticker := time.NewTicker(max(watchdog.threshold/4, minWatchdogInterval))
+	defer ticker.Stop()
+
+	var previous map[TravelerKey]TravelerWait
+	for {
+		select {
+		case <-ticker.C:
+			waits := watchdog.card.waits.snapshot()
+			if watchdog.check(waits, previous) && watchdog.abort {
+				close(watchdog.abortChannel)
+				return
+			}
+			previous = waits
+
+		case <-watchdog.stopChannel:
+			return
+		}
+	}
+}
+
+func (watchdog *Watchdog) stop() {
+	if watchdog != nil {
+		close(watchdog.stopChannel)
+	}
+}
+
+// aborted is closed once the watchdog gave up the run, it is nil without
+// a watchdog.
+func (watchdog *Watchdog) aborted() <-chan struct{} {
+	if watchdog == nil {
+		return nil
+	}
+	return watchdog.abortChannel
+}
+
+// check reports the stuck travelers and the wait-for cycles, it returns
+// true if there is any.
+func (watchdog *Watchdog) check(waits map[TravelerKey]TravelerWait, previous map[TravelerKey]TravelerWait) bool {
+	now := time.Now()
+
+	var stuck []TravelerKey
+	persistent := make(map[TravelerKey]TravelerWait)
+	for key, wait := range waits {
+		if now.Sub(wait.since) >= watchdog.threshold {
+			stuck = append(stuck, key)
+		}
+		if previousWait, exists := previous[key]; exists && previousWait.since.Equal(wait.since) {
+			persistent[key] = wait
+		}
+	}
+
+	if len(stuck) == 0 && len(persistent) == 0 {
+		return false
+	}
+
+	inspections, unresponsive := watchdog.inspect(persistent)
+	cycles := watchdog.findCycles(persistent, inspections)
+	if len(stuck) == 0 && len(cycles) == 0 {
+		return false
+	}
+
+	sort.Slice(stuck, func(i, j int) bool { return stuck[i].less(stuck[j]) })
+
+	isNew := false
+	for _, key := range stuck {
+		if reportedSince, exists := watchdog.reported[key]; !exists || !reportedSince.Equal(waits[key].since) {
+			isNew = true
+		}
+	}
+	for _, cycle := range cycles {
+		for _, key := range cycle {
+			if reportedSince, exists := watchdog.reported[key]; !exists || !reportedSince.Equal(waits[key].since) {
+				isNew = true
+			}
+		}
+	}
+
+	if isNew {
+		watchdog.report(now, waits, stuck, cycles, inspections, unresponsive)
+		for _, key := range stuck {
+			watchdog.reported[key] = waits[key].since
+		}
+		for _, cycle := range cycles {
+			for _, key := range cycle {
+				watchdog.reported[key] = waits[key].since
+			}
+		}
+	}
+	return true
+}
+
+// inspect asks the regions of the nodes the travelers wait for about the
+// state of the nodes, it returns the nodes of the regions which did not
+// answer in time too.
+func (watchdog *Watchdog) inspect(
+	waits map[TravelerKey]TravelerWait,
+) (map[Coordinates]NodeInspection, []Coordinates) {
+	requests := make(map[*Region]*RegionInspectRequest)
+	var unresponsive []Coordinates
+
+	for _, wait := range waits {
+		region := watchdog.card.grid[wait.node.y][wait.node.x].region
+		if _, exists := requests[region]; exists {
+			continue
+		}
+
+		request := newRegionInspectRequest()
+		select {
+		case region.requestChannel <- RegionRequest{inspectRequest: request}:
+			requests[region] = request
+		case <-time.After(inspectTimeout):
+			requests[region] = nil
+			unresponsive = append(unresponsive, wait.node)
+		}
+	}
+
+	inspections := make(map[Coordinates]NodeInspection)
+	for region, request := range requests {
+		if request == nil {
+			continue
+		}
+
+		select {
+		case nodes := <-request.response:
+			for _, inspection := range nodes {
+				inspections[inspection.c] = inspection
+			}
+		case <-time.After(inspectTimeout):
+			unresponsive = append(unresponsive, region.nodes[0].c)
+		}
+	}
+
+	return inspections, unresponsive
+}
+
+// findCycles follows the travelers holding the nodes the others wait for,
+// every traveler waits for a single node, so it has at most one successor.
+// A node only knows the id of its traveler, which is held by the latest
+// traveler given it.
+func (watchdog *Watchdog) findCycles(
+	waits map[TravelerKey]TravelerWait, inspections map[Coordinates]NodeInspection,
+) [][]TravelerKey {
+	holders := make(map[TravelerId]TravelerKey)
+	keys := make([]TravelerKey, 0, len(waits))
+	for key := range waits {
+		if holder, exists := holders[key.id]; !exists || holder.less(key) {
+			holders[key.id] = key
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].less(keys[j]) })
+
+	next := func(key TravelerKey) (TravelerKey, bool) {
+		inspection, exists := inspections[waits[key].node]
+		if !exists || inspection.travelerState == nodeAvailable || inspection.travelerId == key.id {
+			return TravelerKey{}, false
+		}
+		holder, waiting := holders[inspection.travelerId]
+		return holder, waiting
+	}
+
+	var cycles [][]TravelerKey
+	visited := make(map[TravelerKey]bool)
+	for _, start := range keys {
+		path := make(map[TravelerKey]int)
+		var chain []TravelerKey
+
+		key, ok := start, true
+		for ok && !visited[key] {
+			if index, onPath := path[key]; onPath {
+				cycles = append(cycles, chain[index:])
+				break
+			}
+			path[key] = len(chain)
+			chain = append(chain, key)
+			key, ok = next(key)
+		}
+
+		for _, key := range chain {
+			visited[key] = true
+		}
+	}
+
+	return cycles
+}
+
+func (watchdog *Watchdog) describeTraveler(key TravelerKey, wait TravelerWait) string {
+	return fmt.Sprintf("%s traveler %d", wait.species, key.id)
+}
+
+func (watchdog *Watchdog) report(
+	now time.Time, waits map[TravelerKey]TravelerWait, stuck []TravelerKey,
+	cycles [][]TravelerKey, inspections map[Coordinates]NodeInspection, unresponsive []Coordinates,
+) {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "Watchdog: %d traveler(s) waiting for over %v, %d wait-for cycle(s)\n",
+		len(stuck), watchdog.threshold, len(cycles))
+
+	for _, key := range stuck {
+		wait := waits[key]
+		fmt.Fprintf(&builder, "  %s waits %.1fs for %s of node (%d,%d) after %d attempt(s)",
+			watchdog.describeTraveler(key, wait), now.Sub(wait.since).Seconds(),
+			nodeRequestNames[wait.request], wait.node.x, wait.node.y, wait.attempts)
+		if inspection, exists := inspections[wait.node]; exists {
+			fmt.Fprintf(&builder, " - %v", inspection)
+		}
+		builder.WriteString("\n")
+	}
+
+	for _, cycle := range cycles {
+		builder.WriteString("  cycle:")
+		for _, key := range cycle {
+			wait := waits[key]
+			fmt.Fprintf(&builder, " %s -> node (%d,%d) ->",
+				watchdog.describeTraveler(key, wait), wait.node.x, wait.node.y)
+		}
+		fmt.Fprintf(&builder, " %s\n", watchdog.describeTraveler(cycle[0], waits[cycle[0]]))
+	}
+
+	for _, c := range unresponsive {
+		fmt.Fprintf(&builder, "  region of node (%d,%d) is not responding\n", c.x, c.y)
+	}
+
+	io.WriteString(watchdog.writer, builder.String())
+}
@@ -0,0 +1,47 @@
+package travelers
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestWaitTableRecycledIds keeps the waits of the travelers who had the
+// same id apart.
+func TestWaitTableRecycledIds(t *testing.T) {
+	species := &Species{Name: SpeciesNormal}
+	previous, current := TravelerKey{id: 1, spawnNumber: 1}, TravelerKey{id: 1, spawnNumber: 5}
+
+	table := newWaitTable()
+	table.wait(previous, species, Coordinates{0, 0}, travelerReserveNode)
+	table.wait(current, species, Coordinates{1, 0}, travelerReserveNode)
+	table.wait(current, species, Coordinates{1, 0}, travelerReserveNode)
+	table.done(previous)
+
+	waits := table.snapshot()
+	if wait, exists := waits[current]; len(waits) != 1 || !exists || wait.attempts != 2 {
+		t.Errorf("waits %+v, expected 2 attempts of the current traveler", waits)
+	}
+}
+
+// TestWatchdogFindCycles follows the node of a traveler to the traveler
+// holding its id now, not to a stale wait of the one before.
+func TestWatchdogFindCycles(t *testing.T) {
+	stale := TravelerKey{id: 1, spawnNumber: 1}
+	first, second := TravelerKey{id: 1, spawnNumber: 5}, TravelerKey{id: 2, spawnNumber: 2}
+
+	waits := map[TravelerKey]TravelerWait{
+		stale:  {node: Coordinates{2, 0}, request: travelerReserveNode},
+		first:  {node: Coordinates{1, 0}, request: travelerReserveNode},
+		second: {node: Coordinates{0, 0}, request: travelerReserveNode},
+	}
+	inspections := map[Coordinates]NodeInspection{
+		{0, 0}: {c: Coordinates{0, 0}, travelerState: nodeOccupied, travelerId: 1},
+		{1, 0}: {c: Coordinates{1, 0}, travelerState: nodeOccupied, travelerId: 2},
+		{2, 0}: {c: Coordinates{2, 0}, travelerState: nodeAvailable, travelerId: NullTraveler},
+	}
+
+	cycles := (&Watchdog{}).findCycles(waits, inspections)
+	if expected := [][]TravelerKey{{first, second}}; !reflect.DeepEqual(cycles, expected) {
+		t.Errorf("cycles %v, expected %v", cycles, expected)
+	}
+}
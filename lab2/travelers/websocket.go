@@ -0,0 +1,197 @@
+package travelers
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Type aliases
+
+type websocketOpcodeE uint8
+
+// Constants
+
+const websocketGUID string = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// the clients only send close, ping and pong frames, so their payload is small
+const websocketMaxReadPayload uint64 = 125
+
+const ( // websocketOpcodeE
+	websocketContinuation websocketOpcodeE = 0x0
+	websocketText         websocketOpcodeE = 0x1
+	websocketBinary       websocketOpcodeE = 0x2
+	websocketClose        websocketOpcodeE = 0x8
+	websocketPing         websocketOpcodeE = 0x9
+	websocketPong         websocketOpcodeE = 0xA
+)
+
+// Structures - WebSocket
+
+type websocketFrame struct {
+	opcode  websocketOpcodeE
+	payload []byte
+}
+
+// websocketConn is the server side of a WebSocket (RFC 6455) connection,
+// it is only safe to write frames from one goroutine and read them from
+// another.
+type websocketConn struct {
+	conn      net.Conn
+	reader    *bufio.Reader
+	closeOnce sync.Once
+}
+
+func websocketAccept(key string) string {
+	hash := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// upgradeWebSocket answers the opening handshake of the request and takes
+// the connection over from the http server.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*websocketConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || key == "" ||
+		!strings.EqualFold(r.Header.Get("Upgrade"), "websocket") ||
+		!headerContainsToken(r.Header, "Connection", "upgrade") {
+		http.Error(w, "expected a WebSocket handshake", http.StatusBadRequest)
+		return nil, errors.New("not a WebSocket handshake")
+	}
+	// browsers let any page open a WebSocket, they only tell its origin
+	if !sameOrigin(r) {
+		http.Error(w, "cross-origin WebSocket handshake", http.StatusForbidden)
+		return nil, errors.New("cross-origin WebSocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported WebSocket version", http.StatusUpgradeRequired)
+		return nil, errors.New("unsupported WebSocket version")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "cannot take the connection over", http.StatusInternalServerError)
+		return nil, errors.New("the response writer is not a hijacker")
+	}
+	conn, buffer, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	buffer.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + websocketAccept(key) + "\r\n\r\n")
+	if err := buffer.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &websocketConn{conn: conn, reader: buffer.Reader}, nil
+}
+
+// sameOrigin accepts the clients other than browsers, which send no Origin.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	originURL, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(originURL.Host, r.Host)
+}
+
+func headerContainsToken(header http.Header, name string, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, field := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeFrame writes an unfragmented frame, the frames of a server are
+// never masked.
+func (ws *websocketConn) writeFrame(frame websocketFrame) error {
+	header := []byte{0x80 | byte(frame.opcode)}
+	length := len(frame.payload)
+	switch {
+	case length < 126:
+		header = append(header, byte(length))
+	case length <= 0xFFFF:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(length))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(length))
+	}
+
+	if _, err := ws.conn.Write(header); err != nil {
+		return err
+	}
+	_, err := ws.conn.Write(frame.payload)
+	return err
+}
+
+// readFrame reads a frame of the client and unmasks its payload, the
+// fragmented frames are rejected since the clients only watch.
+func (ws *websocketConn) readFrame() (websocketFrame, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(ws.reader, header[:]); err != nil {
+		return websocketFrame{}, err
+	}
+
+	frame := websocketFrame{opcode: websocketOpcodeE(header[0] & 0x0F)}
+	if header[0]&0x80 == 0 || frame.opcode == websocketContinuation {
+		return frame, errors.New("fragmented frame")
+	}
+	if header[1]&0x80 == 0 {
+		return frame, errors.New("unmasked frame of a client")
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err := io.ReadFull(ws.reader, extended[:]); err != nil {
+			return frame, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err := io.ReadFull(ws.reader, extended[:]); err != nil {
+			return frame, err
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+	if length > websocketMaxReadPayload {
+		return frame, fmt.Errorf("frame of %d bytes is too large", length)
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(ws.reader, mask[:]); err != nil {
+		return frame, err
+	}
+	frame.payload = make([]byte, length)
+	if _, err := io.ReadFull(ws.reader, frame.payload); err != nil {
+		return frame, err
+	}
+	for i := range frame.payload {
+		frame.payload[i] ^= mask[i%4]
+	}
+	return frame, nil
+}
+
+func (ws *websocketConn) close() {
+	ws.closeOnce.Do(func() { ws.conn.Close() })
+}
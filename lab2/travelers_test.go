@@ -0,0 +1,156 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"lab2/travelers"
+)
+
+func writeFile(t *testing.T, name string, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseArgs(t *testing.T) {
+	species := writeFile(t, "species.json", `[
+		{"name": "walker", "spawn_prob": 0.3, "movement": "random", "move_prob": 0.5},
+		{"name": "rock", "glyph": "o", "spawn_prob": 0.2, "max_travelers": 3}
+	]`)
+	greedySpecies := writeFile(t, "greedy.json", `[
+		{"name": "walker", "spawn_prob": 0.7, "movement": "random", "move_prob": 0.5},
+		{"name": "rock", "glyph": "o", "spawn_prob": 0.3}
+	]`)
+	config := writeFile(t, "config.json", `{"spawn_prob": 0.6, "wild_prob": 0.3, "danger_prob": 0.1}`)
+	badConfig := writeFile(t, "bad.json", `{"spawn_probability": 0.6}`)
+	zeroConfig := writeFile(t, "zero.json", `{"queue_size": 0}`)
+	scenario := writeFile(t, "scenario.json", `[
+		{"at": "2s", "cells": [{"x": 1, "y": 1}], "shape": "disc", "duration": 8},
+		{"at": "1s", "cells": [{"x": 0, "y": 3}, {"x": 1, "y": 3}, {"x": 2, "y": 3}]}
+	]`)
+	offCardScenario := writeFile(t, "off.json", `[{"at": "1s", "cells": [{"x": 6, "y": 0}]}]`)
+	exitScenario := writeFile(t, "exit.json", `[{"at": "1s", "cells": [{"x": 2, "y": 2}]}]`)
+	badScenario := writeFile(t, "bad-scenario.json", `[{"at": "1s", "cells": [{"x": 0, "y": 0}], "shape": "star"}]`)
+	lateScenario := writeFile(t, "late.json", `[{"at": "soon", "cells": [{"x": 0, "y": 0}]}]`)
+	tests := []struct {
+		name  string
+		args  []string
+		error string
+	}{
+		{"defaults", nil, ""},
+		{"all options", []string{
+			"-H", "5", "-W", "7", "-T", "12", "-s", "0.5", "-m", "1", "-w", "0.2", "-d", "0.3",
+			"--danger-zone-duration", "5", "--queue-size", "20", "--seed", "3", "--fast-forward",
+			"--speed", "2", "--spawn-timing", "uniform:100ms..200ms", "--think-timing", "fixed:1s",
+			"--duration", "1m", "--pictures", "7", "--watchdog", "5s", "--topology", "hex",
+			"--exits", "0,0;6,4", "--lifetime", "20", "--routing", "goal", "--render", "plain",
+			"--export", "run.svg", "--serve", ":0", "--control", ":0",
+		}, ""},
+		{"probabilities adding up to 1", []string{"-s", "0.1", "-w", "0.2", "-d", "0.7"}, ""},
+		{"unknown flag", []string{"--bogus"}, "unknown arguments"},
+		{"not a number", []string{"-s", "often"}, "bad float"},
+		{"negative spawn_prob", []string{"-s", "-0.5"}, "invalid value of spawn_prob"},
+		{"spawn_prob above 1", []string{"-s", "1.5", "-w", "0", "-d", "0"}, "invalid value of spawn_prob"},
+		{"move_prob above 1", []string{"-m", "1.1"}, "invalid value of move_prob"},
+		{"wild_prob above 1", []string{"-w", "2"}, "invalid value of wild_prob"},
+		{"danger_prob above 1", []string{"-d", "1.01"}, "invalid value of danger_prob"},
+		{"probabilities above 1", []string{"-s", "0.5", "-w", "0.3", "-d", "0.3"}, "add up to 1.1"},
+		{"height too large", []string{"-H", "1001"}, "invalid value of height"},
+		{"too many travelers", []string{"-H", "2", "-W", "2", "-T", "5"}, "invalid value of max_travelers"},
+		{"negative lifetime", []string{"--lifetime", "-1"}, "invalid value of lifetime"},
+		{"negative pictures", []string{"--pictures", "-1"}, "invalid value of pictures"},
+		{"bad duration", []string{"--duration", "soon"}, "invalid value of duration"},
+		{"bad watchdog", []string{"--watchdog", "5"}, "invalid value of watchdog"},
+		{"bad timing", []string{"--think-timing", "normal:1s"}, "timing"},
+		{"zero speed", []string{"--speed", "0.0"}, "invalid value of speed"},
+		{"zero danger zone duration", []string{"--danger-zone-duration", "0"}, "invalid value of danger_zone_duration"},
+		{"zero queue size", []string{"--queue-size", "0"}, "invalid value of queue_size"},
+		{"speed too high", []string{"--speed", "5000"}, "invalid value of speed"},
+		{"danger zone too long", []string{"--danger-zone-duration", "1001"}, "invalid value of danger_zone_duration"},
+		{"queue too long", []string{"--queue-size", "100001"}, "invalid value of queue_size"},
+		{"exit off the card", []string{"--exits", "6,0"}, "must be on the card"},
+		{"unknown topology", []string{"--topology", "cube"}, "topology"},
+		{"unknown export format", []string{"--export", "run.png"}, "unknown export format"},
+		{"export too large", []string{"--export", "run.gif", "-W", "101", "-H", "5"}, "cannot export a 101x5 card"},
+		{"species", []string{"--species", species, "-s", "0.9"}, ""},
+		{"species above 1", []string{"--species", greedySpecies, "-d", "0.1"}, "add up to 1.1"},
+		{"missing species", []string{"--species", filepath.Join(t.TempDir(), "none.json")}, "cannot load the species"},
+		{"danger zones", []string{
+			"--danger-zone-shape", "disc", "--danger-zone-spread", "0.5", "--danger-zones-kill",
+			"--scenario", scenario,
+		}, ""},
+		{"danger zone spread above 1", []string{"--danger-zone-spread", "1.5"}, "invalid value of danger_zone_spread"},
+		{"unknown danger zone shape", []string{"--danger-zone-shape", "star"}, "danger-zone-shape"},
+		{"scenario off the card", []string{"--scenario", offCardScenario}, "danger zone 1: cell (6,0) must be on the card"},
+		{"scenario on an exit", []string{"--scenario", exitScenario, "--exits", "2,2"}, "must not be a wall or an exit"},
+		{"scenario with an unknown shape", []string{"--scenario", badScenario}, "danger zone 1: shape must be one of"},
+		{"scenario with a bad time", []string{"--scenario", lateScenario}, "cannot load the scenario"},
+		{"config", []string{"--config", config}, ""},
+		{"config above 1", []string{"--config", config, "-d", "0.2"}, "add up to 1.1"},
+		{"config fixed by the flags", []string{"--config", config, "-d", "0.2", "-w", "0.2"}, ""},
+		{"unknown config key", []string{"--config", badConfig}, "cannot load the config"},
+		{"zero in the config", []string{"--config", zeroConfig}, "invalid value of queue_size"},
+		{"missing config", []string{"--config", filepath.Join(t.TempDir(), "none.json")}, "cannot load the config"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := parseArgs(append([]string{"travelers"}, test.args...))
+			if test.error == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), test.error) {
+				t.Fatalf("expected an error containing %q, got %v", test.error, err)
+			}
+		})
+	}
+}
+
+func TestParseArgsOverrides(t *testing.T) {
+	config := writeFile(t, "config.json", `{
+		"height": 5, "width": 9, "spawn_prob": 0.3, "seed": 42, "duration": "10s",
+		"think_timing": "fixed:2s", "topology": "torus", "render": "plain"
+	}`)
+
+	_, arguments, err := parseArgs([]string{
+		"travelers", "--config", config, "-W", "7", "--duration", "1m", "--dump-config",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runConfig := arguments.runConfig
+	if runConfig.Height != 5 || runConfig.Width != 7 {
+		t.Errorf("wrong size: %dx%d", runConfig.Width, runConfig.Height)
+	}
+	if runConfig.SpawnProb != 0.3 || runConfig.MoveProb != defaultRunConfig().MoveProb {
+		t.Errorf("wrong probabilities: spawn %v, move %v", runConfig.SpawnProb, runConfig.MoveProb)
+	}
+	if runConfig.Seed == nil || *runConfig.Seed != 42 {
+		t.Errorf("wrong seed: %v", runConfig.Seed)
+	}
+	if time.Duration(runConfig.Duration) != time.Minute {
+		t.Errorf("wrong duration: %v", time.Duration(runConfig.Duration))
+	}
+	if runConfig.ThinkTiming.String() != "fixed:2s" {
+		t.Errorf("wrong think timing: %v", runConfig.ThinkTiming)
+	}
+	if !arguments.dumpConfig {
+		t.Error("dump-config not set")
+	}
+
+	simulationConfig := arguments.config
+	if simulationConfig.Topology != travelers.TopologyTorus || simulationConfig.Seed != 42 ||
+		!simulationConfig.Deterministic || simulationConfig.Duration != time.Minute {
+		t.Errorf("wrong simulation config: %+v", simulationConfig)
+	}
+}